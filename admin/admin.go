@@ -1,7 +1,15 @@
 package admin
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// Deduction is the amount to set a deduction to. With EffectiveFrom the
+// change is scheduled to take effect at that time instead of now.
 type Deduction struct {
-	Deduction float64 `json:"amount" validate:"min=0"`
+	Deduction     float64    `json:"amount" validate:"min=0"`
+	EffectiveFrom *time.Time `json:"effectiveFrom,omitempty"`
 }
 
 type PersonalDeduction struct {
@@ -11,3 +19,112 @@ type PersonalDeduction struct {
 type KReceiptDeduction struct {
 	Deduction float64 `json:"kReceipt"`
 }
+
+type DonationDeduction struct {
+	Deduction float64 `json:"donation"`
+}
+
+// DeductionAmounts are the amounts of several deductions, by name.
+type DeductionAmounts map[string]float64
+
+// DeductionsUpdate is the body of a request setting several deductions: the
+// amounts by name and, as with Deduction, an optional effectiveFrom key to
+// schedule them all to take effect at that time instead of now.
+type DeductionsUpdate struct {
+	Amounts       DeductionAmounts
+	EffectiveFrom *time.Time
+}
+
+func (u *DeductionsUpdate) UnmarshalJSON(data []byte) error {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if v, ok := fields[effectiveFromKey]; ok {
+		if err := json.Unmarshal(v, &u.EffectiveFrom); err != nil {
+			return err
+		}
+		delete(fields, effectiveFromKey)
+	}
+	u.Amounts = make(DeductionAmounts, len(fields))
+	for name, v := range fields {
+		var amount float64
+		if err := json.Unmarshal(v, &amount); err != nil {
+			return err
+		}
+		u.Amounts[name] = amount
+	}
+	return nil
+}
+
+// effectiveFromKey is the key of a DeductionsUpdate that is not a deduction.
+const effectiveFromKey = "effectiveFrom"
+
+// DeductionSetting is the current value of a deduction and the range it can
+// be set to: above Min, when there is one, and up to Max.
+type DeductionSetting struct {
+	Name      string    `json:"name"`
+	Amount    float64   `json:"amount"`
+	Min       *float64  `json:"min"`
+	Max       float64   `json:"max"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+type DeductionSettings struct {
+	Deductions []DeductionSetting `json:"deductions"`
+}
+
+type DeductionChange struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	OldAmount float64   `json:"oldAmount"`
+	NewAmount float64   `json:"newAmount"`
+	ChangedBy string    `json:"changedBy"`
+	ChangedAt time.Time `json:"changedAt"`
+	SourceIP  string    `json:"sourceIp"`
+	RequestID string    `json:"requestId"`
+}
+
+// DeductionChanges is a page of the audit log. Total counts every change
+// matching the filter.
+type DeductionChanges struct {
+	Changes []DeductionChange `json:"changes"`
+	Total   int               `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+}
+
+type DeductionSchedule struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	Amount        float64    `json:"amount"`
+	EffectiveFrom time.Time  `json:"effectiveFrom"`
+	Status        string     `json:"status"`
+	CreatedBy     string     `json:"createdBy"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedBy     string     `json:"updatedBy"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	CancelledBy   string     `json:"cancelledBy,omitempty"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+}
+
+type DeductionSchedules struct {
+	Schedules []DeductionSchedule `json:"schedules"`
+}
+
+// ScheduleUpdate is the new amount and effective time of a pending schedule.
+type ScheduleUpdate struct {
+	Amount        float64   `json:"amount" validate:"min=0"`
+	EffectiveFrom time.Time `json:"effectiveFrom" validate:"required"`
+}
+
+type ExchangeRate struct {
+	Currency string  `json:"currency" validate:"required"`
+	Date     string  `json:"date" validate:"required"`
+	Rate     float64 `json:"rate"`
+}
+
+type ExchangeRates struct {
+	Rates []ExchangeRate `json:"rates" validate:"required,min=1"`
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var exchangeRateCSVHeader = []string{"currency", "date", "rate"}
+
+func validateExchangeRateHeader(header []string) error {
+	if len(header) != len(exchangeRateCSVHeader) {
+		return ErrInvalidCSVHeader
+	}
+	for i, name := range exchangeRateCSVHeader {
+		if strings.TrimSpace(header[i]) != name {
+			return ErrInvalidCSVHeader
+		}
+	}
+	return nil
+}
+
+func readExchangeRateCSV(r io.Reader) ([]ExchangeRate, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, ErrReadingCSV
+	}
+	if len(records) == 0 {
+		return nil, ErrInvalidCSVHeader
+	}
+	if err := validateExchangeRateHeader(records[0]); err != nil {
+		return nil, err
+	}
+
+	result := make([]ExchangeRate, 0, len(records)-1)
+	for _, row := range records[1:] {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err != nil {
+			return nil, ErrInvalidExchangeRate
+		}
+		result = append(result, ExchangeRate{
+			Currency: row[0],
+			Date:     row[1],
+			Rate:     rate,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+//go:build unit
+
+package admin
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadExchangeRateCSV_Success(t *testing.T) {
+	// Arrange
+	data := "currency,date,rate\nUSD,2024-01-15,35.5\njpy, 2024-01-16 , 0.24 "
+
+	// Act
+	got, err := readExchangeRateCSV(strings.NewReader(data))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []ExchangeRate{
+		{Currency: "USD", Date: "2024-01-15", Rate: 35.5},
+		{Currency: "jpy", Date: " 2024-01-16 ", Rate: 0.24},
+	}, got)
+}
+
+func TestReadExchangeRateCSV_Error(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{name: "empty file", data: "", wantErr: ErrInvalidCSVHeader},
+		{name: "wrong column order", data: "date,currency,rate\n2024-01-15,USD,35.5", wantErr: ErrInvalidCSVHeader},
+		{name: "inconsistent columns", data: "currency,date,rate\nUSD,2024-01-15", wantErr: ErrReadingCSV},
+		{name: "rate is not number", data: "currency,date,rate\nUSD,2024-01-15,x", wantErr: ErrInvalidExchangeRate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := readExchangeRateCSV(strings.NewReader(tc.data))
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
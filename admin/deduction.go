@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	mw "github.com/golfz/assessment-tax/middleware"
+	"github.com/labstack/echo/v4"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxRequestIDLength is the length of the request_id column of the audit log.
+const maxRequestIDLength = 100
+
+// actorOf is the admin making the request, as recorded in the audit log. The
+// request id comes from the client when it sent one, so it is cleaned of
+// unprintable characters and cut to fit.
+func actorOf(c echo.Context) deduction.Actor {
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	requestID = strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, requestID)
+	if runes := []rune(requestID); len(runes) > maxRequestIDLength {
+		requestID = string(runes[:maxRequestIDLength])
+	}
+	return deduction.Actor{
+		Username:  mw.Username(c),
+		SourceIP:  sourceIP(c),
+		RequestID: requestID,
+	}
+}
+
+// sourceIP is the address of the client. Anything but an ip taken from a
+// forwarding header falls back to the address of the connection.
+func sourceIP(c echo.Context) string {
+	if ip := c.RealIP(); net.ParseIP(ip) != nil {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+	return host
+}
+
+func toDeductionSetting(s deduction.Setting) DeductionSetting {
+	limit, _ := deduction.LimitOf(s.Name)
+	return DeductionSetting{
+		Name:      s.Name,
+		Amount:    s.Amount,
+		Min:       limit.Min,
+		Max:       limit.Max,
+		UpdatedAt: s.UpdatedAt,
+		UpdatedBy: s.UpdatedBy,
+	}
+}
+
+// GetDeductionsHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin list deductions
+//	@Description	Admin list the current deductions with the range each can be set to (above min, when set, and up to max), when it was last changed and by whom
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	DeductionSettings
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions [get]
+func (h *Handler) GetDeductionsHandler(c echo.Context) error {
+	settings, err := h.store.GetDeductionSettings()
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+
+	result := DeductionSettings{Deductions: make([]DeductionSetting, 0, len(settings))}
+	for _, s := range settings {
+		if _, ok := deduction.LimitOf(s.Name); !ok {
+			continue
+		}
+		result.Deductions = append(result.Deductions, toDeductionSetting(s))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetDeductionHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin get a deduction
+//	@Description	Admin get the current value of a deduction with the range it can be set to, when it was last changed and by whom
+//	@Tags			admin
+//	@Param			name	path	string	true	"Deduction name"	Enums(personal, k-receipt, donation)
+//	@Produce		json
+//	@Success		200	{object}	DeductionSetting
+//	@Failure		401	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/{name} [get]
+func (h *Handler) GetDeductionHandler(c echo.Context) error {
+	name := c.Param("name")
+	if _, ok := deduction.LimitOf(name); !ok {
+		return h.handleError(c, http.StatusNotFound, deduction.ErrDeductionNotFound, "getting deduction", ErrDeductionNotFound.Error())
+	}
+
+	setting, err := h.store.GetDeductionSetting(name)
+	if errors.Is(err, deduction.ErrDeductionNotFound) {
+		return h.handleError(c, http.StatusNotFound, err, "getting deduction", ErrDeductionNotFound.Error())
+	}
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+	return c.JSON(http.StatusOK, toDeductionSetting(setting))
+}
+
+// validateDeductionAmounts checks every amount against the definition of its
+// deduction, in name order so the same body always fails the same way.
+func validateDeductionAmounts(amounts DeductionAmounts) error {
+	if len(amounts) == 0 {
+		return ErrInputValidation
+	}
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def, ok := deduction.DefinitionOf(name)
+		if !ok {
+			return ErrUnknownDeduction
+		}
+		if amounts[name] < 0 {
+			return ErrInputValidation
+		}
+		if err := def.Validate(amounts[name]); err != nil {
+			return ErrInvalidInputDeduction
+		}
+	}
+	return nil
+}
+
+// UpdateDeductionsHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin set several deductions at once
+//	@Description	Admin set the deductions of a map of names to amounts. Every amount is checked first and all of them are set together: when one is invalid nothing changes, and a calculation never sees some of the new amounts without the others.
+//	@Description	With an effectiveFrom key in the body, an RFC 3339 time in the future, the amounts are scheduled to take effect together at that time instead, and the schedules are returned with 202.
+//	@Tags			admin
+//	@Accept			json
+//	@Param			amounts	body	DeductionAmounts	true	"Amounts by deduction name, and an optional effectiveFrom"
+//	@Produce		json
+//	@Success		200	{object}	DeductionAmounts
+//	@Success		202	{object}	DeductionSchedules
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions [patch]
+func (h *Handler) UpdateDeductionsHandler(c echo.Context) error {
+	var update DeductionsUpdate
+	if err := c.Bind(&update); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
+	}
+	input := update.Amounts
+	if err := validateDeductionAmounts(input); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating deduction", err.Error())
+	}
+	if update.EffectiveFrom != nil {
+		schedules, err := h.createSchedules(c, input, *update.EffectiveFrom)
+		if err != nil {
+			return h.handleScheduleError(c, err)
+		}
+		return c.JSON(http.StatusAccepted, DeductionSchedules{Schedules: schedules})
+	}
+
+	err := h.store.SetDeductions(input, actorOf(c))
+	if errors.Is(err, deduction.ErrDeductionNotFound) {
+		return h.handleError(c, http.StatusNotFound, err, "setting deduction", ErrDeductionNotFound.Error())
+	}
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "setting deduction", ErrSettingDeduction.Error())
+	}
+	return c.JSON(http.StatusOK, input)
+}
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// parseHistoryTime reads a from or to filter, either a timestamp or a date.
+// A date as the end of a range covers the whole day.
+func parseHistoryTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func parseHistoryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, ErrInvalidHistoryFilter
+	}
+	return n, nil
+}
+
+func historyFilter(c echo.Context) (deduction.ChangeFilter, error) {
+	filter := deduction.ChangeFilter{
+		Name:      c.QueryParam("name"),
+		ChangedBy: c.QueryParam("changedBy"),
+	}
+	var err error
+	if filter.From, err = parseHistoryTime(c.QueryParam("from"), false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseHistoryTime(c.QueryParam("to"), true); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = parseHistoryInt(c.QueryParam("limit"), defaultHistoryLimit); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = parseHistoryInt(c.QueryParam("offset"), 0); err != nil {
+		return filter, err
+	}
+	if filter.Limit == 0 || filter.Limit > maxHistoryLimit {
+		return filter, ErrInvalidHistoryFilter
+	}
+	return filter, nil
+}
+
+// GetDeductionHistoryHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin list changes of deductions
+//	@Description	Admin list the audit log of deduction changes, newest first: the old and new amount, the admin who made the change, when, and the source ip and request id of the request. The log is append-only.
+//	@Description	from and to take a timestamp (RFC 3339) or a date; from is inclusive, to is exclusive for a timestamp and covers the whole day for a date.
+//	@Tags			admin
+//	@Param			name		query	string	false	"Deduction name"
+//	@Param			changedBy	query	string	false	"Admin username"
+//	@Param			from		query	string	false	"Changes at or after, e.g. 2024-03-01"
+//	@Param			to			query	string	false	"Changes before, e.g. 2024-03-31"
+//	@Param			limit		query	int		false	"Changes per page, at most 500"	default(50)
+//	@Param			offset		query	int		false	"Changes to skip"					default(0)
+//	@Produce		json
+//	@Success		200	{object}	DeductionChanges
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/history [get]
+func (h *Handler) GetDeductionHistoryHandler(c echo.Context) error {
+	filter, err := historyFilter(c)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading history filter", ErrInvalidHistoryFilter.Error())
+	}
+
+	changes, total, err := h.store.GetDeductionChanges(filter)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction history", ErrGettingDeductionHistory.Error())
+	}
+
+	result := DeductionChanges{
+		Changes: make([]DeductionChange, 0, len(changes)),
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}
+	for _, ch := range changes {
+		result.Changes = append(result.Changes, DeductionChange(ch))
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,381 @@
+//go:build unit
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	mw "github.com/golfz/assessment-tax/middleware"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updatedAt = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+func TestGetDeductionsHandler(t *testing.T) {
+	t.Run("expect deductions with limits", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions", nil)
+		mock.settings = []deduction.Setting{
+			{Name: deduction.NameDonation, Amount: 100_000, UpdatedAt: updatedAt},
+			{Name: deduction.NamePersonal, Amount: 70_000, UpdatedAt: updatedAt, UpdatedBy: "admin"},
+			{Name: "unknown", Amount: 1},
+		}
+		mock.ExpectToCall(MethodGetDeductionSettings)
+
+		// Act
+		err := h.GetDeductionsHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got DeductionSettings
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		minPersonal := deduction.MinPersonalDeduction
+		assert.Equal(t, DeductionSettings{Deductions: []DeductionSetting{
+			{Name: deduction.NameDonation, Amount: 100_000, Max: deduction.MaxDonationDeduction, UpdatedAt: updatedAt},
+			{Name: deduction.NamePersonal, Amount: 70_000, Min: &minPersonal, Max: deduction.MaxPersonalDeduction, UpdatedAt: updatedAt, UpdatedBy: "admin"},
+		}}, got)
+		assert.Contains(t, rec.Body.String(), `"min":null`)
+	})
+
+	t.Run("GetDeductionSettings() error; expect 500", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions", nil)
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := h.GetDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrGettingDeduction.Error()+`"}`, rec.Body.String())
+	})
+}
+
+func TestGetDeductionHandler(t *testing.T) {
+	t.Run("known deduction; expect setting", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/k-receipt", nil)
+		c.SetParamNames("name")
+		c.SetParamValues(deduction.NameKReceipt)
+		mock.settings = []deduction.Setting{{Name: deduction.NameKReceipt, Amount: 50_000, UpdatedAt: updatedAt, UpdatedBy: "admin"}}
+		mock.ExpectToCall(MethodGetDeductionSetting)
+
+		// Act
+		err := h.GetDeductionHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, deduction.NameKReceipt, mock.whatIsName)
+		var got DeductionSetting
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		minKReceipt := deduction.MinKReceiptDeduction
+		assert.Equal(t, DeductionSetting{
+			Name:      deduction.NameKReceipt,
+			Amount:    50_000,
+			Min:       &minKReceipt,
+			Max:       deduction.MaxKReceiptDeduction,
+			UpdatedAt: updatedAt,
+			UpdatedBy: "admin",
+		}, got)
+	})
+
+	t.Run("unknown deduction; expect 404 without query", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/unknown", nil)
+		c.SetParamNames("name")
+		c.SetParamValues("unknown")
+
+		// Act
+		err := h.GetDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotContains(t, mock.methodToCall, MethodGetDeductionSetting)
+	})
+
+	t.Run("deduction missing from store; expect 404", func(t *testing.T) {
+		// Arrange
+		rec, c, h, _ := setup(http.MethodGet, "/admin/deductions/donation", nil)
+		c.SetParamNames("name")
+		c.SetParamValues(deduction.NameDonation)
+
+		// Act
+		err := h.GetDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrDeductionNotFound.Error()+`"}`, rec.Body.String())
+	})
+
+	t.Run("GetDeductionSetting() error; expect 500", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/personal", nil)
+		c.SetParamNames("name")
+		c.SetParamValues(deduction.NamePersonal)
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := h.GetDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrGettingDeduction.Error()+`"}`, rec.Body.String())
+	})
+}
+
+func TestUpdateDeductionsHandler(t *testing.T) {
+	t.Run("valid amounts; expect all set together", func(t *testing.T) {
+		// Arrange
+		body := DeductionAmounts{deduction.NamePersonal: 70_000, deduction.NameDonation: 90_000}
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", body)
+		c.Set(mw.ContextKeyUsername, "admin")
+		mock.ExpectToCall(MethodSetDeductions)
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, map[string]float64(body), mock.whatAreAmounts)
+		assert.Equal(t, "admin", mock.whatIsActor.Username)
+		assert.JSONEq(t, `{"personal":70000,"donation":90000}`, rec.Body.String())
+	})
+
+	testCases := []struct {
+		name    string
+		body    interface{}
+		wantErr error
+	}{
+		{name: "empty map", body: DeductionAmounts{}, wantErr: ErrInputValidation},
+		{name: "not a map", body: []float64{1}, wantErr: ErrReadingRequestBody},
+		{name: "unknown deduction", body: DeductionAmounts{deduction.NamePersonal: 70_000, "unknown": 1}, wantErr: ErrUnknownDeduction},
+		{name: "negative amount", body: DeductionAmounts{deduction.NameDonation: -1}, wantErr: ErrInputValidation},
+		{name: "one amount out of range", body: DeductionAmounts{deduction.NamePersonal: 70_000, deduction.NameKReceipt: deduction.MaxKReceiptDeduction + 1}, wantErr: ErrInvalidInputDeduction},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name+"; expect 400 and nothing set", func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", tc.body)
+
+			// Act
+			err := h.UpdateDeductionsHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, `{"message":"`+tc.wantErr.Error()+`"}`, rec.Body.String())
+			assert.NotContains(t, mock.methodToCall, MethodSetDeductions)
+		})
+	}
+
+	t.Run("SetDeductions() error; expect 500", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", DeductionAmounts{deduction.NamePersonal: 70_000})
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrSettingDeduction.Error()+`"}`, rec.Body.String())
+	})
+}
+
+func TestActorOf(t *testing.T) {
+	// Arrange
+	_, c, _, _ := setup(http.MethodPut, "/admin/deductions/personal", nil)
+	c.Set(mw.ContextKeyUsername, "admin")
+	c.Request().RemoteAddr = "192.0.2.1:54321"
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+	// Act
+	got := actorOf(c)
+
+	// Assert
+	assert.Equal(t, deduction.Actor{Username: "admin", SourceIP: "192.0.2.1", RequestID: "req-1"}, got)
+}
+
+func TestActorOf_OversizedHeaders(t *testing.T) {
+	// Arrange
+	_, c, _, _ := setup(http.MethodPut, "/admin/deductions/personal", nil)
+	c.Request().RemoteAddr = "192.0.2.1:54321"
+	c.Request().Header.Set(echo.HeaderXForwardedFor, strings.Repeat("9", 200))
+	c.Request().Header.Set(echo.HeaderXRequestID, "req\x00"+strings.Repeat("x", 300))
+
+	// Act
+	got := actorOf(c)
+
+	// Assert
+	assert.Equal(t, "192.0.2.1", got.SourceIP)
+	assert.Equal(t, "req"+strings.Repeat("x", maxRequestIDLength-3), got.RequestID)
+}
+
+func TestSetDeductionHandler_OversizedHeaders(t *testing.T) {
+	// Arrange
+	rec, c, h, mock := setup(http.MethodPut, "/admin/deductions/personal", Deduction{Deduction: 70_000})
+	c.SetParamNames("name")
+	c.SetParamValues(deduction.NamePersonal)
+	c.Request().Header.Set(echo.HeaderXRealIP, strings.Repeat("1", 100))
+	c.Request().Header.Set(echo.HeaderXRequestID, strings.Repeat("r", 500))
+	mock.ExpectToCall(MethodSetDeduction)
+
+	// Act
+	err := h.SetDeductionHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mock.Verify(t)
+	assert.LessOrEqual(t, len(mock.whatIsActor.SourceIP), 45)
+	assert.Len(t, mock.whatIsActor.RequestID, maxRequestIDLength)
+}
+
+func TestHistoryFilter(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		want    deduction.ChangeFilter
+		wantErr bool
+	}{
+		{
+			name:  "no query; expect first page",
+			query: "",
+			want:  deduction.ChangeFilter{Limit: defaultHistoryLimit},
+		},
+		{
+			name:  "all filters",
+			query: "name=personal&changedBy=admin&from=2024-03-01&to=2024-03-31&limit=10&offset=20",
+			want: deduction.ChangeFilter{
+				Name:      "personal",
+				ChangedBy: "admin",
+				From:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				To:        time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				Limit:     10,
+				Offset:    20,
+			},
+		},
+		{
+			name:  "timestamps",
+			query: "from=2024-03-01T09:00:00Z&to=2024-03-01T10:00:00Z",
+			want: deduction.ChangeFilter{
+				From:  time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+				To:    time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				Limit: defaultHistoryLimit,
+			},
+		},
+		{name: "invalid from", query: "from=yesterday", wantErr: true},
+		{name: "negative offset", query: "offset=-1", wantErr: true},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "limit over maximum", query: "limit=501", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			_, c, _, _ := setup(http.MethodGet, "/admin/deductions/history?"+tc.query, nil)
+
+			// Act
+			got, err := historyFilter(c)
+
+			// Assert
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGetDeductionHistoryHandler(t *testing.T) {
+	t.Run("expect page of changes", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/history?name=personal&limit=10", nil)
+		mock.changes = []deduction.Change{{
+			ID:        7,
+			Name:      deduction.NamePersonal,
+			OldAmount: 60_000,
+			NewAmount: 70_000,
+			ChangedBy: "admin",
+			ChangedAt: updatedAt,
+			SourceIP:  "192.0.2.1",
+			RequestID: "req-1",
+		}}
+		mock.ExpectToCall(MethodGetDeductionChanges)
+
+		// Act
+		err := h.GetDeductionHistoryHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, deduction.ChangeFilter{Name: deduction.NamePersonal, Limit: 10}, mock.whatIsFilter)
+		assert.JSONEq(t, `{
+			"changes": [{
+				"id": 7,
+				"name": "personal",
+				"oldAmount": 60000,
+				"newAmount": 70000,
+				"changedBy": "admin",
+				"changedAt": "2024-03-01T09:30:00Z",
+				"sourceIp": "192.0.2.1",
+				"requestId": "req-1"
+			}],
+			"total": 1,
+			"limit": 10,
+			"offset": 0
+		}`, rec.Body.String())
+	})
+
+	t.Run("invalid filter; expect 400", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/history?limit=abc", nil)
+
+		// Act
+		err := h.GetDeductionHistoryHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrInvalidHistoryFilter.Error()+`"}`, rec.Body.String())
+		assert.NotContains(t, mock.methodToCall, MethodGetDeductionChanges)
+	})
+
+	t.Run("GetDeductionChanges() error; expect 500", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/history", nil)
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := h.GetDeductionHistoryHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrGettingDeductionHistory.Error()+`"}`, rec.Body.String())
+	})
+}
@@ -9,4 +9,30 @@ var (
 	ErrInputValidation       = errors.New("invalid input")
 	ErrInvalidInputDeduction = errors.New("invalid input deduction")
 	ErrSettingDeduction      = errors.New("error setting deduction")
+	ErrGettingDeduction      = errors.New("error getting deduction")
+	ErrDeductionNotFound     = errors.New("deduction not found")
+	ErrUnknownDeduction      = errors.New("unknown deduction")
+
+	ErrInvalidHistoryFilter    = errors.New("invalid history filter")
+	ErrGettingDeductionHistory = errors.New("error getting deduction history")
+)
+
+var (
+	ErrInvalidEffectiveFrom = errors.New("effective time must be in the future")
+	ErrScheduleNotFound     = errors.New("scheduled deduction not found")
+	ErrScheduleNotPending   = errors.New("scheduled deduction is not pending")
+	ErrGettingSchedule      = errors.New("error getting scheduled deduction")
+	ErrSettingSchedule      = errors.New("error setting scheduled deduction")
+)
+
+var (
+	ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+	ErrGettingExchangeRate = errors.New("error getting exchange rate")
+	ErrSettingExchangeRate = errors.New("error setting exchange rate")
+)
+
+var (
+	ErrUploadingFile    = errors.New("cannot uploading file")
+	ErrReadingCSV       = errors.New("cannot reading csv")
+	ErrInvalidCSVHeader = errors.New("invalid csv header")
 )
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func toExchangeRate(input ExchangeRate) (exchange.Rate, error) {
+	date, err := exchange.ParseDate(input.Date)
+	if err != nil {
+		return exchange.Rate{}, err
+	}
+	rate := exchange.Rate{
+		Currency: exchange.NormaliseCurrency(input.Currency),
+		Date:     date,
+		Rate:     input.Rate,
+	}
+	return rate, rate.Validate()
+}
+
+func toExchangeRates(inputs []ExchangeRate) ([]exchange.Rate, error) {
+	result := make([]exchange.Rate, 0, len(inputs))
+	for _, input := range inputs {
+		rate, err := toExchangeRate(input)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rate)
+	}
+	return result, nil
+}
+
+func fromExchangeRates(rates []exchange.Rate) ExchangeRates {
+	result := ExchangeRates{Rates: make([]ExchangeRate, 0, len(rates))}
+	for _, rate := range rates {
+		result.Rates = append(result.Rates, ExchangeRate{
+			Currency: rate.Currency,
+			Date:     rate.Date.Format(exchange.DateLayout),
+			Rate:     rate.Rate,
+		})
+	}
+	return result
+}
+
+func (h *Handler) saveExchangeRates(c echo.Context, inputs []ExchangeRate) error {
+	rates, err := toExchangeRates(inputs)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating exchange rate", ErrInvalidExchangeRate.Error())
+	}
+	if err := h.store.SetExchangeRates(rates); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "setting exchange rate", ErrSettingExchangeRate.Error())
+	}
+	return c.JSON(http.StatusOK, fromExchangeRates(rates))
+}
+
+// GetExchangeRatesHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin list exchange rates
+//	@Description	Admin list exchange rates, optionally filtered by currency
+//	@Tags			admin
+//	@Param			currency	query	string	false	"Currency code, e.g. USD"
+//	@Produce		json
+//	@Success		200	{object}	ExchangeRates
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/exchange-rates [get]
+func (h *Handler) GetExchangeRatesHandler(c echo.Context) error {
+	currency := exchange.NormaliseCurrency(c.QueryParam("currency"))
+	rates, err := h.store.GetExchangeRates(currency)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting exchange rate", ErrGettingExchangeRate.Error())
+	}
+	return c.JSON(http.StatusOK, fromExchangeRates(rates))
+}
+
+// SetExchangeRatesHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin set exchange rates
+//	@Description	Admin insert or replace exchange rates (THB per one unit of currency) by currency and date
+//	@Tags			admin
+//	@Accept			json
+//	@Param			rates	body	ExchangeRates	true	"Exchange rates to set"
+//	@Produce		json
+//	@Success		200	{object}	ExchangeRates
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/exchange-rates [post]
+func (h *Handler) SetExchangeRatesHandler(c echo.Context) error {
+	var input ExchangeRates
+	if err := c.Bind(&input); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
+	}
+	validate := validator.New()
+	if err := validate.Struct(input); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInputValidation.Error())
+	}
+	return h.saveExchangeRates(c, input.Rates)
+}
+
+// UploadExchangeRatesCSVHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin import exchange rates from csv
+//	@Description	Admin insert or replace exchange rates from a csv file with header currency,date,rate
+//	@Tags			admin
+//	@Accept			multipart/form-data
+//	@Param			rateFile	formData	file	true	"csv file of exchange rates"
+//	@Produce		json
+//	@Success		200	{object}	ExchangeRates
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/exchange-rates/upload-csv [post]
+func (h *Handler) UploadExchangeRatesCSVHandler(c echo.Context) error {
+	file, err := c.FormFile("rateFile")
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "uploading file", ErrUploadingFile.Error())
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "opening file", ErrUploadingFile.Error())
+	}
+	defer src.Close()
+
+	inputs, err := readExchangeRateCSV(src)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading csv file", err.Error())
+	}
+	if len(inputs) == 0 {
+		return h.handleError(c, http.StatusBadRequest, ErrReadingCSV, "reading csv file", ErrReadingCSV.Error())
+	}
+
+	return h.saveExchangeRates(c, inputs)
+}
@@ -0,0 +1,219 @@
+//go:build unit
+
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setupUploadExchangeRates(field, data string) (*httptest.ResponseRecorder, echo.Context, *Handler, *mockAdminStorer) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile(field, "rates.csv")
+	part.Write([]byte(data))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/exchange-rates/upload-csv", body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	mock := NewMockTaxStorer()
+	h := New(mock)
+
+	return rec, c, h, mock
+}
+
+func TestGetExchangeRatesHandler(t *testing.T) {
+	t.Run("filter by currency; expect rates", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/exchange-rates?currency=usd", nil)
+		mock.exchangeRates = []exchange.Rate{
+			{Currency: "USD", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Rate: 35.5},
+		}
+		mock.ExpectToCall(MethodGetExchangeRates)
+
+		// Act
+		err := h.GetExchangeRatesHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "USD", mock.whatIsCurrency)
+		var got ExchangeRates
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ExchangeRates{Rates: []ExchangeRate{{Currency: "USD", Date: "2024-01-15", Rate: 35.5}}}, got)
+	})
+
+	t.Run("GetExchangeRates() error; expect 500", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodGet, "/admin/exchange-rates", nil)
+		mock.err = errors.New("db down")
+
+		// Act
+		err := h.GetExchangeRatesHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrGettingExchangeRate.Error(), got.Message)
+	})
+}
+
+func TestSetExchangeRatesHandler_Success(t *testing.T) {
+	// Arrange
+	input := ExchangeRates{Rates: []ExchangeRate{
+		{Currency: "usd", Date: "2024-01-15", Rate: 35.5},
+		{Currency: "JPY", Date: "2024-01-15", Rate: 0.24},
+	}}
+	rec, c, h, mock := setup(http.MethodPost, "/admin/exchange-rates", input)
+	mock.ExpectToCall(MethodSetExchangeRates)
+
+	// Act
+	err := h.SetExchangeRatesHandler(c)
+
+	// Assert
+	mock.Verify(t)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []exchange.Rate{
+		{Currency: "USD", Date: date, Rate: 35.5},
+		{Currency: "JPY", Date: date, Rate: 0.24},
+	}, mock.exchangeRates)
+	var got ExchangeRates
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+	}
+	assert.Equal(t, "USD", got.Rates[0].Currency)
+}
+
+func TestSetExchangeRatesHandler_Error(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        interface{}
+		storeErr    error
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "empty rates",
+			body:        ExchangeRates{},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInputValidation.Error(),
+		},
+		{
+			name:        "invalid date",
+			body:        ExchangeRates{Rates: []ExchangeRate{{Currency: "USD", Date: "15-01-2024", Rate: 35.5}}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInvalidExchangeRate.Error(),
+		},
+		{
+			name:        "base currency",
+			body:        ExchangeRates{Rates: []ExchangeRate{{Currency: "THB", Date: "2024-01-15", Rate: 1}}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInvalidExchangeRate.Error(),
+		},
+		{
+			name:        "zero rate",
+			body:        ExchangeRates{Rates: []ExchangeRate{{Currency: "USD", Date: "2024-01-15", Rate: 0}}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInvalidExchangeRate.Error(),
+		},
+		{
+			name:        "SetExchangeRates() error",
+			body:        ExchangeRates{Rates: []ExchangeRate{{Currency: "USD", Date: "2024-01-15", Rate: 35.5}}},
+			storeErr:    errors.New("db down"),
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: ErrSettingExchangeRate.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodPost, "/admin/exchange-rates", tc.body)
+			mock.err = tc.storeErr
+
+			// Act
+			err := h.SetExchangeRatesHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			var got Err
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+			}
+			assert.Equal(t, tc.wantMessage, got.Message)
+		})
+	}
+}
+
+func TestUploadExchangeRatesCSVHandler_Success(t *testing.T) {
+	// Arrange
+	data := "currency,date,rate\n"
+	data += "USD,2024-01-15,35.5\n"
+	data += "JPY,2024-01-15,0.24"
+	rec, c, h, mock := setupUploadExchangeRates("rateFile", data)
+	mock.ExpectToCall(MethodSetExchangeRates)
+
+	// Act
+	err := h.UploadExchangeRatesCSVHandler(c)
+
+	// Assert
+	mock.Verify(t)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, mock.exchangeRates, 2)
+}
+
+func TestUploadExchangeRatesCSVHandler_Error(t *testing.T) {
+	testCases := []struct {
+		name        string
+		field       string
+		data        string
+		wantMessage string
+	}{
+		{name: "wrong form field", field: "wrongField", data: "currency,date,rate\nUSD,2024-01-15,35.5", wantMessage: ErrUploadingFile.Error()},
+		{name: "invalid header", field: "rateFile", data: "currency,rate\nUSD,35.5", wantMessage: ErrInvalidCSVHeader.Error()},
+		{name: "header only", field: "rateFile", data: "currency,date,rate\n", wantMessage: ErrReadingCSV.Error()},
+		{name: "rate is not number", field: "rateFile", data: "currency,date,rate\nUSD,2024-01-15,abc", wantMessage: ErrInvalidExchangeRate.Error()},
+		{name: "invalid currency", field: "rateFile", data: "currency,date,rate\nUS,2024-01-15,35.5", wantMessage: ErrInvalidExchangeRate.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, _ := setupUploadExchangeRates(tc.field, tc.data)
+
+			// Act
+			err := h.UploadExchangeRatesCSVHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			var got Err
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+			}
+			assert.Equal(t, tc.wantMessage, got.Message)
+		})
+	}
+}
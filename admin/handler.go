@@ -1,15 +1,29 @@
 package admin
 
 import (
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/golfz/assessment-tax/i18n"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"time"
 )
 
 type Storer interface {
-	SetPersonalDeduction(amount float64) error
-	SetKReceiptDeduction(amount float64) error
+	SetDeduction(name string, amount float64, by deduction.Actor) error
+	SetDeductions(amounts map[string]float64, by deduction.Actor) error
+	CreateSchedules(schedules []deduction.Schedule, by deduction.Actor) ([]deduction.Schedule, error)
+	GetSchedules(name string, status deduction.ScheduleStatus) ([]deduction.Schedule, error)
+	GetSchedule(id int64) (deduction.Schedule, error)
+	UpdateSchedule(id int64, amount float64, effectiveFrom time.Time, by deduction.Actor) (deduction.Schedule, error)
+	CancelSchedule(id int64, by deduction.Actor) (deduction.Schedule, error)
+	GetDeductionSettings() ([]deduction.Setting, error)
+	GetDeductionSetting(name string) (deduction.Setting, error)
+	GetDeductionChanges(filter deduction.ChangeFilter) ([]deduction.Change, int, error)
+	GetExchangeRates(currency string) ([]exchange.Rate, error)
+	SetExchangeRates(rates []exchange.Rate) error
 }
 
 type Handler struct {
@@ -24,18 +38,6 @@ type Err struct {
 	Message string `json:"message"`
 }
 
-type ValidatorFunc func(float64) error
-type SetterFunc func(float64) error
-type OutputFunc func(Deduction) interface{}
-
-func outputToPersonalDeduction(input Deduction) interface{} {
-	return PersonalDeduction(input)
-}
-
-func outputToKReceiptDeduction(input Deduction) interface{} {
-	return KReceiptDeduction(input)
-}
-
 func (h *Handler) validateInput(c echo.Context, input *Deduction) (err error) {
 	err = c.Bind(input)
 	if err != nil {
@@ -50,21 +52,37 @@ func (h *Handler) validateInput(c echo.Context, input *Deduction) (err error) {
 
 func (h *Handler) handleError(c echo.Context, errStatus int, err error, action, errMsg string) error {
 	c.Logger().Printf("error %s: %v", action, err)
-	return c.JSON(errStatus, Err{Message: errMsg})
+	return c.JSON(errStatus, Err{Message: messages.Translate(i18n.FromRequest(c.Request()), errMsg)})
 }
 
-func (h *Handler) processDeduction(c echo.Context, validateDeduction ValidatorFunc, setDeduction SetterFunc, output OutputFunc) error {
+func (h *Handler) processDeduction(c echo.Context, name string) error {
+	def, ok := deduction.DefinitionOf(name)
+	if !ok {
+		return h.handleError(c, http.StatusNotFound, deduction.ErrDeductionNotFound, "setting deduction", ErrDeductionNotFound.Error())
+	}
+
 	var input Deduction
 	if err := h.validateInput(c, &input); err != nil {
 		return h.handleError(c, http.StatusBadRequest, err, "reading request body", err.Error())
 	}
-	if err := validateDeduction(input.Deduction); err != nil {
+	if err := def.Validate(input.Deduction); err != nil {
 		return h.handleError(c, http.StatusBadRequest, err, "validating deduction", ErrInvalidInputDeduction.Error())
 	}
-	if err := setDeduction(input.Deduction); err != nil {
+	if input.EffectiveFrom != nil {
+		schedules, err := h.createSchedules(c, map[string]float64{name: input.Deduction}, *input.EffectiveFrom)
+		if err != nil {
+			return h.handleScheduleError(c, err)
+		}
+		return c.JSON(http.StatusAccepted, schedules[0])
+	}
+	err := h.store.SetDeduction(name, input.Deduction, actorOf(c))
+	if errors.Is(err, deduction.ErrDeductionNotFound) {
+		return h.handleError(c, http.StatusNotFound, err, "setting deduction", ErrDeductionNotFound.Error())
+	}
+	if err != nil {
 		return h.handleError(c, http.StatusInternalServerError, err, "setting deduction", ErrSettingDeduction.Error())
 	}
-	return c.JSON(http.StatusOK, output(input))
+	return c.JSON(http.StatusOK, map[string]float64{def.Key: input.Deduction})
 }
 
 // SetPersonalDeductionHandler
@@ -77,12 +95,13 @@ func (h *Handler) processDeduction(c echo.Context, validateDeduction ValidatorFu
 //	@Param			amount	body	Deduction	true	"Amount to set personal deduction"
 //	@Produce		json
 //	@Success		200	{object}	PersonalDeduction
+//	@Success		202	{object}	DeductionSchedule
 //	@Failure		400	{object}	Err
 //	@Failure		401	{object}	Err
 //	@Failure		500	{object}	Err
 //	@Router			/admin/deductions/personal [post]
 func (h *Handler) SetPersonalDeductionHandler(c echo.Context) error {
-	return h.processDeduction(c, deduction.ValidatePersonalDeduction, h.store.SetPersonalDeduction, outputToPersonalDeduction)
+	return h.processDeduction(c, deduction.NamePersonal)
 }
 
 // SetKReceiptDeductionHandler
@@ -95,10 +114,52 @@ func (h *Handler) SetPersonalDeductionHandler(c echo.Context) error {
 //	@Param			amount	body	Deduction	true	"Amount to set personal deduction"
 //	@Produce		json
 //	@Success		200	{object}	KReceiptDeduction
+//	@Success		202	{object}	DeductionSchedule
 //	@Failure		400	{object}	Err
 //	@Failure		401	{object}	Err
 //	@Failure		500	{object}	Err
 //	@Router			/admin/deductions/k-receipt [post]
 func (h *Handler) SetKReceiptDeductionHandler(c echo.Context) error {
-	return h.processDeduction(c, deduction.ValidateKReceiptDeduction, h.store.SetKReceiptDeduction, outputToKReceiptDeduction)
+	return h.processDeduction(c, deduction.NameKReceipt)
+}
+
+// SetDonationDeductionHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin set donation deduction
+//	@Description	Admin set the maximum donation deduction
+//	@Tags			admin
+//	@Accept			json
+//	@Param			amount	body	Deduction	true	"Amount to set donation deduction"
+//	@Produce		json
+//	@Success		200	{object}	DonationDeduction
+//	@Success		202	{object}	DeductionSchedule
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/donation [post]
+func (h *Handler) SetDonationDeductionHandler(c echo.Context) error {
+	return h.processDeduction(c, deduction.NameDonation)
+}
+
+// SetDeductionHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin set a deduction by name
+//	@Description	Admin set any deduction by name, checked against the range of that deduction. The response is the same as the POST endpoint of the deduction.
+//	@Description	With an effectiveFrom in the future the change is scheduled instead, and the schedule is returned with 202.
+//	@Tags			admin
+//	@Accept			json
+//	@Param			name	path	string		true	"Deduction name"	Enums(personal, k-receipt, donation)
+//	@Param			amount	body	Deduction	true	"Amount to set the deduction"
+//	@Produce		json
+//	@Success		200	{object}	object
+//	@Success		202	{object}	DeductionSchedule
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/{name} [put]
+func (h *Handler) SetDeductionHandler(c echo.Context) error {
+	return h.processDeduction(c, c.Param("name"))
 }
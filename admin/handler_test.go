@@ -5,6 +5,9 @@ package admin
 import (
 	"encoding/json"
 	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/golfz/assessment-tax/i18n"
+	mw "github.com/golfz/assessment-tax/middleware"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -12,17 +15,38 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
-	MethodSetPersonalDeduction = "SetPersonalDeduction"
-	MethodSetKReceiptDeduction = "SetKReceiptDeduction"
+	MethodSetDeduction         = "SetDeduction"
+	MethodSetDeductions        = "SetDeductions"
+	MethodGetDeductionChanges  = "GetDeductionChanges"
+	MethodGetExchangeRates     = "GetExchangeRates"
+	MethodGetDeductionSettings = "GetDeductionSettings"
+	MethodGetDeductionSetting  = "GetDeductionSetting"
+	MethodSetExchangeRates     = "SetExchangeRates"
+	MethodCreateSchedules      = "CreateSchedules"
+	MethodGetSchedules         = "GetSchedules"
+	MethodUpdateSchedule       = "UpdateSchedule"
+	MethodCancelSchedule       = "CancelSchedule"
 )
 
 type mockAdminStorer struct {
-	err          error
-	methodToCall map[string]bool
-	whatIsAmount float64
+	err            error
+	methodToCall   map[string]bool
+	whatIsAmount   float64
+	whatIsActor    deduction.Actor
+	whatIsFilter   deduction.ChangeFilter
+	whatAreAmounts map[string]float64
+	whatIsName     string
+	whatIsCurrency string
+	exchangeRates  []exchange.Rate
+	settings       []deduction.Setting
+	changes        []deduction.Change
+	whatIsStatus   deduction.ScheduleStatus
+	whatIsID       int64
+	schedules      []deduction.Schedule
 }
 
 func NewMockTaxStorer() *mockAdminStorer {
@@ -31,15 +55,109 @@ func NewMockTaxStorer() *mockAdminStorer {
 	}
 }
 
-func (m *mockAdminStorer) SetPersonalDeduction(amount float64) error {
-	m.methodToCall[MethodSetPersonalDeduction] = true
+func (m *mockAdminStorer) SetDeduction(name string, amount float64, by deduction.Actor) error {
+	m.methodToCall[MethodSetDeduction] = true
+	m.whatIsName = name
 	m.whatIsAmount = amount
+	m.whatIsActor = by
 	return m.err
 }
 
-func (m *mockAdminStorer) SetKReceiptDeduction(amount float64) error {
-	m.methodToCall[MethodSetKReceiptDeduction] = true
+func (m *mockAdminStorer) SetDeductions(amounts map[string]float64, by deduction.Actor) error {
+	m.methodToCall[MethodSetDeductions] = true
+	m.whatAreAmounts = amounts
+	m.whatIsActor = by
+	return m.err
+}
+
+func (m *mockAdminStorer) CreateSchedules(schedules []deduction.Schedule, by deduction.Actor) ([]deduction.Schedule, error) {
+	m.methodToCall[MethodCreateSchedules] = true
+	m.schedules = schedules
+	m.whatIsActor = by
+	if m.err != nil {
+		return nil, m.err
+	}
+	return schedules, nil
+}
+
+func (m *mockAdminStorer) GetSchedules(name string, status deduction.ScheduleStatus) ([]deduction.Schedule, error) {
+	m.methodToCall[MethodGetSchedules] = true
+	m.whatIsName = name
+	m.whatIsStatus = status
+	return m.schedules, m.err
+}
+
+func (m *mockAdminStorer) GetSchedule(id int64) (deduction.Schedule, error) {
+	for _, s := range m.schedules {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return deduction.Schedule{}, deduction.ErrScheduleNotFound
+}
+
+func (m *mockAdminStorer) UpdateSchedule(id int64, amount float64, effectiveFrom time.Time, by deduction.Actor) (deduction.Schedule, error) {
+	m.methodToCall[MethodUpdateSchedule] = true
+	m.whatIsID = id
 	m.whatIsAmount = amount
+	m.whatIsActor = by
+	s, err := m.GetSchedule(id)
+	if err != nil {
+		return deduction.Schedule{}, err
+	}
+	s.Amount = amount
+	s.EffectiveFrom = effectiveFrom
+	return s, m.err
+}
+
+func (m *mockAdminStorer) CancelSchedule(id int64, by deduction.Actor) (deduction.Schedule, error) {
+	m.methodToCall[MethodCancelSchedule] = true
+	m.whatIsID = id
+	m.whatIsActor = by
+	s, err := m.GetSchedule(id)
+	if err != nil {
+		return deduction.Schedule{}, err
+	}
+	now := time.Now()
+	s.CancelledBy = by.Username
+	s.CancelledAt = &now
+	return s, m.err
+}
+
+func (m *mockAdminStorer) GetDeductionSettings() ([]deduction.Setting, error) {
+	m.methodToCall[MethodGetDeductionSettings] = true
+	return m.settings, m.err
+}
+
+func (m *mockAdminStorer) GetDeductionSetting(name string) (deduction.Setting, error) {
+	m.methodToCall[MethodGetDeductionSetting] = true
+	m.whatIsName = name
+	for _, s := range m.settings {
+		if s.Name == name {
+			return s, m.err
+		}
+	}
+	if m.err != nil {
+		return deduction.Setting{}, m.err
+	}
+	return deduction.Setting{}, deduction.ErrDeductionNotFound
+}
+
+func (m *mockAdminStorer) GetDeductionChanges(filter deduction.ChangeFilter) ([]deduction.Change, int, error) {
+	m.methodToCall[MethodGetDeductionChanges] = true
+	m.whatIsFilter = filter
+	return m.changes, len(m.changes), m.err
+}
+
+func (m *mockAdminStorer) GetExchangeRates(currency string) ([]exchange.Rate, error) {
+	m.methodToCall[MethodGetExchangeRates] = true
+	m.whatIsCurrency = currency
+	return m.exchangeRates, m.err
+}
+
+func (m *mockAdminStorer) SetExchangeRates(rates []exchange.Rate) error {
+	m.methodToCall[MethodSetExchangeRates] = true
+	m.exchangeRates = rates
 	return m.err
 }
 
@@ -99,7 +217,8 @@ func TestSetPersonalDeductionHandler_Success(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
 			rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/personal", Deduction{Deduction: tc.amount})
-			mock.ExpectToCall(MethodSetPersonalDeduction)
+			c.Set(mw.ContextKeyUsername, "admin")
+			mock.ExpectToCall(MethodSetDeduction)
 
 			// Act
 			err := h.SetPersonalDeductionHandler(c)
@@ -107,6 +226,8 @@ func TestSetPersonalDeductionHandler_Success(t *testing.T) {
 			// Assert
 			mock.Verify(t)
 			assert.Equal(t, tc.amount, mock.whatIsAmount)
+			assert.Equal(t, deduction.NamePersonal, mock.whatIsName)
+			assert.Equal(t, "admin", mock.whatIsActor.Username)
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, rec.Code)
 			var got PersonalDeduction
@@ -179,6 +300,38 @@ func TestSetPersonalDeductionHandler_Error(t *testing.T) {
 	})
 }
 
+func TestSetPersonalDeductionHandler_AcceptLanguage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		wantMessage    string
+	}{
+		{name: "no header; expect english message", acceptLanguage: "", wantMessage: ErrInvalidInputDeduction.Error()},
+		{name: "english", acceptLanguage: "en", wantMessage: ErrInvalidInputDeduction.Error()},
+		{name: "thai", acceptLanguage: "th-TH", wantMessage: "ค่าลดหย่อนที่ส่งมาไม่ถูกต้อง"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, _ := setup(http.MethodPost, "/admin/deductions/personal", Deduction{Deduction: deduction.MaxPersonalDeduction + 1})
+			c.Request().Header.Set(i18n.HeaderAcceptLanguage, tc.acceptLanguage)
+
+			// Act
+			err := h.SetPersonalDeductionHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			var got Err
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+			}
+			assert.Equal(t, tc.wantMessage, got.Message)
+		})
+	}
+}
+
 func TestSetPersonalDeductionHandler_ValidateAmount_Error(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -242,7 +395,8 @@ func TestSetKReceiptDeductionHandler_Success(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
 			rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/k-receipt", Deduction{Deduction: tc.amount})
-			mock.ExpectToCall(MethodSetKReceiptDeduction)
+			c.Set(mw.ContextKeyUsername, "admin")
+			mock.ExpectToCall(MethodSetDeduction)
 
 			// Act
 			err := h.SetKReceiptDeductionHandler(c)
@@ -250,6 +404,8 @@ func TestSetKReceiptDeductionHandler_Success(t *testing.T) {
 			// Assert
 			mock.Verify(t)
 			assert.Equal(t, tc.amount, mock.whatIsAmount)
+			assert.Equal(t, deduction.NameKReceipt, mock.whatIsName)
+			assert.Equal(t, "admin", mock.whatIsActor.Username)
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, rec.Code)
 			var got KReceiptDeduction
@@ -365,3 +521,89 @@ func TestSetKReceiptDeductionHandler_ValidateAmount_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestSetDonationDeductionHandler(t *testing.T) {
+	t.Run("maximum donation deduction; expect set", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/donation", Deduction{Deduction: deduction.MaxDonationDeduction})
+		mock.ExpectToCall(MethodSetDeduction)
+
+		// Act
+		err := h.SetDonationDeductionHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, deduction.NameDonation, mock.whatIsName)
+		assert.JSONEq(t, `{"donation":100000}`, rec.Body.String())
+	})
+
+	t.Run("above maximum donation deduction; expect 400", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/donation", Deduction{Deduction: deduction.MaxDonationDeduction + 1})
+
+		// Act
+		err := h.SetDonationDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrInvalidInputDeduction.Error()+`"}`, rec.Body.String())
+		assert.NotContains(t, mock.methodToCall, MethodSetDeduction)
+	})
+}
+
+func TestSetDeductionHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		deduction  string
+		amount     float64
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "personal", deduction: deduction.NamePersonal, amount: 70_000, wantStatus: http.StatusOK, wantBody: `{"personalDeduction":70000}`},
+		{name: "k-receipt", deduction: deduction.NameKReceipt, amount: 70_000, wantStatus: http.StatusOK, wantBody: `{"kReceipt":70000}`},
+		{name: "donation", deduction: deduction.NameDonation, amount: 70_000, wantStatus: http.StatusOK, wantBody: `{"donation":70000}`},
+		{name: "invalid amount", deduction: deduction.NamePersonal, amount: deduction.MinPersonalDeduction, wantStatus: http.StatusBadRequest, wantBody: `{"message":"` + ErrInvalidInputDeduction.Error() + `"}`},
+		{name: "unknown deduction", deduction: "unknown", amount: 1, wantStatus: http.StatusNotFound, wantBody: `{"message":"` + ErrDeductionNotFound.Error() + `"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodPut, "/admin/deductions/"+tc.deduction, Deduction{Deduction: tc.amount})
+			c.SetParamNames("name")
+			c.SetParamValues(tc.deduction)
+			c.Set(mw.ContextKeyUsername, "admin")
+
+			// Act
+			err := h.SetDeductionHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			assert.JSONEq(t, tc.wantBody, rec.Body.String())
+			if tc.wantStatus == http.StatusOK {
+				assert.Equal(t, tc.deduction, mock.whatIsName)
+				assert.Equal(t, tc.amount, mock.whatIsAmount)
+				assert.Equal(t, "admin", mock.whatIsActor.Username)
+			}
+		})
+	}
+
+	t.Run("deduction missing from store; expect 404", func(t *testing.T) {
+		// Arrange
+		rec, c, h, mock := setup(http.MethodPut, "/admin/deductions/donation", Deduction{Deduction: 1})
+		c.SetParamNames("name")
+		c.SetParamValues(deduction.NameDonation)
+		mock.err = deduction.ErrDeductionNotFound
+
+		// Act
+		err := h.SetDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,32 @@
+package admin
+
+import "github.com/golfz/assessment-tax/i18n"
+
+var messages = i18n.Catalogue{
+	i18n.LanguageThai: {
+		ErrReadingRequestBody.Error():    "ไม่สามารถอ่านข้อมูลที่ส่งมาได้",
+		ErrInputValidation.Error():       "ข้อมูลที่ส่งมาไม่ถูกต้อง",
+		ErrInvalidInputDeduction.Error(): "ค่าลดหย่อนที่ส่งมาไม่ถูกต้อง",
+		ErrSettingDeduction.Error():      "เกิดข้อผิดพลาดในการตั้งค่าลดหย่อน",
+		ErrGettingDeduction.Error():      "เกิดข้อผิดพลาดในการดึงค่าลดหย่อน",
+		ErrDeductionNotFound.Error():     "ไม่พบค่าลดหย่อนที่ระบุ",
+		ErrUnknownDeduction.Error():      "ไม่รู้จักค่าลดหย่อนที่ส่งมา",
+
+		ErrInvalidHistoryFilter.Error():    "เงื่อนไขการค้นหาประวัติไม่ถูกต้อง",
+		ErrGettingDeductionHistory.Error(): "เกิดข้อผิดพลาดในการดึงประวัติการแก้ไขค่าลดหย่อน",
+
+		ErrInvalidEffectiveFrom.Error(): "เวลาที่มีผลต้องเป็นเวลาในอนาคต",
+		ErrScheduleNotFound.Error():     "ไม่พบการตั้งเวลาเปลี่ยนค่าลดหย่อนที่ระบุ",
+		ErrScheduleNotPending.Error():   "การตั้งเวลาเปลี่ยนค่าลดหย่อนนี้มีผลแล้วหรือถูกยกเลิกแล้ว",
+		ErrGettingSchedule.Error():      "เกิดข้อผิดพลาดในการดึงการตั้งเวลาเปลี่ยนค่าลดหย่อน",
+		ErrSettingSchedule.Error():      "เกิดข้อผิดพลาดในการบันทึกการตั้งเวลาเปลี่ยนค่าลดหย่อน",
+
+		ErrInvalidExchangeRate.Error(): "อัตราแลกเปลี่ยนไม่ถูกต้อง",
+		ErrGettingExchangeRate.Error(): "เกิดข้อผิดพลาดในการดึงอัตราแลกเปลี่ยน",
+		ErrSettingExchangeRate.Error(): "เกิดข้อผิดพลาดในการบันทึกอัตราแลกเปลี่ยน",
+
+		ErrUploadingFile.Error():    "ไม่สามารถอัปโหลดไฟล์ได้",
+		ErrReadingCSV.Error():       "ไม่สามารถอ่านไฟล์ csv ได้",
+		ErrInvalidCSVHeader.Error(): "หัวคอลัมน์ของไฟล์ csv ไม่ถูกต้อง",
+	},
+}
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func toDeductionSchedule(s deduction.Schedule, now time.Time) DeductionSchedule {
+	return DeductionSchedule{
+		ID:            s.ID,
+		Name:          s.Name,
+		Amount:        s.Amount,
+		EffectiveFrom: s.EffectiveFrom,
+		Status:        string(s.Status(now)),
+		CreatedBy:     s.CreatedBy,
+		CreatedAt:     s.CreatedAt,
+		UpdatedBy:     s.UpdatedBy,
+		UpdatedAt:     s.UpdatedAt,
+		CancelledBy:   s.CancelledBy,
+		CancelledAt:   s.CancelledAt,
+	}
+}
+
+func toDeductionSchedules(schedules []deduction.Schedule) DeductionSchedules {
+	now := time.Now()
+	result := DeductionSchedules{Schedules: make([]DeductionSchedule, 0, len(schedules))}
+	for _, s := range schedules {
+		result.Schedules = append(result.Schedules, toDeductionSchedule(s, now))
+	}
+	return result
+}
+
+// createSchedules schedules amounts, already validated, to take effect
+// together at effectiveFrom.
+func (h *Handler) createSchedules(c echo.Context, amounts map[string]float64, effectiveFrom time.Time) ([]DeductionSchedule, error) {
+	if !effectiveFrom.After(time.Now()) {
+		return nil, ErrInvalidEffectiveFrom
+	}
+
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	schedules := make([]deduction.Schedule, 0, len(names))
+	for _, name := range names {
+		schedules = append(schedules, deduction.Schedule{Name: name, Amount: amounts[name], EffectiveFrom: effectiveFrom})
+	}
+
+	created, err := h.store.CreateSchedules(schedules, actorOf(c))
+	if err != nil {
+		return nil, err
+	}
+	return toDeductionSchedules(created).Schedules, nil
+}
+
+func (h *Handler) handleScheduleError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidEffectiveFrom):
+		return h.handleError(c, http.StatusBadRequest, err, "validating effective time", ErrInvalidEffectiveFrom.Error())
+	case errors.Is(err, deduction.ErrDeductionNotFound):
+		return h.handleError(c, http.StatusNotFound, err, "setting scheduled deduction", ErrDeductionNotFound.Error())
+	case errors.Is(err, deduction.ErrScheduleNotFound):
+		return h.handleError(c, http.StatusNotFound, err, "setting scheduled deduction", ErrScheduleNotFound.Error())
+	case errors.Is(err, deduction.ErrScheduleNotPending):
+		return h.handleError(c, http.StatusConflict, err, "setting scheduled deduction", ErrScheduleNotPending.Error())
+	default:
+		return h.handleError(c, http.StatusInternalServerError, err, "setting scheduled deduction", ErrSettingSchedule.Error())
+	}
+}
+
+func scheduleID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, deduction.ErrScheduleNotFound
+	}
+	return id, nil
+}
+
+// GetDeductionSchedulesHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin list scheduled deduction changes
+//	@Description	Admin list deduction changes set in advance, in the order they take effect. A schedule is pending until its effective time, then applied, unless it was cancelled.
+//	@Tags			admin
+//	@Param			name	query	string	false	"Deduction name"
+//	@Param			status	query	string	false	"Status of the schedules"	Enums(pending, applied, cancelled, all)	default(pending)
+//	@Produce		json
+//	@Success		200	{object}	DeductionSchedules
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/schedules [get]
+func (h *Handler) GetDeductionSchedulesHandler(c echo.Context) error {
+	var status deduction.ScheduleStatus
+	switch s := deduction.ScheduleStatus(c.QueryParam("status")); s {
+	case "":
+		status = deduction.SchedulePending
+	case "all":
+	case deduction.SchedulePending, deduction.ScheduleApplied, deduction.ScheduleCancelled:
+		status = s
+	default:
+		return h.handleError(c, http.StatusBadRequest, errors.New("unknown status "+string(s)), "reading schedule filter", ErrInputValidation.Error())
+	}
+
+	schedules, err := h.store.GetSchedules(c.QueryParam("name"), status)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting scheduled deduction", ErrGettingSchedule.Error())
+	}
+	return c.JSON(http.StatusOK, toDeductionSchedules(schedules))
+}
+
+// UpdateDeductionScheduleHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin edit a pending deduction change
+//	@Description	Admin change the amount and effective time of a schedule that has not taken effect yet. The amount is checked against the range of the deduction.
+//	@Tags			admin
+//	@Accept			json
+//	@Param			id			path	int				true	"Schedule id"
+//	@Param			schedule	body	ScheduleUpdate	true	"New amount and effective time"
+//	@Produce		json
+//	@Success		200	{object}	DeductionSchedule
+//	@Failure		400	{object}	Err
+//	@Failure		401	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/schedules/{id} [put]
+func (h *Handler) UpdateDeductionScheduleHandler(c echo.Context) error {
+	id, err := scheduleID(c)
+	if err != nil {
+		return h.handleScheduleError(c, err)
+	}
+
+	var input ScheduleUpdate
+	if err := c.Bind(&input); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
+	}
+	if err := validator.New().Struct(input); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInputValidation.Error())
+	}
+	if !input.EffectiveFrom.After(time.Now()) {
+		return h.handleScheduleError(c, ErrInvalidEffectiveFrom)
+	}
+
+	current, err := h.store.GetSchedule(id)
+	if err != nil {
+		return h.handleScheduleError(c, err)
+	}
+	if def, ok := deduction.DefinitionOf(current.Name); ok {
+		if err := def.Validate(input.Amount); err != nil {
+			return h.handleError(c, http.StatusBadRequest, err, "validating deduction", ErrInvalidInputDeduction.Error())
+		}
+	}
+
+	updated, err := h.store.UpdateSchedule(id, input.Amount, input.EffectiveFrom, actorOf(c))
+	if err != nil {
+		return h.handleScheduleError(c, err)
+	}
+	return c.JSON(http.StatusOK, toDeductionSchedule(updated, time.Now()))
+}
+
+// CancelDeductionScheduleHandler
+//
+//	@Security		BasicAuth
+//	@Summary		Admin cancel a pending deduction change
+//	@Description	Admin cancel a schedule that has not taken effect yet. The schedule is kept, marked as cancelled.
+//	@Tags			admin
+//	@Param			id	path	int	true	"Schedule id"
+//	@Produce		json
+//	@Success		200	{object}	DeductionSchedule
+//	@Failure		401	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/admin/deductions/schedules/{id} [delete]
+func (h *Handler) CancelDeductionScheduleHandler(c echo.Context) error {
+	id, err := scheduleID(c)
+	if err != nil {
+		return h.handleScheduleError(c, err)
+	}
+
+	cancelled, err := h.store.CancelSchedule(id, actorOf(c))
+	if err != nil {
+		return h.handleScheduleError(c, err)
+	}
+	return c.JSON(http.StatusOK, toDeductionSchedule(cancelled, time.Now()))
+}
@@ -0,0 +1,276 @@
+//go:build unit
+
+package admin
+
+import (
+	"encoding/json"
+	"github.com/golfz/assessment-tax/deduction"
+	mw "github.com/golfz/assessment-tax/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetDeductionHandler_EffectiveFrom(t *testing.T) {
+	t.Run("future effective time; expect change scheduled", func(t *testing.T) {
+		// Arrange
+		effectiveFrom := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		rec, c, h, mock := setup(http.MethodPut, "/admin/deductions/personal", Deduction{Deduction: 70_000, EffectiveFrom: &effectiveFrom})
+		c.SetParamNames("name")
+		c.SetParamValues(deduction.NamePersonal)
+		c.Set(mw.ContextKeyUsername, "admin")
+		mock.ExpectToCall(MethodCreateSchedules)
+
+		// Act
+		err := h.SetDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		mock.Verify(t)
+		assert.NotContains(t, mock.methodToCall, MethodSetDeduction)
+		assert.Equal(t, []deduction.Schedule{{Name: deduction.NamePersonal, Amount: 70_000, EffectiveFrom: effectiveFrom}}, mock.schedules)
+		assert.Equal(t, "admin", mock.whatIsActor.Username)
+		var got DeductionSchedule
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, string(deduction.SchedulePending), got.Status)
+		assert.Equal(t, 70_000.0, got.Amount)
+	})
+
+	t.Run("past effective time; expect 400", func(t *testing.T) {
+		// Arrange
+		effectiveFrom := time.Now().Add(-time.Hour)
+		rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/personal", Deduction{Deduction: 70_000, EffectiveFrom: &effectiveFrom})
+
+		// Act
+		err := h.SetPersonalDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrInvalidEffectiveFrom.Error()+`"}`, rec.Body.String())
+		assert.NotContains(t, mock.methodToCall, MethodCreateSchedules)
+	})
+
+	t.Run("invalid amount; expect 400 and nothing scheduled", func(t *testing.T) {
+		// Arrange
+		effectiveFrom := time.Now().Add(time.Hour)
+		rec, c, h, mock := setup(http.MethodPost, "/admin/deductions/personal", Deduction{Deduction: deduction.MaxPersonalDeduction + 1, EffectiveFrom: &effectiveFrom})
+
+		// Act
+		err := h.SetPersonalDeductionHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NotContains(t, mock.methodToCall, MethodCreateSchedules)
+	})
+}
+
+func TestUpdateDeductionsHandler_EffectiveFrom(t *testing.T) {
+	t.Run("future effective time; expect all amounts scheduled together", func(t *testing.T) {
+		// Arrange
+		effectiveFrom := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		body := map[string]any{deduction.NamePersonal: 70_000, deduction.NameDonation: 90_000, "effectiveFrom": effectiveFrom}
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", body)
+		mock.ExpectToCall(MethodCreateSchedules)
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		mock.Verify(t)
+		assert.NotContains(t, mock.methodToCall, MethodSetDeductions)
+		assert.Equal(t, []deduction.Schedule{
+			{Name: deduction.NameDonation, Amount: 90_000, EffectiveFrom: effectiveFrom},
+			{Name: deduction.NamePersonal, Amount: 70_000, EffectiveFrom: effectiveFrom},
+		}, mock.schedules)
+		var got DeductionSchedules
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Len(t, got.Schedules, 2)
+	})
+
+	t.Run("past effective time; expect 400", func(t *testing.T) {
+		// Arrange
+		body := map[string]any{deduction.NamePersonal: 70_000, "effectiveFrom": time.Now().Add(-time.Hour)}
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", body)
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrInvalidEffectiveFrom.Error()+`"}`, rec.Body.String())
+		assert.Empty(t, mock.methodToCall)
+	})
+
+	t.Run("effective time not a time; expect 400", func(t *testing.T) {
+		// Arrange
+		body := map[string]any{deduction.NamePersonal: 70_000, "effectiveFrom": "tomorrow"}
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", body)
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"`+ErrReadingRequestBody.Error()+`"}`, rec.Body.String())
+		assert.Empty(t, mock.methodToCall)
+	})
+
+	t.Run("effective time as a query param; expect ignored and amounts set now", func(t *testing.T) {
+		// Arrange
+		effectiveFrom := time.Now().Add(time.Hour)
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions?effectiveFrom="+effectiveFrom.Format(time.RFC3339), DeductionAmounts{deduction.NamePersonal: 70_000})
+		mock.ExpectToCall(MethodSetDeductions)
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mock.Verify(t)
+		assert.NotContains(t, mock.methodToCall, MethodCreateSchedules)
+	})
+
+	t.Run("deduction missing from store; expect 404", func(t *testing.T) {
+		// Arrange
+		body := map[string]any{deduction.NamePersonal: 70_000, "effectiveFrom": time.Now().Add(time.Hour)}
+		rec, c, h, mock := setup(http.MethodPatch, "/admin/deductions", body)
+		mock.err = deduction.ErrDeductionNotFound
+
+		// Act
+		err := h.UpdateDeductionsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestGetDeductionSchedulesHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFilter deduction.ScheduleStatus
+	}{
+		{name: "default to pending", query: "", wantStatus: http.StatusOK, wantFilter: deduction.SchedulePending},
+		{name: "cancelled", query: "?status=cancelled", wantStatus: http.StatusOK, wantFilter: deduction.ScheduleCancelled},
+		{name: "all", query: "?status=all", wantStatus: http.StatusOK, wantFilter: ""},
+		{name: "unknown status", query: "?status=unknown", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodGet, "/admin/deductions/schedules"+tc.query, nil)
+			mock.schedules = []deduction.Schedule{{ID: 1, Name: deduction.NamePersonal, Amount: 70_000, EffectiveFrom: time.Now().Add(time.Hour)}}
+
+			// Act
+			err := h.GetDeductionSchedulesHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if tc.wantStatus == http.StatusOK {
+				assert.Equal(t, tc.wantFilter, mock.whatIsStatus)
+				var got DeductionSchedules
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+				assert.Len(t, got.Schedules, 1)
+				assert.Equal(t, string(deduction.SchedulePending), got.Schedules[0].Status)
+			}
+		})
+	}
+}
+
+func TestUpdateDeductionScheduleHandler(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	testCases := []struct {
+		name       string
+		id         string
+		input      ScheduleUpdate
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{name: "pending schedule; expect updated", id: "1", input: ScheduleUpdate{Amount: 75_000, EffectiveFrom: future}, wantStatus: http.StatusOK},
+		{name: "amount out of range", id: "1", input: ScheduleUpdate{Amount: deduction.MaxPersonalDeduction + 1, EffectiveFrom: future}, wantStatus: http.StatusBadRequest, wantMsg: ErrInvalidInputDeduction.Error()},
+		{name: "past effective time", id: "1", input: ScheduleUpdate{Amount: 75_000, EffectiveFrom: time.Now().Add(-time.Hour)}, wantStatus: http.StatusBadRequest, wantMsg: ErrInvalidEffectiveFrom.Error()},
+		{name: "schedule no longer pending", id: "1", input: ScheduleUpdate{Amount: 75_000, EffectiveFrom: future}, err: deduction.ErrScheduleNotPending, wantStatus: http.StatusConflict, wantMsg: ErrScheduleNotPending.Error()},
+		{name: "schedule not found", id: "2", input: ScheduleUpdate{Amount: 75_000, EffectiveFrom: future}, wantStatus: http.StatusNotFound, wantMsg: ErrScheduleNotFound.Error()},
+		{name: "invalid id", id: "x", input: ScheduleUpdate{Amount: 75_000, EffectiveFrom: future}, wantStatus: http.StatusNotFound, wantMsg: ErrScheduleNotFound.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodPut, "/admin/deductions/schedules/"+tc.id, tc.input)
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+			c.Set(mw.ContextKeyUsername, "admin")
+			mock.schedules = []deduction.Schedule{{ID: 1, Name: deduction.NamePersonal, Amount: 70_000, EffectiveFrom: future}}
+			mock.err = tc.err
+
+			// Act
+			err := h.UpdateDeductionScheduleHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if tc.wantMsg != "" {
+				assert.JSONEq(t, `{"message":"`+tc.wantMsg+`"}`, rec.Body.String())
+				return
+			}
+			assert.Equal(t, int64(1), mock.whatIsID)
+			assert.Equal(t, "admin", mock.whatIsActor.Username)
+			var got DeductionSchedule
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, 75_000.0, got.Amount)
+		})
+	}
+}
+
+func TestCancelDeductionScheduleHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		id         string
+		err        error
+		wantStatus int
+	}{
+		{name: "pending schedule; expect cancelled", id: "1", wantStatus: http.StatusOK},
+		{name: "schedule no longer pending", id: "1", err: deduction.ErrScheduleNotPending, wantStatus: http.StatusConflict},
+		{name: "schedule not found", id: "2", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			rec, c, h, mock := setup(http.MethodDelete, "/admin/deductions/schedules/"+tc.id, nil)
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+			c.Set(mw.ContextKeyUsername, "admin")
+			mock.schedules = []deduction.Schedule{{ID: 1, Name: deduction.NamePersonal, Amount: 70_000, EffectiveFrom: time.Now().Add(time.Hour)}}
+			mock.err = tc.err
+
+			// Act
+			err := h.CancelDeductionScheduleHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if tc.wantStatus == http.StatusOK {
+				var got DeductionSchedule
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+				assert.Equal(t, string(deduction.ScheduleCancelled), got.Status)
+				assert.Equal(t, "admin", got.CancelledBy)
+			}
+		})
+	}
+}
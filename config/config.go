@@ -16,6 +16,33 @@ const (
 
 	kAdminPassword       = "ADMIN_PASSWORD"
 	defaultAdminPassword = ""
+
+	kCSVMaxBufferedRows       = "CSV_MAX_BUFFERED_ROWS"
+	defaultCSVMaxBufferedRows = 10_000
+
+	kBatchWorkers       = "BATCH_WORKERS"
+	defaultBatchWorkers = 2
+
+	kCalculationWorkers       = "CALCULATION_WORKERS"
+	defaultCalculationWorkers = 0
+
+	kCalculationBatchMaxItems       = "CALCULATION_BATCH_MAX_ITEMS"
+	defaultCalculationBatchMaxItems = 1_000
+
+	kZipMaxEntries       = "ZIP_MAX_ENTRIES"
+	defaultZipMaxEntries = 100
+
+	kZipMaxUncompressedBytes       = "ZIP_MAX_UNCOMPRESSED_BYTES"
+	defaultZipMaxUncompressedBytes = 100 * 1024 * 1024
+
+	kBatchMaxUploadBytes       = "BATCH_MAX_UPLOAD_BYTES"
+	defaultBatchMaxUploadBytes = 200 * 1024 * 1024
+
+	kXLSXMaxUncompressedBytes       = "XLSX_MAX_UNCOMPRESSED_BYTES"
+	defaultXLSXMaxUncompressedBytes = 100 * 1024 * 1024
+
+	kExchangeRateMaxAgeDays       = "EXCHANGE_RATE_MAX_AGE_DAYS"
+	defaultExchangeRateMaxAgeDays = 7
 )
 
 type ConfigGetter func(string) string
@@ -25,6 +52,32 @@ type Config struct {
 	DatabaseURL   string
 	AdminUsername string
 	AdminPassword string
+
+	// CSVMaxBufferedRows is the largest csv upload answered as a single json
+	// document; larger uploads must be streamed.
+	CSVMaxBufferedRows int
+	// BatchWorkers is the number of uploads calculated at the same time in
+	// the background.
+	BatchWorkers int
+	// CalculationWorkers is the number of rows of an upload calculated at the
+	// same time. Zero means one per CPU.
+	CalculationWorkers int
+	// CalculationBatchMaxItems is the largest number of people calculated in
+	// one request to /tax/calculations/batch.
+	CalculationBatchMaxItems int
+	// ZipMaxEntries is the most files an uploaded zip may hold.
+	ZipMaxEntries int
+	// ZipMaxUncompressedBytes is the most an uploaded zip may hold once
+	// uncompressed, across all its files.
+	ZipMaxUncompressedBytes int
+	// BatchMaxUploadBytes is the largest file accepted by /tax/batches.
+	BatchMaxUploadBytes int
+	// XLSXMaxUncompressedBytes is the most an uploaded xlsx workbook may hold
+	// once unzipped.
+	XLSXMaxUncompressedBytes int
+	// ExchangeRateMaxAgeDays is how many days before a payment the rate
+	// converting it may have been published, to bridge weekends and holidays.
+	ExchangeRateMaxAgeDays int
 }
 
 func NewWith(cfgGetter ConfigGetter) *Config {
@@ -33,6 +86,17 @@ func NewWith(cfgGetter ConfigGetter) *Config {
 		DatabaseURL:   getString(cfgGetter, kDatabaseURL, defaultDatabaseURL),
 		AdminUsername: getString(cfgGetter, kAdminUsername, defaultAdminUsername),
 		AdminPassword: getString(cfgGetter, kAdminPassword, defaultAdminPassword),
+
+		CSVMaxBufferedRows: getInt(cfgGetter, kCSVMaxBufferedRows, defaultCSVMaxBufferedRows),
+		BatchWorkers:       getInt(cfgGetter, kBatchWorkers, defaultBatchWorkers),
+		CalculationWorkers: getInt(cfgGetter, kCalculationWorkers, defaultCalculationWorkers),
+
+		CalculationBatchMaxItems: getInt(cfgGetter, kCalculationBatchMaxItems, defaultCalculationBatchMaxItems),
+		ZipMaxEntries:            getInt(cfgGetter, kZipMaxEntries, defaultZipMaxEntries),
+		ZipMaxUncompressedBytes:  getInt(cfgGetter, kZipMaxUncompressedBytes, defaultZipMaxUncompressedBytes),
+		BatchMaxUploadBytes:      getInt(cfgGetter, kBatchMaxUploadBytes, defaultBatchMaxUploadBytes),
+		XLSXMaxUncompressedBytes: getInt(cfgGetter, kXLSXMaxUncompressedBytes, defaultXLSXMaxUncompressedBytes),
+		ExchangeRateMaxAgeDays:   getInt(cfgGetter, kExchangeRateMaxAgeDays, defaultExchangeRateMaxAgeDays),
 	}
 }
 
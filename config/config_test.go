@@ -22,6 +22,15 @@ func TestNewWith_Default(t *testing.T) {
 	assert.Equal(t, defaultDatabaseURL, got.DatabaseURL)
 	assert.Equal(t, defaultAdminUsername, got.AdminUsername)
 	assert.Equal(t, defaultAdminPassword, got.AdminPassword)
+	assert.Equal(t, defaultCSVMaxBufferedRows, got.CSVMaxBufferedRows)
+	assert.Equal(t, defaultBatchWorkers, got.BatchWorkers)
+	assert.Equal(t, defaultCalculationWorkers, got.CalculationWorkers)
+	assert.Equal(t, defaultCalculationBatchMaxItems, got.CalculationBatchMaxItems)
+	assert.Equal(t, defaultZipMaxEntries, got.ZipMaxEntries)
+	assert.Equal(t, defaultZipMaxUncompressedBytes, got.ZipMaxUncompressedBytes)
+	assert.Equal(t, defaultBatchMaxUploadBytes, got.BatchMaxUploadBytes)
+	assert.Equal(t, defaultXLSXMaxUncompressedBytes, got.XLSXMaxUncompressedBytes)
+	assert.Equal(t, defaultExchangeRateMaxAgeDays, got.ExchangeRateMaxAgeDays)
 }
 
 func TestNewWith_Custom(t *testing.T) {
@@ -31,6 +40,17 @@ func TestNewWith_Custom(t *testing.T) {
 		DatabaseURL:   "database-url",
 		AdminUsername: "admin",
 		AdminPassword: "password",
+
+		CSVMaxBufferedRows: 500,
+		BatchWorkers:       4,
+		CalculationWorkers: 8,
+
+		CalculationBatchMaxItems: 50,
+		ZipMaxEntries:            10,
+		ZipMaxUncompressedBytes:  1024,
+		BatchMaxUploadBytes:      2048,
+		XLSXMaxUncompressedBytes: 4096,
+		ExchangeRateMaxAgeDays:   3,
 	}
 	cfgGetter := func(key string) string {
 		if key == kPort {
@@ -45,6 +65,33 @@ func TestNewWith_Custom(t *testing.T) {
 		if key == kAdminPassword {
 			return want.AdminPassword
 		}
+		if key == kCSVMaxBufferedRows {
+			return strconv.Itoa(want.CSVMaxBufferedRows)
+		}
+		if key == kBatchWorkers {
+			return strconv.Itoa(want.BatchWorkers)
+		}
+		if key == kCalculationWorkers {
+			return strconv.Itoa(want.CalculationWorkers)
+		}
+		if key == kCalculationBatchMaxItems {
+			return strconv.Itoa(want.CalculationBatchMaxItems)
+		}
+		if key == kZipMaxEntries {
+			return strconv.Itoa(want.ZipMaxEntries)
+		}
+		if key == kZipMaxUncompressedBytes {
+			return strconv.Itoa(want.ZipMaxUncompressedBytes)
+		}
+		if key == kBatchMaxUploadBytes {
+			return strconv.Itoa(want.BatchMaxUploadBytes)
+		}
+		if key == kXLSXMaxUncompressedBytes {
+			return strconv.Itoa(want.XLSXMaxUncompressedBytes)
+		}
+		if key == kExchangeRateMaxAgeDays {
+			return strconv.Itoa(want.ExchangeRateMaxAgeDays)
+		}
 		return ""
 	}
 
@@ -56,4 +103,13 @@ func TestNewWith_Custom(t *testing.T) {
 	assert.Equal(t, want.DatabaseURL, got.DatabaseURL)
 	assert.Equal(t, want.AdminUsername, got.AdminUsername)
 	assert.Equal(t, want.AdminPassword, got.AdminPassword)
+	assert.Equal(t, want.CSVMaxBufferedRows, got.CSVMaxBufferedRows)
+	assert.Equal(t, want.BatchWorkers, got.BatchWorkers)
+	assert.Equal(t, want.CalculationWorkers, got.CalculationWorkers)
+	assert.Equal(t, want.CalculationBatchMaxItems, got.CalculationBatchMaxItems)
+	assert.Equal(t, want.ZipMaxEntries, got.ZipMaxEntries)
+	assert.Equal(t, want.ZipMaxUncompressedBytes, got.ZipMaxUncompressedBytes)
+	assert.Equal(t, want.BatchMaxUploadBytes, got.BatchMaxUploadBytes)
+	assert.Equal(t, want.XLSXMaxUncompressedBytes, got.XLSXMaxUncompressedBytes)
+	assert.Equal(t, want.ExchangeRateMaxAgeDays, got.ExchangeRateMaxAgeDays)
 }
@@ -0,0 +1,174 @@
+package deduction
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	NamePersonal = "personal"
+	NameKReceipt = "k-receipt"
+	NameDonation = "donation"
+)
+
+var ErrDeductionNotFound = errors.New("deduction not found")
+
+// Limit is the range a deduction can be set to. Min is exclusive and nil when
+// there is no lower bound; Max is inclusive.
+type Limit struct {
+	Min *float64
+	Max float64
+}
+
+func minOf(v float64) *float64 {
+	return &v
+}
+
+// Definition is a deduction an admin can set. The deductions an admin may
+// set, the range of each and where each amount goes in a Deduction are all
+// read from definitions, so a deduction is added in one place.
+type Definition struct {
+	Name  string
+	Limit Limit
+	// Key names the amount in the body sent back when the deduction is set
+	// on its own.
+	Key      string
+	validate func(float64) error
+	field    func(*Deduction) *float64
+}
+
+// Validate checks amount against the range of the deduction.
+func (d Definition) Validate(amount float64) error {
+	return d.validate(amount)
+}
+
+var definitions = []Definition{
+	{
+		Name:     NamePersonal,
+		Limit:    Limit{Min: minOf(MinPersonalDeduction), Max: MaxPersonalDeduction},
+		Key:      "personalDeduction",
+		validate: ValidatePersonalDeduction,
+		field:    func(d *Deduction) *float64 { return &d.Personal },
+	},
+	{
+		Name:     NameKReceipt,
+		Limit:    Limit{Min: minOf(MinKReceiptDeduction), Max: MaxKReceiptDeduction},
+		Key:      "kReceipt",
+		validate: ValidateKReceiptDeduction,
+		field:    func(d *Deduction) *float64 { return &d.KReceipt },
+	},
+	{
+		Name:     NameDonation,
+		Limit:    Limit{Max: MaxDonationDeduction},
+		Key:      "donation",
+		validate: ValidateDonationDeduction,
+		field:    func(d *Deduction) *float64 { return &d.Donation },
+	},
+}
+
+// DefinitionOf returns the definition of a deduction, and false for an
+// unknown name.
+func DefinitionOf(name string) (Definition, bool) {
+	for _, d := range definitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// LimitOf returns the range of a deduction, and false for an unknown name.
+func LimitOf(name string) (Limit, bool) {
+	d, ok := DefinitionOf(name)
+	return d.Limit, ok
+}
+
+// Set puts amount in the field of the deduction called name. It tells
+// whether there is such a deduction; an unknown name changes nothing.
+func (d *Deduction) Set(name string, amount float64) bool {
+	def, ok := DefinitionOf(name)
+	if !ok {
+		return false
+	}
+	*def.field(d) = amount
+	return true
+}
+
+// Setting is a deduction as stored, with its last change.
+type Setting struct {
+	Name      string
+	Amount    float64
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+// Actor is the admin behind a change, and the request that made it.
+type Actor struct {
+	Username  string
+	SourceIP  string
+	RequestID string
+}
+
+// Change is one entry of the audit log of deduction changes.
+type Change struct {
+	ID        int64
+	Name      string
+	OldAmount float64
+	NewAmount float64
+	ChangedBy string
+	ChangedAt time.Time
+	SourceIP  string
+	RequestID string
+}
+
+// ChangeFilter selects a page of the audit log. Empty fields match every
+// change; From is inclusive and To exclusive.
+type ChangeFilter struct {
+	Name      string
+	ChangedBy string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+var (
+	ErrScheduleNotFound   = errors.New("scheduled deduction not found")
+	ErrScheduleNotPending = errors.New("scheduled deduction is not pending")
+)
+
+type ScheduleStatus string
+
+const (
+	SchedulePending   ScheduleStatus = "pending"
+	ScheduleApplied   ScheduleStatus = "applied"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// Schedule is a change of a deduction set in advance. The amount is in force
+// from EffectiveFrom, until a later change of the same deduction.
+type Schedule struct {
+	ID            int64
+	Name          string
+	Amount        float64
+	EffectiveFrom time.Time
+	CreatedBy     string
+	CreatedAt     time.Time
+	UpdatedBy     string
+	UpdatedAt     time.Time
+	CancelledBy   string
+	CancelledAt   *time.Time
+}
+
+// Status is the state of the schedule at now. A schedule is applied once its
+// effective time has passed, whether or not the stored amount has caught up.
+func (s Schedule) Status(now time.Time) ScheduleStatus {
+	switch {
+	case s.CancelledAt != nil:
+		return ScheduleCancelled
+	case s.EffectiveFrom.After(now):
+		return SchedulePending
+	default:
+		return ScheduleApplied
+	}
+}
@@ -0,0 +1,90 @@
+//go:build unit
+
+package deduction
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimitOf(t *testing.T) {
+	testCases := []struct {
+		name   string
+		want   Limit
+		wantOK bool
+	}{
+		{name: NamePersonal, want: Limit{Min: minOf(MinPersonalDeduction), Max: MaxPersonalDeduction}, wantOK: true},
+		{name: NameKReceipt, want: Limit{Min: minOf(MinKReceiptDeduction), Max: MaxKReceiptDeduction}, wantOK: true},
+		{name: NameDonation, want: Limit{Max: MaxDonationDeduction}, wantOK: true},
+		{name: "unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, ok := LimitOf(tc.name)
+
+			// Assert
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDefinitions_ValidateMatchesLimit(t *testing.T) {
+	for _, def := range definitions {
+		t.Run(def.Name, func(t *testing.T) {
+			// Assert
+			assert.NoError(t, def.Validate(def.Limit.Max))
+			assert.Error(t, def.Validate(def.Limit.Max+0.01))
+			if def.Limit.Min != nil {
+				assert.Error(t, def.Validate(*def.Limit.Min))
+				assert.NoError(t, def.Validate(*def.Limit.Min+0.01))
+			}
+		})
+	}
+}
+
+func TestDeduction_Set(t *testing.T) {
+	// Arrange
+	var got Deduction
+
+	// Act
+	okPersonal := got.Set(NamePersonal, 60_000.0)
+	okKReceipt := got.Set(NameKReceipt, 50_000.0)
+	okDonation := got.Set(NameDonation, 100_000.0)
+	okUnknown := got.Set("unknown", 1.0)
+
+	// Assert
+	assert.True(t, okPersonal)
+	assert.True(t, okKReceipt)
+	assert.True(t, okDonation)
+	assert.False(t, okUnknown)
+	assert.Equal(t, Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}, got)
+}
+
+func TestSchedule_Status(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cancelledAt := now.Add(-time.Hour)
+	testCases := []struct {
+		name     string
+		schedule Schedule
+		want     ScheduleStatus
+	}{
+		{name: "effective later", schedule: Schedule{EffectiveFrom: now.Add(time.Hour)}, want: SchedulePending},
+		{name: "effective now", schedule: Schedule{EffectiveFrom: now}, want: ScheduleApplied},
+		{name: "effective earlier", schedule: Schedule{EffectiveFrom: now.Add(-time.Hour)}, want: ScheduleApplied},
+		{name: "cancelled", schedule: Schedule{EffectiveFrom: now.Add(time.Hour), CancelledAt: &cancelledAt}, want: ScheduleCancelled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := tc.schedule.Status(now)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
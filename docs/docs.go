@@ -15,14 +15,49 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/deductions/k-receipt": {
-            "post": {
+        "/admin/deductions": {
+            "get": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "Admin set k-receipt deduction",
+                "description": "Admin list the current deductions with the range each can be set to (above min, when set, and up to max), when it was last changed and by whom",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin list deductions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSettings"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin set the deductions of a map of names to amounts. Every amount is checked first and all of them are set together: when one is invalid nothing changes, and a calculation never sees some of the new amounts without the others.\nWith an effectiveFrom key in the body, an RFC 3339 time in the future, the amounts are scheduled to take effect together at that time instead, and the schedules are returned with 202.",
                 "consumes": [
                     "application/json"
                 ],
@@ -32,15 +67,15 @@ const docTemplate = `{
                 "tags": [
                     "admin"
                 ],
-                "summary": "Admin set k-receipt deduction",
+                "summary": "Admin set several deductions at once",
                 "parameters": [
                     {
-                        "description": "Amount to set personal deduction",
-                        "name": "amount",
+                        "description": "Amounts by deduction name, and an optional effectiveFrom",
+                        "name": "amounts",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/admin.Deduction"
+                            "$ref": "#/definitions/admin.DeductionAmounts"
                         }
                     }
                 ],
@@ -48,7 +83,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/admin.KReceiptDeduction"
+                            "$ref": "#/definitions/admin.DeductionAmounts"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedules"
                         }
                     },
                     "400": {
@@ -63,6 +104,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/admin.Err"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -72,14 +119,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/admin/deductions/personal": {
+        "/admin/deductions/donation": {
             "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "Admin set personal deduction",
+                "description": "Admin set the maximum donation deduction",
                 "consumes": [
                     "application/json"
                 ],
@@ -89,10 +136,10 @@ const docTemplate = `{
                 "tags": [
                     "admin"
                 ],
-                "summary": "Admin set personal deduction",
+                "summary": "Admin set donation deduction",
                 "parameters": [
                     {
-                        "description": "Amount to set personal deduction",
+                        "description": "Amount to set donation deduction",
                         "name": "amount",
                         "in": "body",
                         "required": true,
@@ -105,7 +152,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/admin.PersonalDeduction"
+                            "$ref": "#/definitions/admin.DonationDeduction"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
                         }
                     },
                     "400": {
@@ -129,9 +182,97 @@ const docTemplate = `{
                 }
             }
         },
-        "/tax/calculations": {
+        "/admin/deductions/history": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin list the audit log of deduction changes, newest first: the old and new amount, the admin who made the change, when, and the source ip and request id of the request. The log is append-only.\nfrom and to take a timestamp (RFC 3339) or a date; from is inclusive, to is exclusive for a timestamp and covers the whole day for a date.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin list changes of deductions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Deduction name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin username",
+                        "name": "changedBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Changes at or after, e.g. 2024-03-01",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Changes before, e.g. 2024-03-31",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Changes per page, at most 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Changes to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionChanges"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deductions/k-receipt": {
             "post": {
-                "description": "Calculate tax",
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin set k-receipt deduction",
                 "consumes": [
                     "application/json"
                 ],
@@ -139,17 +280,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "tax"
+                    "admin"
                 ],
-                "summary": "Calculate tax",
+                "summary": "Admin set k-receipt deduction",
                 "parameters": [
                     {
-                        "description": "Amount to calculate tax",
+                        "description": "Amount to set personal deduction",
                         "name": "amount",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/tax.TaxInformation"
+                            "$ref": "#/definitions/admin.Deduction"
                         }
                     }
                 ],
@@ -157,129 +298,1938 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/tax.TaxResult"
+                            "$ref": "#/definitions/admin.KReceiptDeduction"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/tax.Err"
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/tax.Err"
+                            "$ref": "#/definitions/admin.Err"
                         }
                     }
                 }
             }
         },
-        "/tax/calculations/upload-csv": {
+        "/admin/deductions/personal": {
             "post": {
-                "description": "Upload csv file and calculate tax",
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin set personal deduction",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "tax"
+                    "admin"
                 ],
-                "summary": "Upload csv file and calculate tax",
+                "summary": "Admin set personal deduction",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "this is a test file",
-                        "name": "taxFile",
-                        "in": "formData",
-                        "required": true
+                        "description": "Amount to set personal deduction",
+                        "name": "amount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.Deduction"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/tax.CsvTaxResponse"
+                            "$ref": "#/definitions/admin.PersonalDeduction"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/tax.Err"
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/tax.Err"
+                            "$ref": "#/definitions/admin.Err"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "admin.Deduction": {
-            "type": "object",
-            "properties": {
-                "amount": {
-                    "type": "number",
-                    "minimum": 0
-                }
-            }
-        },
-        "admin.Err": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
-                }
-            }
-        },
-        "admin.KReceiptDeduction": {
-            "type": "object",
-            "properties": {
-                "kReceipt": {
-                    "type": "number"
-                }
-            }
         },
-        "admin.PersonalDeduction": {
-            "type": "object",
-            "properties": {
-                "personalDeduction": {
-                    "type": "number"
+        "/admin/deductions/schedules": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin list deduction changes set in advance, in the order they take effect. A schedule is pending until its effective time, then applied, unless it was cancelled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin list scheduled deduction changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Deduction name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "applied",
+                            "cancelled",
+                            "all"
+                        ],
+                        "type": "string",
+                        "default": "pending",
+                        "description": "Status of the schedules",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedules"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
                 }
             }
         },
-        "tax.Allowance": {
-            "type": "object",
-            "properties": {
-                "allowanceType": {
-                    "$ref": "#/definitions/tax.AllowanceType"
-                },
+        "/admin/deductions/schedules/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin change the amount and effective time of a schedule that has not taken effect yet. The amount is checked against the range of the deduction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin edit a pending deduction change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Schedule id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New amount and effective time",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.ScheduleUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin cancel a schedule that has not taken effect yet. The schedule is kept, marked as cancelled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin cancel a pending deduction change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Schedule id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/deductions/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin get the current value of a deduction with the range it can be set to, when it was last changed and by whom",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin get a deduction",
+                "parameters": [
+                    {
+                        "enum": [
+                            "personal",
+                            "k-receipt",
+                            "donation"
+                        ],
+                        "type": "string",
+                        "description": "Deduction name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSetting"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin set any deduction by name, checked against the range of that deduction. The response is the same as the POST endpoint of the deduction.\nWith an effectiveFrom in the future the change is scheduled instead, and the schedule is returned with 202.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin set a deduction by name",
+                "parameters": [
+                    {
+                        "enum": [
+                            "personal",
+                            "k-receipt",
+                            "donation"
+                        ],
+                        "type": "string",
+                        "description": "Deduction name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Amount to set the deduction",
+                        "name": "amount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.Deduction"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/admin.DeductionSchedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/exchange-rates": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin list exchange rates, optionally filtered by currency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin list exchange rates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Currency code, e.g. USD",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ExchangeRates"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin insert or replace exchange rates (THB per one unit of currency) by currency and date",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin set exchange rates",
+                "parameters": [
+                    {
+                        "description": "Exchange rates to set",
+                        "name": "rates",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.ExchangeRates"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ExchangeRates"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/exchange-rates/upload-csv": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Admin insert or replace exchange rates from a csv file with header currency,date,rate",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Admin import exchange rates from csv",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "csv file of exchange rates",
+                        "name": "rateFile",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/admin.ExchangeRates"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/admin.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/batches": {
+            "post": {
+                "description": "Store the upload as a batch and return it at once. The batch is calculated by a background worker; poll /tax/batches/{id} for its progress, or follow /tax/batches/{id}/events.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Upload csv or xlsx file to calculate tax in the background",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "csv or xlsx file",
+                        "name": "taxFile",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "pass unknown columns through as metadata instead of rejecting the file",
+                        "name": "allowUnknownColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated columns to echo as metadata on every result; they must be in the header",
+                        "name": "passThroughColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "fail the batch on the first invalid row",
+                        "name": "strict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "xlsx sheet to read, the first one by default",
+                        "name": "sheet",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv encoding: utf-8 or tis-620 (windows-874), detected by default",
+                        "name": "encoding",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv delimiter: comma (default), semicolon or tab",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Batch"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/batches/{id}": {
+            "get": {
+                "description": "Get the status, row counts and progress in percent of a batch",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Get the progress of a batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Batch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/batches/{id}/cancel": {
+            "post": {
+                "description": "Stop a pending or running batch. The rows calculated so far are kept and can still be read from /tax/batches/{id}/result.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Cancel a batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Batch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/batches/{id}/events": {
+            "get": {
+                "description": "Stream server-sent events while a batch is calculated. A progress event carries the rows processed, calculated and failed so far, the progress in percent and, once the speed is known, the estimated seconds left. The stream ends with a complete event, also sent at once for a finished or cancelled batch, holding the final counts and the link to the result.\nClosing the connection stops the stream only, and the batch carries on in the background, unless cancelOnDisconnect is set: the batch is then cancelled once no other stream is watching it.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Stream the progress of a batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "cancel the batch when the client disconnects",
+                        "name": "cancelOnDisconnect",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.BatchProgress"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/batches/{id}/result": {
+            "get": {
+                "description": "Get the taxes and row errors of a finished batch",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Get the result of a batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.CsvTaxResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations": {
+            "post": {
+                "description": "Calculate tax",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Calculate tax",
+                "parameters": [
+                    {
+                        "description": "Amount to calculate tax",
+                        "name": "amount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/tax.TaxInformation"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages and tax level labels (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.TaxResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/amendments": {
+            "post": {
+                "description": "Calculate the original and the amended tax, report changed inputs, allowances and tax levels, and the additional payment or refund\nBoth are calculated with the deductions in force at the original's asOf. The amended asOf may be left out; any other time than the original's is rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Compare an amended filing with the original",
+                "parameters": [
+                    {
+                        "description": "Original and amended tax information",
+                        "name": "amendment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/tax.AmendmentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages and tax level labels (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.AmendmentResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/batch": {
+            "post": {
+                "description": "Calculate tax for a json array of items, or one item per line with Content-Type: application/x-ndjson. An item is the body of /tax/calculations with an id chosen by the client, and is checked and calculated the same way.\nEvery item without an asOf is calculated with the same deduction; an item with one uses the deduction in force at that time. Each result carries the index and id of its item, and either the tax result or the reason the item failed; an id that is missing or repeats an earlier one fails its item.\nWith Accept: application/x-ndjson the results are written one per line; text/csv and xlsx export one row per item, with its index and id, like /tax/calculations/upload-csv. The number of items is limited by the server.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Calculate tax for many people at once",
+                "parameters": [
+                    {
+                        "description": "People to calculate tax for",
+                        "name": "items",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/tax.CalculationItem"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "application/json (default), application/x-ndjson, text/csv or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages and tax level labels (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.CalculationBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/csv-template": {
+            "get": {
+                "description": "Download a file with the exact header upload-csv accepts, including one column per allowance type and the optional id column, followed by an example row.\nWith Accept set to the xlsx media type the template is a spreadsheet with a note on every column and amount columns limited to numbers of at least 0.",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Download a template for csv uploads",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "text/csv (default) or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of the notes (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/interim": {
+            "post": {
+                "description": "Calculate interim tax on January-June income of categories 40(5)-40(8) with half-year allowances",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Calculate half-year (PND.94) interim tax",
+                "parameters": [
+                    {
+                        "description": "Half-year income to calculate interim tax",
+                        "name": "amount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/tax.TaxInformation"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages and tax level labels (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.TaxResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/upload-csv": {
+            "post": {
+                "description": "Upload csv or xlsx file and calculate tax. An xlsx file, recognised by its .xlsx extension, is read from the first sheet or the one named in sheet. Invalid rows are skipped and reported in errors unless strict is set, in which case any invalid row fails the upload.\nA csv may be UTF-8, with or without a byte order mark, or TIS-620; the encoding is detected unless given. Amounts may have thousands separators, as in 1,250,000.00, and negatives may be written in parentheses.\nAn optional id column, such as an employee number, is echoed on every result and error; a row repeating an earlier id is rejected.\nThe json result ends with a summary: totals, the count and tax of each bracket, and the min, max and median tax. With summaryOnly the response is that summary alone.\nWith Accept: application/x-ndjson the result is streamed row by row as one CsvTaxLine per line, the last line holding the summary; in strict mode the stream stops after the first error line, without a summary. A json response is limited to the server's buffer size and larger files are rejected with 413.\nWith Accept: text/csv or the xlsx media type the result is a file with the uploaded columns followed by tax, taxRefund, one column per tax level and error. A csv is streamed, except in strict mode, where it is sent once complete so that an invalid row fails the upload as it does for json.\nA .zip of csv and xlsx files is answered with a ZipTaxResponse instead: the result of each file by name, with the same options, and the summary of all of them. A file that cannot be read, or has an invalid row in strict mode, fails on its own. The number of files and their uncompressed size are limited by the server.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Upload csv or xlsx file and calculate tax",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "this is a test file",
+                        "name": "taxFile",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "pass unknown columns through as metadata instead of rejecting the file",
+                        "name": "allowUnknownColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated columns to echo as metadata on every result; they must be in the header",
+                        "name": "passThroughColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "fail the whole upload on the first invalid row",
+                        "name": "strict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "answer with the CsvTaxSummary of the file only, whatever its size",
+                        "name": "summaryOnly",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "xlsx sheet to read, the first one by default",
+                        "name": "sheet",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv encoding: utf-8 or tis-620 (windows-874), detected by default",
+                        "name": "encoding",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv delimiter: comma (default), semicolon or tab",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "application/json (default), application/x-ndjson, text/csv or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.ZipTaxResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        },
+        "/tax/calculations/upload-csv/validate": {
+            "post": {
+                "description": "Check every row of an upload the way upload-csv would, without calculating it. The report counts the valid and invalid rows and lists every error with its row and column.\nWarnings flag valid values that are likely mistakes: a wht above 20% of total income, or a donation or k-receipt above its deduction cap.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tax"
+                ],
+                "summary": "Validate csv or xlsx file without calculating tax",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "this is a test file",
+                        "name": "taxFile",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "pass unknown columns through as metadata instead of rejecting the file",
+                        "name": "allowUnknownColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma-separated columns to echo as metadata on every result; they must be in the header",
+                        "name": "passThroughColumns",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "xlsx sheet to read, the first one by default",
+                        "name": "sheet",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv encoding: utf-8 or tis-620 (windows-874), detected by default",
+                        "name": "encoding",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv delimiter: comma (default), semicolon or tab",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language of messages (en, th)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tax.CsvValidationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/tax.Err"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "admin.Deduction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "effectiveFrom": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.DeductionAmounts": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "number"
+            }
+        },
+        "admin.DeductionChange": {
+            "type": "object",
+            "properties": {
+                "changedAt": {
+                    "type": "string"
+                },
+                "changedBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "newAmount": {
+                    "type": "number"
+                },
+                "oldAmount": {
+                    "type": "number"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "sourceIp": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.DeductionChanges": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.DeductionChange"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "admin.DeductionSchedule": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "cancelledAt": {
+                    "type": "string"
+                },
+                "cancelledBy": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "effectiveFrom": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.DeductionSchedules": {
+            "type": "object",
+            "properties": {
+                "schedules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.DeductionSchedule"
+                    }
+                }
+            }
+        },
+        "admin.DeductionSetting": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "max": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.DeductionSettings": {
+            "type": "object",
+            "properties": {
+                "deductions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.DeductionSetting"
+                    }
+                }
+            }
+        },
+        "admin.DonationDeduction": {
+            "type": "object",
+            "properties": {
+                "donation": {
+                    "type": "number"
+                }
+            }
+        },
+        "admin.Err": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.ExchangeRate": {
+            "type": "object",
+            "required": [
+                "currency",
+                "date"
+            ],
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                }
+            }
+        },
+        "admin.ExchangeRates": {
+            "type": "object",
+            "required": [
+                "rates"
+            ],
+            "properties": {
+                "rates": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/admin.ExchangeRate"
+                    }
+                }
+            }
+        },
+        "admin.KReceiptDeduction": {
+            "type": "object",
+            "properties": {
+                "kReceipt": {
+                    "type": "number"
+                }
+            }
+        },
+        "admin.PersonalDeduction": {
+            "type": "object",
+            "properties": {
+                "personalDeduction": {
+                    "type": "number"
+                }
+            }
+        },
+        "admin.ScheduleUpdate": {
+            "type": "object",
+            "required": [
+                "effectiveFrom"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "effectiveFrom": {
+                    "type": "string"
+                }
+            }
+        },
+        "tax.Allowance": {
+            "type": "object",
+            "properties": {
+                "allowanceType": {
+                    "$ref": "#/definitions/tax.AllowanceType"
+                },
                 "amount": {
                     "type": "number",
                     "minimum": 0
                 }
             }
         },
-        "tax.AllowanceType": {
+        "tax.AllowanceType": {
+            "type": "string",
+            "enum": [
+                "donation",
+                "k-receipt"
+            ],
+            "x-enum-varnames": [
+                "AllowanceTypeDonation",
+                "AllowanceTypeKReceipt"
+            ]
+        },
+        "tax.AmendmentDiff": {
+            "type": "object",
+            "properties": {
+                "allowances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.FieldChange"
+                    }
+                },
+                "inputs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.FieldChange"
+                    }
+                },
+                "severanceTaxLevel": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.FieldChange"
+                    }
+                },
+                "taxLevel": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.FieldChange"
+                    }
+                }
+            }
+        },
+        "tax.AmendmentRequest": {
+            "type": "object",
+            "properties": {
+                "amended": {
+                    "$ref": "#/definitions/tax.TaxInformation"
+                },
+                "original": {
+                    "$ref": "#/definitions/tax.TaxInformation"
+                }
+            }
+        },
+        "tax.AmendmentResult": {
+            "type": "object",
+            "properties": {
+                "additionalPayment": {
+                    "type": "number"
+                },
+                "amended": {
+                    "$ref": "#/definitions/tax.TaxResult"
+                },
+                "diff": {
+                    "$ref": "#/definitions/tax.AmendmentDiff"
+                },
+                "original": {
+                    "$ref": "#/definitions/tax.TaxResult"
+                },
+                "refund": {
+                    "type": "number"
+                }
+            }
+        },
+        "tax.Batch": {
+            "type": "object",
+            "properties": {
+                "completedAt": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failedRows": {
+                    "type": "integer"
+                },
+                "fileName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "options": {
+                    "$ref": "#/definitions/tax.BatchOptions"
+                },
+                "processedRows": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "type": "number"
+                },
+                "status": {
+                    "$ref": "#/definitions/tax.BatchStatus"
+                },
+                "succeededRows": {
+                    "type": "integer"
+                },
+                "totalRows": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "tax.BatchOptions": {
+            "type": "object",
+            "properties": {
+                "allowUnknownColumns": {
+                    "type": "boolean"
+                },
+                "delimiter": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "type": "string"
+                },
+                "passThroughColumns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sheet": {
+                    "type": "string"
+                },
+                "strict": {
+                    "type": "boolean"
+                },
+                "xlsx": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "tax.BatchProgress": {
+            "type": "object",
+            "properties": {
+                "calculatedRows": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "etaSeconds": {
+                    "type": "number"
+                },
+                "failedRows": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "processedRows": {
+                    "type": "integer"
+                },
+                "progress": {
+                    "type": "number"
+                },
+                "resultUrl": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/tax.BatchStatus"
+                },
+                "totalRows": {
+                    "type": "integer"
+                }
+            }
+        },
+        "tax.BatchStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "completed",
+                "failed",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "BatchStatusPending",
+                "BatchStatusRunning",
+                "BatchStatusCompleted",
+                "BatchStatusFailed",
+                "BatchStatusCancelled"
+            ]
+        },
+        "tax.CalculationBatchResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CalculationItemResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "tax.CalculationItem": {
+            "type": "object",
+            "properties": {
+                "allowances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.Allowance"
+                    }
+                },
+                "asOf": {
+                    "description": "AsOf is the date, or RFC 3339 time, at which deductions are taken;\nnow when empty.",
+                    "type": "string"
+                },
+                "foreignIncomes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.ForeignAmount"
+                    }
+                },
+                "foreignWht": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.ForeignAmount"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "interimTax": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "severance": {
+                    "$ref": "#/definitions/tax.Severance"
+                },
+                "totalIncome": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "wht": {
+                    "type": "number",
+                    "minimum": 0
+                }
+            }
+        },
+        "tax.CalculationItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "result": {
+                    "$ref": "#/definitions/tax.TaxResult"
+                }
+            }
+        },
+        "tax.ConversionType": {
             "type": "string",
             "enum": [
-                "donation",
-                "k-receipt"
+                "income",
+                "wht"
             ],
             "x-enum-varnames": [
-                "AllowanceTypeDonation",
-                "AllowanceTypeKReceipt"
+                "ConversionTypeIncome",
+                "ConversionTypeWHT"
             ]
         },
+        "tax.CsvBracketSummary": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "level": {
+                    "type": "string"
+                },
+                "tax": {
+                    "type": "number"
+                }
+            }
+        },
+        "tax.CsvRowError": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "tax.CsvRowWarning": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
         "tax.CsvTaxRecord": {
             "type": "object",
             "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "row": {
+                    "type": "integer"
+                },
                 "tax": {
                     "type": "number"
                 },
@@ -294,6 +2244,15 @@ const docTemplate = `{
         "tax.CsvTaxResponse": {
             "type": "object",
             "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvRowError"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/tax.CsvTaxSummary"
+                },
                 "taxes": {
                     "type": "array",
                     "items": {
@@ -302,19 +2261,188 @@ const docTemplate = `{
                 }
             }
         },
+        "tax.CsvTaxSummary": {
+            "type": "object",
+            "properties": {
+                "brackets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvBracketSummary"
+                    }
+                },
+                "failedRows": {
+                    "type": "integer"
+                },
+                "maxTax": {
+                    "type": "number"
+                },
+                "medianTax": {
+                    "type": "number"
+                },
+                "minTax": {
+                    "type": "number"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "totalIncome": {
+                    "type": "number"
+                },
+                "totalRefund": {
+                    "type": "number"
+                },
+                "totalTax": {
+                    "type": "number"
+                }
+            }
+        },
+        "tax.CsvValidationReport": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvRowError"
+                    }
+                },
+                "invalidRows": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "validRows": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvRowWarning"
+                    }
+                }
+            }
+        },
+        "tax.CurrencyConversion": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "amountTHB": {
+                    "type": "number"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "rateDate": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/tax.ConversionType"
+                }
+            }
+        },
         "tax.Err": {
             "type": "object",
             "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvRowError"
+                    }
+                },
                 "message": {
                     "type": "string"
                 }
             }
         },
-        "tax.TaxInformation": {
+        "tax.FieldChange": {
+            "type": "object",
+            "properties": {
+                "amended": {
+                    "type": "number"
+                },
+                "difference": {
+                    "type": "number"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "original": {
+                    "type": "number"
+                }
+            }
+        },
+        "tax.ForeignAmount": {
             "type": "object",
             "required": [
-                "totalIncome"
+                "currency",
+                "date"
             ],
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                }
+            }
+        },
+        "tax.Severance": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "lastSalary": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "yearsOfService": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "tax.SeveranceResult": {
+            "type": "object",
+            "properties": {
+                "exemption": {
+                    "type": "number"
+                },
+                "separateTaxation": {
+                    "type": "boolean"
+                },
+                "serviceDeduction": {
+                    "type": "number"
+                },
+                "tax": {
+                    "type": "number"
+                },
+                "taxLevel": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.TaxLevel"
+                    }
+                },
+                "taxableIncome": {
+                    "type": "number"
+                }
+            }
+        },
+        "tax.TaxInformation": {
+            "type": "object",
             "properties": {
                 "allowances": {
                     "type": "array",
@@ -322,6 +2450,29 @@ const docTemplate = `{
                         "$ref": "#/definitions/tax.Allowance"
                     }
                 },
+                "asOf": {
+                    "description": "AsOf is the date, or RFC 3339 time, at which deductions are taken;\nnow when empty.",
+                    "type": "string"
+                },
+                "foreignIncomes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.ForeignAmount"
+                    }
+                },
+                "foreignWht": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.ForeignAmount"
+                    }
+                },
+                "interimTax": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "severance": {
+                    "$ref": "#/definitions/tax.Severance"
+                },
                 "totalIncome": {
                     "type": "number",
                     "minimum": 0
@@ -346,6 +2497,15 @@ const docTemplate = `{
         "tax.TaxResult": {
             "type": "object",
             "properties": {
+                "conversions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CurrencyConversion"
+                    }
+                },
+                "severance": {
+                    "$ref": "#/definitions/tax.SeveranceResult"
+                },
                 "tax": {
                     "type": "number"
                 },
@@ -359,6 +2519,46 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "tax.ZipFileResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvRowError"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "summary": {
+                    "$ref": "#/definitions/tax.CsvTaxSummary"
+                },
+                "taxes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.CsvTaxRecord"
+                    }
+                }
+            }
+        },
+        "tax.ZipTaxResponse": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tax.ZipFileResult"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/tax.CsvTaxSummary"
+                }
+            }
         }
     },
     "securityDefinitions": {
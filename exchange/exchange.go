@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	BaseCurrency = "THB"
+	DateLayout   = "2006-01-02"
+)
+
+// Rate is the amount of THB for one unit of Currency, as published for Date.
+type Rate struct {
+	Currency string
+	Date     time.Time
+	Rate     float64
+}
+
+var (
+	ErrRateNotFound    = errors.New("exchange rate not found")
+	ErrInvalidCurrency = errors.New("invalid currency")
+	ErrInvalidDate     = errors.New("invalid date")
+	ErrInvalidRate     = errors.New("invalid exchange rate")
+)
+
+func NormaliseCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+func IsBaseCurrency(currency string) bool {
+	return NormaliseCurrency(currency) == BaseCurrency
+}
+
+func ParseDate(date string) (time.Time, error) {
+	result, err := time.Parse(DateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return result, nil
+}
+
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 || IsBaseCurrency(currency) {
+		return ErrInvalidCurrency
+	}
+	for _, ch := range currency {
+		if ch < 'A' || ch > 'Z' {
+			return ErrInvalidCurrency
+		}
+	}
+	return nil
+}
+
+func (r Rate) Validate() (err error) {
+	if e := ValidateCurrency(r.Currency); e != nil {
+		err = errors.Join(err, e)
+	}
+
+	if r.Date.IsZero() {
+		err = errors.Join(err, ErrInvalidDate)
+	}
+
+	if r.Rate <= 0 {
+		err = errors.Join(err, ErrInvalidRate)
+	}
+	return
+}
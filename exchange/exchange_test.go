@@ -0,0 +1,70 @@
+//go:build unit
+
+package exchange
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNormaliseCurrency(t *testing.T) {
+	assert.Equal(t, "USD", NormaliseCurrency(" usd "))
+}
+
+func TestIsBaseCurrency(t *testing.T) {
+	assert.True(t, IsBaseCurrency("thb"))
+	assert.False(t, IsBaseCurrency("USD"))
+}
+
+func TestParseDate(t *testing.T) {
+	t.Run("valid date", func(t *testing.T) {
+		// Act
+		got, err := ParseDate("2024-01-15")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), got)
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		// Act
+		_, err := ParseDate("15/01/2024")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidDate)
+	})
+}
+
+func TestRate_Validate(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		rate    Rate
+		wantErr []error
+	}{
+		{name: "valid", rate: Rate{Currency: "USD", Date: date, Rate: 35.5}},
+		{name: "lower case currency", rate: Rate{Currency: "usd", Date: date, Rate: 35.5}, wantErr: []error{ErrInvalidCurrency}},
+		{name: "base currency", rate: Rate{Currency: "THB", Date: date, Rate: 1}, wantErr: []error{ErrInvalidCurrency}},
+		{name: "too long currency", rate: Rate{Currency: "USDT", Date: date, Rate: 1}, wantErr: []error{ErrInvalidCurrency}},
+		{name: "zero date", rate: Rate{Currency: "JPY", Rate: 0.24}, wantErr: []error{ErrInvalidDate}},
+		{name: "zero rate", rate: Rate{Currency: "JPY", Date: date}, wantErr: []error{ErrInvalidRate}},
+		{name: "everything invalid", rate: Rate{Currency: "", Rate: -1}, wantErr: []error{ErrInvalidCurrency, ErrInvalidDate, ErrInvalidRate}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			err := tc.rate.Validate()
+
+			// Assert
+			if len(tc.wantErr) == 0 {
+				assert.NoError(t, err)
+			}
+			for _, want := range tc.wantErr {
+				assert.ErrorIs(t, err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const HeaderAcceptLanguage = "Accept-Language"
+
+type Language string
+
+const (
+	LanguageDefault Language = ""
+	LanguageEnglish Language = "en"
+	LanguageThai    Language = "th"
+)
+
+var supportedLanguages = map[Language]bool{
+	LanguageEnglish: true,
+	LanguageThai:    true,
+}
+
+type Catalogue map[Language]map[string]string
+
+// Translate returns msg in the given language. Messages that have no entry in
+// the catalogue, including everything requested in LanguageDefault, are
+// returned as is.
+func (c Catalogue) Translate(lang Language, msg string) string {
+	if translated, ok := c[lang][msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+type languageRange struct {
+	lang    Language
+	quality float64
+}
+
+func parseLanguageRange(part string) (languageRange, bool) {
+	fields := strings.Split(part, ";")
+	tag := strings.ToLower(strings.TrimSpace(fields[0]))
+	if tag == "" {
+		return languageRange{}, false
+	}
+	if i := strings.Index(tag, "-"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	quality := 1.0
+	for _, param := range fields[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return languageRange{}, false
+		}
+		quality = q
+	}
+
+	return languageRange{lang: Language(tag), quality: quality}, true
+}
+
+// FromAcceptLanguage picks the supported language with the highest quality
+// value from an Accept-Language header. It returns LanguageDefault when the
+// header is empty or names no supported language.
+func FromAcceptLanguage(header string) Language {
+	ranges := make([]languageRange, 0)
+	for _, part := range strings.Split(header, ",") {
+		r, ok := parseLanguageRange(part)
+		if !ok || r.quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if supportedLanguages[r.lang] {
+			return r.lang
+		}
+	}
+	return LanguageDefault
+}
+
+func FromRequest(r *http.Request) Language {
+	return FromAcceptLanguage(r.Header.Get(HeaderAcceptLanguage))
+}
@@ -0,0 +1,79 @@
+//go:build unit
+
+package i18n
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromAcceptLanguage(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		want   Language
+	}{
+		{name: "empty header; expect default", header: "", want: LanguageDefault},
+		{name: "thai", header: "th", want: LanguageThai},
+		{name: "english with region", header: "en-US", want: LanguageEnglish},
+		{name: "upper case tag", header: "TH-th", want: LanguageThai},
+		{name: "first supported in list", header: "fr, th, en", want: LanguageThai},
+		{name: "highest quality wins", header: "en;q=0.5, th;q=0.9", want: LanguageThai},
+		{name: "browser style header", header: "th-TH,th;q=0.9,en-US;q=0.8,en;q=0.7", want: LanguageThai},
+		{name: "q=0 is not acceptable", header: "th;q=0, en;q=0.1", want: LanguageEnglish},
+		{name: "invalid q value is skipped", header: "th;q=abc, en", want: LanguageEnglish},
+		{name: "unsupported only; expect default", header: "fr-FR, de;q=0.8", want: LanguageDefault},
+		{name: "wildcard only; expect default", header: "*", want: LanguageDefault},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := FromAcceptLanguage(tc.header)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	// Arrange
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAcceptLanguage, "th")
+
+	// Act
+	got := FromRequest(req)
+
+	// Assert
+	assert.Equal(t, LanguageThai, got)
+}
+
+func TestCatalogue_Translate(t *testing.T) {
+	catalogue := Catalogue{
+		LanguageThai: {"hello": "สวัสดี"},
+	}
+
+	testCases := []struct {
+		name string
+		lang Language
+		msg  string
+		want string
+	}{
+		{name: "found in catalogue", lang: LanguageThai, msg: "hello", want: "สวัสดี"},
+		{name: "missing message falls back to original", lang: LanguageThai, msg: "bye", want: "bye"},
+		{name: "missing language falls back to original", lang: LanguageEnglish, msg: "hello", want: "hello"},
+		{name: "default language returns original", lang: LanguageDefault, msg: "hello", want: "hello"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := catalogue.Translate(tc.lang, tc.msg)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+CREATE TABLE public.exchange_rates
+(
+    id serial NOT NULL,
+    currency character(3) NOT NULL,
+    rate_date date NOT NULL,
+    rate numeric(14, 6) NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT unique_exchange_rate_currency_date UNIQUE (currency, rate_date),
+    CONSTRAINT positive_exchange_rate CHECK (rate > 0)
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.exchange_rates
+    OWNER to postgres;
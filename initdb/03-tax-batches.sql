@@ -0,0 +1,69 @@
+CREATE TABLE public.tax_batches
+(
+    id character varying(32) NOT NULL,
+    status character varying(20) NOT NULL,
+    file_name character varying(255) NOT NULL,
+    allow_unknown_columns boolean NOT NULL DEFAULT false,
+    pass_through_columns text[] NOT NULL DEFAULT '{}',
+    strict boolean NOT NULL DEFAULT false,
+    encoding character varying(20) NOT NULL DEFAULT '',
+    delimiter character varying(1) NOT NULL DEFAULT ',',
+    xlsx boolean NOT NULL DEFAULT false,
+    sheet character varying(255) NOT NULL DEFAULT '',
+    personal_deduction numeric(10, 2) NOT NULL,
+    k_receipt_deduction numeric(10, 2) NOT NULL,
+    donation_deduction numeric(10, 2) NOT NULL,
+    total_rows integer NOT NULL DEFAULT 0,
+    processed_rows integer NOT NULL DEFAULT 0,
+    succeeded_rows integer NOT NULL DEFAULT 0,
+    failed_rows integer NOT NULL DEFAULT 0,
+    checkpoint_row integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    owner character varying(64) NOT NULL DEFAULT '',
+    lease_until timestamp with time zone,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    completed_at timestamp with time zone,
+    PRIMARY KEY (id)
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.tax_batches
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS tax_batches_claimable
+    ON public.tax_batches (created_at)
+    WHERE status IN ('pending', 'running');
+
+CREATE TABLE public.tax_batch_chunks
+(
+    batch_id character varying(32) NOT NULL,
+    seq integer NOT NULL,
+    data bytea NOT NULL,
+    PRIMARY KEY (batch_id, seq),
+    CONSTRAINT fk_tax_batch_chunks_batch FOREIGN KEY (batch_id)
+        REFERENCES public.tax_batches (id) ON DELETE CASCADE
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.tax_batch_chunks
+    OWNER to postgres;
+
+CREATE TABLE public.tax_batch_results
+(
+    batch_id character varying(32) NOT NULL,
+    row_number integer NOT NULL,
+    tax jsonb,
+    tax_levels jsonb,
+    errors jsonb,
+    PRIMARY KEY (batch_id, row_number),
+    CONSTRAINT fk_tax_batch_results_batch FOREIGN KEY (batch_id)
+        REFERENCES public.tax_batches (id) ON DELETE CASCADE
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.tax_batch_results
+    OWNER to postgres;
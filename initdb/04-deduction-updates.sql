@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS public.deductions
+    ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_by character varying(100) NOT NULL DEFAULT '';
@@ -0,0 +1,34 @@
+CREATE TABLE public.deduction_changes
+(
+    id bigserial NOT NULL,
+    name character varying(50) NOT NULL,
+    old_amount numeric(10, 2) NOT NULL,
+    new_amount numeric(10, 2) NOT NULL,
+    changed_by character varying(100) NOT NULL,
+    changed_at timestamp with time zone NOT NULL,
+    source_ip character varying(45) NOT NULL DEFAULT '',
+    request_id character varying(100) NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.deduction_changes
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS deduction_changes_changed_at
+    ON public.deduction_changes (changed_at DESC, id DESC);
+
+-- the audit log is append-only: a recorded change can never be edited or removed
+CREATE OR REPLACE FUNCTION public.deduction_changes_append_only()
+    RETURNS trigger
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'deduction_changes is append-only';
+END;
+$$;
+
+CREATE TRIGGER deduction_changes_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON public.deduction_changes
+    FOR EACH STATEMENT EXECUTE FUNCTION public.deduction_changes_append_only();
@@ -0,0 +1,29 @@
+CREATE TABLE public.deduction_schedules
+(
+    id bigserial NOT NULL,
+    name character varying(50) NOT NULL,
+    amount numeric(10, 2) NOT NULL,
+    effective_from timestamp with time zone NOT NULL,
+    created_by character varying(100) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_by character varying(100) NOT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    source_ip character varying(45) NOT NULL DEFAULT '',
+    request_id character varying(100) NOT NULL DEFAULT '',
+    cancelled_by character varying(100),
+    cancelled_at timestamp with time zone,
+    applied_at timestamp with time zone,
+    PRIMARY KEY (id)
+)
+
+    TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.deduction_schedules
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS deduction_schedules_due
+    ON public.deduction_schedules (name, effective_from DESC, id DESC)
+    WHERE cancelled_at IS NULL AND applied_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS deduction_changes_name_changed_at
+    ON public.deduction_changes (name, changed_at, id);
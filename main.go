@@ -6,6 +6,7 @@ import (
 	"github.com/golfz/assessment-tax/config"
 	"github.com/golfz/assessment-tax/postgres"
 	"github.com/golfz/assessment-tax/router"
+	"github.com/golfz/assessment-tax/tax"
 	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq"
 	"log"
@@ -25,6 +26,7 @@ func initPostgres(cfg *config.Config) *postgres.Postgres {
 	if err != nil {
 		log.Fatalf("exit: %v", err)
 	}
+	pg.ExchangeRateMaxAgeDays = cfg.ExchangeRateMaxAgeDays
 	return pg
 }
 
@@ -39,7 +41,18 @@ func listenForShutdownSignal() (ctx context.Context, stop context.CancelFunc) {
 	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
 
-func waitForGracefullyShutdown(ctx context.Context, e *echo.Echo) {
+func initBatchRunner(pg *postgres.Postgres, cfg *config.Config) *tax.BatchRunner {
+	batches := tax.NewBatchRunner(pg, cfg.BatchWorkers,
+		tax.WithBatchCalculationWorkers(cfg.CalculationWorkers),
+		tax.WithBatchXLSXMaxUncompressedBytes(int64(cfg.XLSXMaxUncompressedBytes)),
+	)
+	if err := batches.Start(); err != nil {
+		log.Fatalf("exit: %v", err)
+	}
+	return batches
+}
+
+func waitForGracefullyShutdown(ctx context.Context, e *echo.Echo, batches *tax.BatchRunner) {
 	<-ctx.Done()
 	fmt.Println("shutting down the server")
 
@@ -48,6 +61,9 @@ func waitForGracefullyShutdown(ctx context.Context, e *echo.Echo) {
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
+	if err := batches.Shutdown(ctx); err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	fmt.Println("server gracefully stopped")
 }
@@ -61,11 +77,12 @@ func waitForGracefullyShutdown(ctx context.Context, e *echo.Echo) {
 func main() {
 	cfg := config.NewWith(os.Getenv)
 	pg := initPostgres(cfg)
-	e := router.New(pg, cfg)
+	batches := initBatchRunner(pg, cfg)
+	e := router.New(pg, cfg, batches)
 
 	ctx, stop := listenForShutdownSignal()
 	defer stop()
 
 	go startServer(e, cfg)
-	waitForGracefullyShutdown(ctx, e)
+	waitForGracefullyShutdown(ctx, e, batches)
 }
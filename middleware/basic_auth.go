@@ -6,12 +6,24 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// ContextKeyUsername is the echo context key holding the admin username once
+// BasicAuth has accepted the request.
+const ContextKeyUsername = "username"
+
 func BasicAuth(cfg config.Config) func(username, password string, c echo.Context) (bool, error) {
 	return func(username, password string, c echo.Context) (bool, error) {
 		if subtle.ConstantTimeCompare([]byte(username), []byte(cfg.AdminUsername)) == 1 &&
 			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.AdminPassword)) == 1 {
+			c.Set(ContextKeyUsername, username)
 			return true, nil
 		}
 		return false, nil
 	}
 }
+
+// Username returns the admin who made the request, or "" when the request did
+// not go through BasicAuth.
+func Username(c echo.Context) string {
+	username, _ := c.Get(ContextKeyUsername).(string)
+	return username
+}
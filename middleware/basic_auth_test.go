@@ -64,3 +64,32 @@ func TestBasicAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestUsername_WithBasicAuth(t *testing.T) {
+	// Arrange
+	cfg := config.Config{
+		AdminUsername: "admin",
+		AdminPassword: "correct",
+	}
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// Act
+	ok, err := BasicAuth(cfg)("admin", "correct", c)
+	got := Username(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "admin", got)
+}
+
+func TestUsername_WithoutBasicAuth(t *testing.T) {
+	// Arrange
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// Act
+	got := Username(c)
+
+	// Assert
+	assert.Empty(t, got)
+}
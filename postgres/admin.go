@@ -1,22 +1,162 @@
 package postgres
 
-type deductionType string
+import (
+	"database/sql"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"sort"
+	"time"
+)
+
+var (
+	ErrCannotQueryDeductionChange = errors.New("unable to query deduction change")
+	ErrCannotScanDeductionChange  = errors.New("unable to scan deduction change")
+)
+
+const (
+	// updateDeductionSQL sets a deduction and records the change in the audit
+	// log in one statement, so neither happens without the other.
+	updateDeductionSQL = `WITH old AS (
+			SELECT name, amount FROM deductions WHERE name = $2 FOR UPDATE
+		), updated AS (
+			UPDATE deductions d SET amount = $1, updated_at = COALESCE($6::timestamptz, now()), updated_by = $3
+			FROM old WHERE d.name = old.name
+			RETURNING d.name, old.amount AS old_amount, d.amount, d.updated_at
+		)
+		INSERT INTO deduction_changes (name, old_amount, new_amount, changed_by, changed_at, source_ip, request_id)
+		SELECT name, old_amount, amount, $3, updated_at, $4, $5 FROM updated`
+)
 
 const (
-	personalDeduction  deductionType = "personal"
-	kReceiptDeduction  deductionType = "k-receipt"
-	updateDeductionSQL               = "UPDATE deductions SET amount = $1 WHERE name = $2"
+	// deductionSettingsSQL reads the deductions as they stand now, taking a
+	// scheduled change that is due but not yet applied to the table as made
+	// at its effective time by the admin who scheduled it, so that reading
+	// needs no write and works on a replica.
+	deductionSettingsSQL = `SELECT d.name, COALESCE(s.amount, d.amount), COALESCE(s.effective_from, d.updated_at), COALESCE(s.created_by, d.updated_by)
+		FROM deductions d
+		LEFT JOIN LATERAL (
+			SELECT amount, effective_from, created_by FROM deduction_schedules
+			WHERE name = d.name AND cancelled_at IS NULL AND applied_at IS NULL AND effective_from <= now()
+			ORDER BY effective_from DESC, id DESC LIMIT 1
+		) s ON true`
+	selectDeductionSettingsSQL = deductionSettingsSQL + ` ORDER BY d.name`
+	selectDeductionSettingSQL  = deductionSettingsSQL + ` WHERE d.name = $1`
+	deductionChangesFilterSQL  = ` FROM deduction_changes
+		WHERE ($1::text = '' OR name = $1::text) AND ($2::text = '' OR changed_by = $2::text)
+		AND ($3::timestamptz IS NULL OR changed_at >= $3) AND ($4::timestamptz IS NULL OR changed_at < $4)`
+	countDeductionChangesSQL  = `SELECT count(*)` + deductionChangesFilterSQL
+	selectDeductionChangesSQL = `SELECT id, name, old_amount, new_amount, changed_by, changed_at, source_ip, request_id` +
+		deductionChangesFilterSQL + ` ORDER BY changed_at DESC, id DESC LIMIT $5 OFFSET $6`
 )
 
-func (p *Postgres) setDeduction(deducType deductionType, amount float64) error {
-	_, err := p.DB.Exec(updateDeductionSQL, amount, deducType)
-	return err
+// updateDeduction sets a deduction as changed at the time at, or now when at
+// is zero.
+func updateDeduction(db execer, name string, amount float64, by deduction.Actor, at time.Time) error {
+	result, err := db.Exec(updateDeductionSQL, amount, name, by.Username, by.SourceIP, by.RequestID, nullTime(at))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return deduction.ErrDeductionNotFound
+	}
+	return nil
 }
 
-func (p *Postgres) SetPersonalDeduction(amount float64) error {
-	return p.setDeduction(personalDeduction, amount)
+// SetDeduction sets the amount of the deduction called name, which must
+// already be in the table.
+func (p *Postgres) SetDeduction(name string, amount float64, by deduction.Actor) error {
+	return p.SetDeductions(map[string]float64{name: amount}, by)
+}
+
+// SetDeductions sets several deductions in one transaction, so a calculation
+// sees either all the new amounts or none of them. Nothing changes when one
+// of the deductions is not in the table. Scheduled changes that are due are
+// applied first, so the audit log records what each change replaced.
+func (p *Postgres) SetDeductions(amounts map[string]float64, by deduction.Actor) error {
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := applyDueSchedules(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, name := range names {
+		if err := updateDeduction(tx, name, amounts[name], by, time.Time{}); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (p *Postgres) SetKReceiptDeduction(amount float64) error {
-	return p.setDeduction(kReceiptDeduction, amount)
+// GetDeductionSettings returns every deduction of the table GetDeduction
+// reads, with who changed it last, scheduled changes included.
+func (p *Postgres) GetDeductionSettings() ([]deduction.Setting, error) {
+	rows, err := p.DB.Query(selectDeductionSettingsSQL)
+	if err != nil {
+		return nil, ErrCannotQueryDeduction
+	}
+	defer rows.Close()
+
+	result := make([]deduction.Setting, 0)
+	for rows.Next() {
+		var s deduction.Setting
+		if err := rows.Scan(&s.Name, &s.Amount, &s.UpdatedAt, &s.UpdatedBy); err != nil {
+			return nil, ErrCannotScanDeduction
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (p *Postgres) GetDeductionSetting(name string) (deduction.Setting, error) {
+	var s deduction.Setting
+	err := p.DB.QueryRow(selectDeductionSettingSQL, name).Scan(&s.Name, &s.Amount, &s.UpdatedAt, &s.UpdatedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return deduction.Setting{}, deduction.ErrDeductionNotFound
+	}
+	if err != nil {
+		return deduction.Setting{}, ErrCannotQueryDeduction
+	}
+	return s, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetDeductionChanges returns a page of the audit log, newest first, and the
+// number of changes matching the filter.
+func (p *Postgres) GetDeductionChanges(filter deduction.ChangeFilter) ([]deduction.Change, int, error) {
+	args := []any{filter.Name, filter.ChangedBy, nullTime(filter.From), nullTime(filter.To)}
+
+	var total int
+	if err := p.DB.QueryRow(countDeductionChangesSQL, args...).Scan(&total); err != nil {
+		return nil, 0, ErrCannotQueryDeductionChange
+	}
+
+	rows, err := p.DB.Query(selectDeductionChangesSQL, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, ErrCannotQueryDeductionChange
+	}
+	defer rows.Close()
+
+	result := make([]deduction.Change, 0)
+	for rows.Next() {
+		var ch deduction.Change
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.OldAmount, &ch.NewAmount, &ch.ChangedBy, &ch.ChangedAt, &ch.SourceIP, &ch.RequestID); err != nil {
+			return nil, 0, ErrCannotScanDeductionChange
+		}
+		result = append(result, ch)
+	}
+	return result, total, nil
 }
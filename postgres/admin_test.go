@@ -3,51 +3,177 @@
 package postgres
 
 import (
+	"database/sql"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golfz/assessment-tax/deduction"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
-func TestSetPersonalDeduction_Success(t *testing.T) {
-	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+var dueScheduleColumns = []string{"id", "name", "amount", "effective_from", "created_by", "source_ip", "request_id"}
 
-	mock.ExpectExec("^UPDATE (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
-	pg := Postgres{DB: db}
+func expectDueSchedules(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WillReturnRows(sqlmock.NewRows(dueScheduleColumns))
+}
 
-	// Act
-	err = pg.SetPersonalDeduction(60000.00)
+func expectDueSchedulesApplied(mock sqlmock.Sqlmock) {
+	expectDueSchedules(mock)
+	mock.ExpectCommit()
+}
 
-	// Assert
-	assert.NoError(t, err)
-	_ = mock.ExpectationsWereMet()
+var actor = deduction.Actor{Username: "admin", SourceIP: "192.0.2.1", RequestID: "req-1"}
+
+func TestSetDeduction(t *testing.T) {
+	t.Run("known deduction", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectDueSchedules(mock)
+		mock.ExpectExec("^WITH old AS (.+) INSERT INTO deduction_changes").WithArgs(90000.00, "donation", "admin", "192.0.2.1", "req-1", sql.NullTime{}).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeduction("donation", 90000.00, actor)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("store error; expect rolled back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectDueSchedules(mock)
+		mock.ExpectExec("^WITH old AS (.+) INSERT INTO deduction_changes").WillReturnError(errors.New("unexpected error"))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeduction("personal", 60000.00, actor)
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no row updated; expect not found", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectDueSchedules(mock)
+		mock.ExpectExec("^WITH old AS (.+) INSERT INTO deduction_changes").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeduction("unknown", 1, actor)
+
+		// Assert
+		assert.ErrorIs(t, err, deduction.ErrDeductionNotFound)
+	})
 }
 
-func TestSetPersonalDeduction_Error(t *testing.T) {
-	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+func TestSetDeductions(t *testing.T) {
+	amounts := map[string]float64{"personal": 70000.00, "donation": 90000.00}
 
-	mock.ExpectExec("^UPDATE (.+)").WillReturnError(errors.New("unexpected error"))
-	pg := Postgres{DB: db}
+	t.Run("all deductions updated in one transaction", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WillReturnRows(sqlmock.NewRows(dueScheduleColumns))
+		mock.ExpectExec("INSERT INTO deduction_changes").WithArgs(90000.00, "donation", "admin", "192.0.2.1", "req-1", sql.NullTime{}).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO deduction_changes").WithArgs(70000.00, "personal", "admin", "192.0.2.1", "req-1", sql.NullTime{}).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
 
-	// Act
-	err = pg.SetPersonalDeduction(60000.00)
+		// Act
+		err = pg.SetDeductions(amounts, actor)
 
-	// Assert
-	assert.Error(t, err)
-	_ = mock.ExpectationsWereMet()
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("exec error rolls back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WillReturnRows(sqlmock.NewRows(dueScheduleColumns))
+		mock.ExpectExec("INSERT INTO deduction_changes").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO deduction_changes").WillReturnError(errors.New("unexpected error"))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeductions(amounts, actor)
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown deduction rolls back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WillReturnRows(sqlmock.NewRows(dueScheduleColumns))
+		mock.ExpectExec("INSERT INTO deduction_changes").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeductions(map[string]float64{"unknown": 1}, actor)
+
+		// Assert
+		assert.ErrorIs(t, err, deduction.ErrDeductionNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin().WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetDeductions(amounts, actor)
+
+		// Assert
+		assert.Error(t, err)
+	})
 }
 
-func TestSetKReceiptDeduction_Success(t *testing.T) {
+func TestGetDeductionSettings_Success(t *testing.T) {
 	// Arrange
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -55,32 +181,195 @@ func TestSetKReceiptDeduction_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectExec("^UPDATE (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	updatedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT (.+) FROM deductions d LEFT JOIN LATERAL (.+) FROM deduction_schedules (.+) ORDER BY d.name`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "amount", "updated_at", "updated_by"}).
+			AddRow("donation", "100000.00", updatedAt, "").
+			AddRow("personal", "70000.00", updatedAt, "admin"))
 	pg := Postgres{DB: db}
 
 	// Act
-	err = pg.SetKReceiptDeduction(70000.00)
+	got, err := pg.GetDeductionSettings()
 
 	// Assert
 	assert.NoError(t, err)
-	_ = mock.ExpectationsWereMet()
+	assert.Equal(t, []deduction.Setting{
+		{Name: "donation", Amount: 100_000, UpdatedAt: updatedAt},
+		{Name: "personal", Amount: 70_000, UpdatedAt: updatedAt, UpdatedBy: "admin"},
+	}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestSetKReceiptDeduction_Error(t *testing.T) {
-	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+func TestGetDeductionSettings_Error(t *testing.T) {
+	t.Run("query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT (.+) FROM deductions`).WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
 
-	mock.ExpectExec("^UPDATE (.+)").WillReturnError(errors.New("unexpected error"))
-	pg := Postgres{DB: db}
+		// Act
+		got, err := pg.GetDeductionSettings()
 
-	// Act
-	err = pg.SetKReceiptDeduction(60000.00)
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQueryDeduction)
+		assert.Nil(t, got)
+	})
 
-	// Assert
-	assert.Error(t, err)
-	_ = mock.ExpectationsWereMet()
+	t.Run("scan error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT (.+) FROM deductions`).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "amount", "updated_at", "updated_by"}).
+				AddRow("personal", "invalid", time.Now(), ""))
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.GetDeductionSettings()
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotScanDeduction)
+		assert.Nil(t, got)
+	})
+}
+
+func TestGetDeductionSetting(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		updatedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+		mock.ExpectQuery(`SELECT (.+) FROM deductions d (.+) WHERE d.name = \$1`).WithArgs("k-receipt").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "amount", "updated_at", "updated_by"}).
+				AddRow("k-receipt", "50000.00", updatedAt, "admin"))
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.GetDeductionSetting("k-receipt")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, deduction.Setting{Name: "k-receipt", Amount: 50_000, UpdatedAt: updatedAt, UpdatedBy: "admin"}, got)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT (.+) FROM deductions`).WithArgs("unknown").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "amount", "updated_at", "updated_by"}))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetDeductionSetting("unknown")
+
+		// Assert
+		assert.ErrorIs(t, err, deduction.ErrDeductionNotFound)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT (.+) FROM deductions`).WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetDeductionSetting("personal")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQueryDeduction)
+	})
+}
+
+func TestGetDeductionChanges(t *testing.T) {
+	columns := []string{"id", "name", "old_amount", "new_amount", "changed_by", "changed_at", "source_ip", "request_id"}
+	changedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("filtered page; expect changes and total", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM deduction_changes`).
+			WithArgs("personal", "", sql.NullTime{Time: from, Valid: true}, sql.NullTime{}).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(`SELECT id, (.+) FROM deduction_changes (.+) ORDER BY changed_at DESC, id DESC LIMIT \$5 OFFSET \$6`).
+			WithArgs("personal", "", sql.NullTime{Time: from, Valid: true}, sql.NullTime{}, 1, 2).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "personal", "60000.00", "70000.00", "admin", changedAt, "192.0.2.1", "req-1"))
+		pg := Postgres{DB: db}
+
+		// Act
+		got, total, err := pg.GetDeductionChanges(deduction.ChangeFilter{Name: "personal", From: from, Limit: 1, Offset: 2})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []deduction.Change{{
+			ID:        7,
+			Name:      "personal",
+			OldAmount: 60_000,
+			NewAmount: 70_000,
+			ChangedBy: "admin",
+			ChangedAt: changedAt,
+			SourceIP:  "192.0.2.1",
+			RequestID: "req-1",
+		}}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("count error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT count`).WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, _, err = pg.GetDeductionChanges(deduction.ChangeFilter{Limit: 10})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQueryDeductionChange)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT count`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(`SELECT id`).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "personal", "invalid", "70000.00", "admin", changedAt, "", ""))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, _, err = pg.GetDeductionChanges(deduction.ChangeFilter{Limit: 10})
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotScanDeductionChange)
+	})
 }
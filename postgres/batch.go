@@ -0,0 +1,323 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/tax"
+	"github.com/lib/pq"
+	"io"
+	"time"
+)
+
+var (
+	ErrCannotQueryBatch = errors.New("unable to query batch")
+	ErrCannotScanBatch  = errors.New("unable to scan batch")
+)
+
+// batchChunkBytes is the size of the pieces an uploaded file is stored in, so
+// that neither storing nor reading it holds the whole file in memory.
+const batchChunkBytes = 1024 * 1024
+
+const batchColumns = `id, status, file_name,
+		allow_unknown_columns, pass_through_columns, strict, encoding, delimiter, xlsx, sheet,
+		personal_deduction, k_receipt_deduction, donation_deduction,
+		total_rows, processed_rows, succeeded_rows, failed_rows, checkpoint_row,
+		error, owner, created_at, updated_at, completed_at`
+
+const (
+	insertBatchSQL = `INSERT INTO tax_batches (id, status, file_name,
+		allow_unknown_columns, pass_through_columns, strict, encoding, delimiter, xlsx, sheet,
+		personal_deduction, k_receipt_deduction, donation_deduction,
+		created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+	insertBatchChunkSQL = `INSERT INTO tax_batch_chunks (batch_id, seq, data) VALUES ($1, $2, $3)`
+	selectBatchSQL      = `SELECT ` + batchColumns + ` FROM tax_batches WHERE id = $1`
+	existsBatchSQL      = `SELECT EXISTS (SELECT 1 FROM tax_batches WHERE id = $1)`
+	selectBatchChunkSQL = `SELECT data FROM tax_batch_chunks WHERE batch_id = $1 AND seq = $2`
+	// claimBatchSQL takes the oldest pending batch, or running one whose
+	// owner has stopped renewing its lease. SKIP LOCKED lets instances
+	// claiming at the same time each take a different batch.
+	claimBatchSQL = `UPDATE tax_batches SET status = $3, owner = $1, lease_until = $2
+		WHERE id = (SELECT id FROM tax_batches
+			WHERE status = $4 OR (status = $3 AND (lease_until IS NULL OR lease_until < now()))
+			ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING ` + batchColumns
+	renewBatchLeaseSQL = `UPDATE tax_batches SET lease_until = $3 WHERE id = $1 AND owner = $2 AND status = $4`
+	releaseBatchSQL    = `UPDATE tax_batches SET status = $3, lease_until = NULL, updated_at = now()
+		WHERE id = $1 AND owner = $2 AND status = $4`
+	cancelBatchSQL = `UPDATE tax_batches SET status = $2, lease_until = NULL, updated_at = $3, completed_at = $3
+		WHERE id = $1 AND status IN ($4, $5)
+		RETURNING ` + batchColumns
+	// a batch is only updated by the instance that owns it, while it runs
+	updateBatchSQL = `UPDATE tax_batches SET status = $2,
+		total_rows = $3, processed_rows = $4, succeeded_rows = $5, failed_rows = $6, checkpoint_row = $7,
+		error = $8, updated_at = $9, completed_at = $10
+		WHERE id = $1 AND owner = $11 AND status = $12`
+	upsertBatchResultSQL = `INSERT INTO tax_batch_results (batch_id, row_number, tax, tax_levels, errors) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (batch_id, row_number) DO UPDATE SET tax = EXCLUDED.tax, tax_levels = EXCLUDED.tax_levels, errors = EXCLUDED.errors`
+	selectBatchResultsSQL = `SELECT row_number, tax, tax_levels, errors FROM tax_batch_results
+		WHERE batch_id = $1 AND row_number > $2 ORDER BY row_number LIMIT $3`
+)
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// CreateBatch stores a batch and its file, read from content a chunk at a
+// time, in one transaction.
+func (p *Postgres) CreateBatch(b tax.Batch, content io.Reader) error {
+	passThroughColumns := b.Options.PassThroughColumns
+	if passThroughColumns == nil {
+		// a nil array is stored as NULL
+		passThroughColumns = []string{}
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(insertBatchSQL, b.ID, b.Status, b.FileName,
+		b.Options.AllowUnknownColumns, pq.Array(passThroughColumns), b.Options.Strict, b.Options.Encoding, b.Options.Delimiter, b.Options.XLSX, b.Options.Sheet,
+		b.Deduction.Personal, b.Deduction.KReceipt, b.Deduction.Donation,
+		b.CreatedAt, b.UpdatedAt)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	chunk := make([]byte, batchChunkBytes)
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(content, chunk)
+		if n > 0 {
+			if _, err := tx.Exec(insertBatchChunkSQL, b.ID, seq, chunk[:n]); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func scanBatch(row scanner) (tax.Batch, error) {
+	var b tax.Batch
+	var completedAt sql.NullTime
+	err := row.Scan(&b.ID, &b.Status, &b.FileName,
+		&b.Options.AllowUnknownColumns, pq.Array(&b.Options.PassThroughColumns), &b.Options.Strict, &b.Options.Encoding, &b.Options.Delimiter, &b.Options.XLSX, &b.Options.Sheet,
+		&b.Deduction.Personal, &b.Deduction.KReceipt, &b.Deduction.Donation,
+		&b.TotalRows, &b.ProcessedRows, &b.SucceededRows, &b.FailedRows, &b.CheckpointRow,
+		&b.Error, &b.Owner, &b.CreatedAt, &b.UpdatedAt, &completedAt)
+	if err != nil {
+		return tax.Batch{}, err
+	}
+	if completedAt.Valid {
+		b.CompletedAt = &completedAt.Time
+	}
+	if len(b.Options.PassThroughColumns) == 0 {
+		b.Options.PassThroughColumns = nil
+	}
+	return b, nil
+}
+
+func (p *Postgres) GetBatch(id string) (tax.Batch, error) {
+	b, err := scanBatch(p.DB.QueryRow(selectBatchSQL, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return tax.Batch{}, tax.ErrBatchNotFound
+	}
+	if err != nil {
+		return tax.Batch{}, ErrCannotQueryBatch
+	}
+	return b, nil
+}
+
+// batchContentReader reads the file of a batch one chunk at a time.
+type batchContentReader struct {
+	db    *sql.DB
+	id    string
+	seq   int
+	chunk []byte
+}
+
+func (r *batchContentReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		err := r.db.QueryRow(selectBatchChunkSQL, r.id, r.seq).Scan(&r.chunk)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, ErrCannotQueryBatch
+		}
+		r.seq++
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (p *Postgres) GetBatchContent(id string) (io.Reader, error) {
+	var exists bool
+	if err := p.DB.QueryRow(existsBatchSQL, id).Scan(&exists); err != nil {
+		return nil, ErrCannotQueryBatch
+	}
+	if !exists {
+		return nil, tax.ErrBatchNotFound
+	}
+	return &batchContentReader{db: p.DB, id: id}, nil
+}
+
+// ClaimBatch marks the next batch to calculate as running on owner until
+// leaseUntil.
+func (p *Postgres) ClaimBatch(owner string, leaseUntil time.Time) (tax.Batch, error) {
+	b, err := scanBatch(p.DB.QueryRow(claimBatchSQL, owner, leaseUntil, tax.BatchStatusRunning, tax.BatchStatusPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return tax.Batch{}, tax.ErrNoBatchToClaim
+	}
+	if err != nil {
+		return tax.Batch{}, ErrCannotQueryBatch
+	}
+	return b, nil
+}
+
+// ownedBatch turns an update that matched no row into ErrBatchLeaseLost: the
+// batch has been claimed by another owner since.
+func ownedBatch(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return tax.ErrBatchLeaseLost
+	}
+	return nil
+}
+
+func (p *Postgres) RenewBatchLease(id, owner string, leaseUntil time.Time) error {
+	return ownedBatch(p.DB.Exec(renewBatchLeaseSQL, id, owner, leaseUntil, tax.BatchStatusRunning))
+}
+
+// ReleaseBatch puts a running batch back to pending, as it was saved at its
+// last checkpoint, for any instance to claim.
+func (p *Postgres) ReleaseBatch(id, owner string) error {
+	return ownedBatch(p.DB.Exec(releaseBatchSQL, id, owner, tax.BatchStatusPending, tax.BatchStatusRunning))
+}
+
+// CancelBatch marks a pending or running batch as cancelled. Its owner, if
+// any, loses the lease and stops at its next update.
+func (p *Postgres) CancelBatch(id string) (tax.Batch, error) {
+	b, err := scanBatch(p.DB.QueryRow(cancelBatchSQL, id, tax.BatchStatusCancelled, time.Now(), tax.BatchStatusPending, tax.BatchStatusRunning))
+	if errors.Is(err, sql.ErrNoRows) {
+		// the batch is either finished or missing
+		if _, err := p.GetBatch(id); err != nil {
+			return tax.Batch{}, err
+		}
+		return tax.Batch{}, tax.ErrBatchFinished
+	}
+	if err != nil {
+		return tax.Batch{}, ErrCannotQueryBatch
+	}
+	return b, nil
+}
+
+func updateBatch(db execer, b tax.Batch) error {
+	return ownedBatch(db.Exec(updateBatchSQL, b.ID, b.Status,
+		b.TotalRows, b.ProcessedRows, b.SucceededRows, b.FailedRows, b.CheckpointRow,
+		b.Error, b.UpdatedAt, b.CompletedAt, b.Owner, tax.BatchStatusRunning))
+}
+
+func (p *Postgres) UpdateBatch(b tax.Batch) error {
+	return updateBatch(p.DB, b)
+}
+
+// marshalNullable returns the json of v, or an untyped nil to store NULL.
+func marshalNullable(v any, isNull bool) (any, error) {
+	if isNull {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// SaveBatchCheckpoint stores the results and the progress of a batch in one
+// transaction.
+func (p *Postgres) SaveBatchCheckpoint(b tax.Batch, results []tax.BatchRowResult) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		taxJSON, err := marshalNullable(result.Tax, result.Tax == nil)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		levelsJSON, err := marshalNullable(result.TaxLevels, len(result.TaxLevels) == 0)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		errorsJSON, err := marshalNullable(result.Errors, len(result.Errors) == 0)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(upsertBatchResultSQL, b.ID, result.Row, taxJSON, levelsJSON, errorsJSON); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err := updateBatch(tx, b); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetBatchResults returns a page of the results of a batch: up to limit rows
+// after the row afterRow.
+func (p *Postgres) GetBatchResults(id string, afterRow, limit int) ([]tax.BatchRowResult, error) {
+	rows, err := p.DB.Query(selectBatchResultsSQL, id, afterRow, limit)
+	if err != nil {
+		return nil, ErrCannotQueryBatch
+	}
+	defer rows.Close()
+
+	result := make([]tax.BatchRowResult, 0)
+	for rows.Next() {
+		var r tax.BatchRowResult
+		var taxJSON, levelsJSON, errorsJSON []byte
+		if err := rows.Scan(&r.Row, &taxJSON, &levelsJSON, &errorsJSON); err != nil {
+			return nil, ErrCannotScanBatch
+		}
+		if taxJSON != nil {
+			r.Tax = &tax.CsvTaxRecord{}
+			if err := json.Unmarshal(taxJSON, r.Tax); err != nil {
+				return nil, ErrCannotScanBatch
+			}
+		}
+		if levelsJSON != nil {
+			if err := json.Unmarshal(levelsJSON, &r.TaxLevels); err != nil {
+				return nil, ErrCannotScanBatch
+			}
+		}
+		if errorsJSON != nil {
+			if err := json.Unmarshal(errorsJSON, &r.Errors); err != nil {
+				return nil, ErrCannotScanBatch
+			}
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
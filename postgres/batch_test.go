@@ -0,0 +1,478 @@
+//go:build unit
+
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/tax"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+var batchColumnNames = []string{"id", "status", "file_name",
+	"allow_unknown_columns", "pass_through_columns", "strict", "encoding", "delimiter", "xlsx", "sheet",
+	"personal_deduction", "k_receipt_deduction", "donation_deduction",
+	"total_rows", "processed_rows", "succeeded_rows", "failed_rows", "checkpoint_row",
+	"error", "owner", "created_at", "updated_at", "completed_at"}
+
+func TestCreateBatch(t *testing.T) {
+	now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	b := tax.Batch{
+		ID:        "b1",
+		Status:    tax.BatchStatusPending,
+		FileName:  "taxes.csv",
+		Options:   tax.BatchOptions{Strict: true, Encoding: "tis-620", Delimiter: ";"},
+		Deduction: deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	t.Run("batch and file chunks stored together", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		content := strings.Repeat("a", batchChunkBytes) + "totalIncome"
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO tax_batches").
+			WithArgs("b1", "pending", "taxes.csv", false, "{}", true, "tis-620", ";", false, "", 60_000.0, 50_000.0, 100_000.0, now, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO tax_batch_chunks").
+			WithArgs("b1", 0, []byte(content[:batchChunkBytes])).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO tax_batch_chunks").
+			WithArgs("b1", 1, []byte("totalIncome")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.CreateBatch(b, strings.NewReader(content))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("upload cannot be read; expect rollback", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO tax_batches").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.CreateBatch(b, iotest.ErrReader(errors.New("connection reset")))
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBatch(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(batchColumnNames).AddRow("b1", "completed", "taxes.xlsx",
+			true, "{department,name}", false, "", ",", true, "payroll",
+			"60000.00", "50000.00", "100000.00",
+			3, 3, 2, 1, 4,
+			"", "runner-1", now, now, now)
+		mock.ExpectQuery("^SELECT id, status, file_name").WithArgs("b1").WillReturnRows(rows)
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.GetBatch("b1")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, tax.Batch{
+			ID:            "b1",
+			Status:        tax.BatchStatusCompleted,
+			FileName:      "taxes.xlsx",
+			Options:       tax.BatchOptions{AllowUnknownColumns: true, PassThroughColumns: []string{"department", "name"}, Delimiter: ",", XLSX: true, Sheet: "payroll"},
+			Deduction:     deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0},
+			TotalRows:     3,
+			ProcessedRows: 3,
+			SucceededRows: 2,
+			FailedRows:    1,
+			CheckpointRow: 4,
+			Owner:         "runner-1",
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			CompletedAt:   &now,
+		}, got)
+	})
+
+	t.Run("no rows; expect batch not found", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT id, status, file_name").WillReturnError(sql.ErrNoRows)
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetBatch("missing")
+
+		// Assert
+		assert.ErrorIs(t, err, tax.ErrBatchNotFound)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT id, status, file_name").WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetBatch("b1")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQueryBatch)
+	})
+}
+
+func TestGetBatchContent(t *testing.T) {
+	t.Run("chunks read in order", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("b1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("^SELECT data FROM tax_batch_chunks").WithArgs("b1", 0).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("total")))
+		mock.ExpectQuery("^SELECT data FROM tax_batch_chunks").WithArgs("b1", 1).WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("Income")))
+		mock.ExpectQuery("^SELECT data FROM tax_batch_chunks").WithArgs("b1", 2).WillReturnRows(sqlmock.NewRows([]string{"data"}))
+		pg := Postgres{DB: db}
+
+		// Act
+		r, err := pg.GetBatchContent("b1")
+		got, readErr := io.ReadAll(r)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, readErr)
+		assert.Equal(t, []byte("totalIncome"), got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("batch not found", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("b1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetBatchContent("b1")
+
+		// Assert
+		assert.ErrorIs(t, err, tax.ErrBatchNotFound)
+	})
+
+	t.Run("chunk query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("b1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("^SELECT data FROM tax_batch_chunks").WithArgs("b1", 0).WillReturnError(errors.New("connection reset"))
+		pg := Postgres{DB: db}
+
+		// Act
+		r, err := pg.GetBatchContent("b1")
+		_, readErr := io.ReadAll(r)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.ErrorIs(t, readErr, ErrCannotQueryBatch)
+	})
+}
+
+func TestClaimBatch(t *testing.T) {
+	leaseUntil := time.Date(2024, 1, 12, 0, 1, 0, 0, time.UTC)
+
+	t.Run("oldest claimable batch marked running", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(batchColumnNames).AddRow("b1", "running", "taxes.csv",
+			false, "{}", false, "", ",", false, "",
+			"60000.00", "50000.00", "100000.00",
+			3, 1, 1, 0, 2,
+			"", "runner-1", now, now, nil)
+		mock.ExpectQuery("^UPDATE tax_batches SET status = (.+) FOR UPDATE SKIP LOCKED").
+			WithArgs("runner-1", leaseUntil, "running", "pending").
+			WillReturnRows(rows)
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.ClaimBatch("runner-1", leaseUntil)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "b1", got.ID)
+		assert.Equal(t, tax.BatchStatusRunning, got.Status)
+		assert.Equal(t, "runner-1", got.Owner)
+		assert.Equal(t, 2, got.CheckpointRow)
+	})
+
+	t.Run("nothing to claim", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^UPDATE tax_batches SET status").WillReturnRows(sqlmock.NewRows(batchColumnNames))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.ClaimBatch("runner-1", leaseUntil)
+
+		// Assert
+		assert.ErrorIs(t, err, tax.ErrNoBatchToClaim)
+	})
+}
+
+func TestRenewBatchLease(t *testing.T) {
+	leaseUntil := time.Date(2024, 1, 12, 0, 1, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{name: "still owned; expect renewed", affected: 1},
+		{name: "claimed by another owner; expect lease lost", affected: 0, wantErr: tax.ErrBatchLeaseLost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectExec("^UPDATE tax_batches SET lease_until").
+				WithArgs("b1", "runner-1", leaseUntil, "running").
+				WillReturnResult(sqlmock.NewResult(0, tc.affected))
+			pg := Postgres{DB: db}
+
+			// Act
+			err = pg.RenewBatchLease("b1", "runner-1", leaseUntil)
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReleaseBatch(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("^UPDATE tax_batches SET status").
+		WithArgs("b1", "runner-1", "pending", "running").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	pg := Postgres{DB: db}
+
+	// Act
+	err = pg.ReleaseBatch("b1", "runner-1")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelBatch(t *testing.T) {
+	now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	batchRow := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows(batchColumnNames).AddRow("b1", status, "taxes.csv",
+			false, "{}", false, "", ",", false, "",
+			"60000.00", "50000.00", "100000.00",
+			3, 1, 1, 0, 1,
+			"", "runner-1", now, now, now)
+	}
+
+	t.Run("running batch; expect cancelled", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^UPDATE tax_batches SET status = (.+) WHERE id = (.+) AND status IN").
+			WithArgs("b1", "cancelled", sqlmock.AnyArg(), "pending", "running").
+			WillReturnRows(batchRow("cancelled"))
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.CancelBatch("b1")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, tax.BatchStatusCancelled, got.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("finished batch; expect ErrBatchFinished", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^UPDATE tax_batches SET status").WillReturnRows(sqlmock.NewRows(batchColumnNames))
+		mock.ExpectQuery("^SELECT (.+) FROM tax_batches WHERE id").WithArgs("b1").WillReturnRows(batchRow("completed"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.CancelBatch("b1")
+
+		// Assert
+		assert.ErrorIs(t, err, tax.ErrBatchFinished)
+	})
+
+	t.Run("missing batch; expect ErrBatchNotFound", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^UPDATE tax_batches SET status").WillReturnRows(sqlmock.NewRows(batchColumnNames))
+		mock.ExpectQuery("^SELECT (.+) FROM tax_batches WHERE id").WithArgs("b1").WillReturnRows(sqlmock.NewRows(batchColumnNames))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.CancelBatch("b1")
+
+		// Assert
+		assert.ErrorIs(t, err, tax.ErrBatchNotFound)
+	})
+}
+
+func TestSaveBatchCheckpoint(t *testing.T) {
+	now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	b := tax.Batch{ID: "b1", Status: tax.BatchStatusRunning, TotalRows: 2, ProcessedRows: 2, SucceededRows: 1, FailedRows: 1, CheckpointRow: 3, Owner: "runner-1", UpdatedAt: now}
+	results := []tax.BatchRowResult{
+		{Row: 2, Tax: &tax.CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}, TaxLevels: []float64{0, 29_000.0, 0, 0, 0}},
+		{Row: 3, Errors: []tax.CsvRowError{{Row: 3, Reason: "cannot parsing data"}}},
+	}
+
+	t.Run("results and progress saved in one transaction", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO tax_batch_results").
+			WithArgs("b1", 2, []byte(`{"row":2,"totalIncome":500000,"tax":29000}`), []byte(`[0,29000,0,0,0]`), nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO tax_batch_results").
+			WithArgs("b1", 3, nil, nil, []byte(`[{"row":3,"reason":"cannot parsing data"}]`)).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("^UPDATE tax_batches").
+			WithArgs("b1", "running", 2, 2, 1, 1, 3, "", now, nil, "runner-1", "running").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SaveBatchCheckpoint(b, results)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update error rolls back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO tax_batch_results").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO tax_batch_results").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("^UPDATE tax_batches").WillReturnError(errors.New("unexpected error"))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SaveBatchCheckpoint(b, results)
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBatchResults(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	rows := sqlmock.NewRows([]string{"row_number", "tax", "tax_levels", "errors"}).
+		AddRow(2, []byte(`{"row":2,"totalIncome":500000,"tax":29000}`), []byte(`[0,29000,0,0,0]`), nil).
+		AddRow(3, nil, nil, []byte(`[{"row":3,"reason":"cannot parsing data"}]`))
+	mock.ExpectQuery("^SELECT row_number, tax, tax_levels, errors FROM tax_batch_results").WithArgs("b1", 1, 500).WillReturnRows(rows)
+	pg := Postgres{DB: db}
+
+	// Act
+	got, err := pg.GetBatchResults("b1", 1, 500)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []tax.BatchRowResult{
+		{Row: 2, Tax: &tax.CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}, TaxLevels: []float64{0, 29_000.0, 0, 0, 0}},
+		{Row: 3, Errors: []tax.CsvRowError{{Row: 3, Reason: "cannot parsing data"}}},
+	}, got)
+}
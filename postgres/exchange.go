@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/golfz/assessment-tax/exchange"
+	"time"
+)
+
+var (
+	ErrCannotQueryExchangeRate = errors.New("unable to query exchange rate")
+	ErrCannotScanExchangeRate  = errors.New("unable to scan exchange rate")
+)
+
+const (
+	selectExchangeRateSQL = `SELECT currency, rate_date, rate FROM exchange_rates
+		WHERE currency = $1 AND rate_date <= $2 AND rate_date >= $2::date - $3::integer
+		ORDER BY rate_date DESC LIMIT 1`
+	selectExchangeRatesSQL = `SELECT currency, rate_date, rate FROM exchange_rates
+		WHERE ($1::text = '' OR currency = $1::text) ORDER BY currency, rate_date`
+	upsertExchangeRateSQL = `INSERT INTO exchange_rates (currency, rate_date, rate) VALUES ($1, $2, $3)
+		ON CONFLICT (currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate`
+)
+
+// GetExchangeRate returns the latest rate of the currency published on or
+// before date, so that payments made on weekends and holidays use the rate of
+// the previous business day. A rate older than ExchangeRateMaxAgeDays is
+// not used: a gap in the published rates is reported as not found rather
+// than converted at a stale rate.
+func (p *Postgres) GetExchangeRate(currency string, date time.Time) (exchange.Rate, error) {
+	var rate exchange.Rate
+	err := p.DB.QueryRow(selectExchangeRateSQL, currency, date, p.ExchangeRateMaxAgeDays).Scan(&rate.Currency, &rate.Date, &rate.Rate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return exchange.Rate{}, exchange.ErrRateNotFound
+	}
+	if err != nil {
+		return exchange.Rate{}, ErrCannotQueryExchangeRate
+	}
+	return rate, nil
+}
+
+func (p *Postgres) GetExchangeRates(currency string) ([]exchange.Rate, error) {
+	rows, err := p.DB.Query(selectExchangeRatesSQL, currency)
+	if err != nil {
+		return nil, ErrCannotQueryExchangeRate
+	}
+	defer rows.Close()
+
+	result := make([]exchange.Rate, 0)
+	for rows.Next() {
+		var rate exchange.Rate
+		if err := rows.Scan(&rate.Currency, &rate.Date, &rate.Rate); err != nil {
+			return nil, ErrCannotScanExchangeRate
+		}
+		result = append(result, rate)
+	}
+	return result, nil
+}
+
+// SetExchangeRates inserts or replaces all rates in one transaction.
+func (p *Postgres) SetExchangeRates(rates []exchange.Rate) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, rate := range rates {
+		if _, err := tx.Exec(upsertExchangeRateSQL, rate.Currency, rate.Date, rate.Rate); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,215 @@
+//go:build unit
+
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetExchangeRate_Success(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	date := time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)
+	rateDate := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"currency", "rate_date", "rate"}).AddRow("USD", rateDate, "35.5")
+	mock.ExpectQuery(`SELECT currency, rate_date, rate FROM exchange_rates`).WithArgs("USD", date, 3).WillReturnRows(rows)
+	pg := Postgres{DB: db, ExchangeRateMaxAgeDays: 3}
+
+	// Act
+	got, err := pg.GetExchangeRate("USD", date)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, exchange.Rate{Currency: "USD", Date: rateDate, Rate: 35.5}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExchangeRate_Error(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "no rows; expect rate not found", err: sql.ErrNoRows, wantErr: exchange.ErrRateNotFound},
+		{name: "query error", err: errors.New("unexpected error"), wantErr: ErrCannotQueryExchangeRate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery(`SELECT currency, rate_date, rate FROM exchange_rates`).WillReturnError(tc.err)
+			pg := Postgres{DB: db}
+
+			// Act
+			_, err = pg.GetExchangeRate("USD", time.Now())
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestGetExchangeRate_MaxAge(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	date := time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`rate_date <= \$2 AND rate_date >= \$2::date - \$3::integer`).
+		WithArgs("USD", date, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "rate_date", "rate"}))
+	pg := Postgres{DB: db, ExchangeRateMaxAgeDays: 7}
+
+	// Act
+	_, err = pg.GetExchangeRate("USD", date)
+
+	// Assert
+	assert.ErrorIs(t, err, exchange.ErrRateNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExchangeRates(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		date := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"currency", "rate_date", "rate"}).
+			AddRow("JPY", date, "0.24").
+			AddRow("USD", date, "35.5")
+		mock.ExpectQuery(`SELECT currency, rate_date, rate FROM exchange_rates`).WithArgs("").WillReturnRows(rows)
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.GetExchangeRates("")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []exchange.Rate{
+			{Currency: "JPY", Date: date, Rate: 0.24},
+			{Currency: "USD", Date: date, Rate: 35.5},
+		}, got)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery(`SELECT currency, rate_date, rate FROM exchange_rates`).WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetExchangeRates("USD")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQueryExchangeRate)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		rows := sqlmock.NewRows([]string{"currency", "rate_date", "rate"}).AddRow("USD", "not a date", "abc")
+		mock.ExpectQuery(`SELECT currency, rate_date, rate FROM exchange_rates`).WillReturnRows(rows)
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetExchangeRates("USD")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotScanExchangeRate)
+	})
+}
+
+func TestSetExchangeRates(t *testing.T) {
+	date := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	rates := []exchange.Rate{
+		{Currency: "USD", Date: date, Rate: 35.5},
+		{Currency: "JPY", Date: date, Rate: 0.24},
+	}
+
+	t.Run("all rates upserted in one transaction", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO exchange_rates").WithArgs("USD", date, 35.5).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO exchange_rates").WithArgs("JPY", date, 0.24).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetExchangeRates(rates)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("exec error rolls back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO exchange_rates").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("^INSERT INTO exchange_rates").WillReturnError(errors.New("unexpected error"))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetExchangeRates(rates)
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin().WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		err = pg.SetExchangeRates(rates)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -8,6 +8,10 @@ import (
 
 type Postgres struct {
 	DB *sql.DB
+	// ExchangeRateMaxAgeDays is how many days before a payment the rate
+	// converting it may have been published. Zero allows only a rate of the
+	// payment date itself.
+	ExchangeRateMaxAgeDays int
 }
 
 func New(databaseURL string) (*Postgres, error) {
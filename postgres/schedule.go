@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"time"
+)
+
+var (
+	ErrCannotQuerySchedule = errors.New("unable to query scheduled deduction")
+	ErrCannotScanSchedule  = errors.New("unable to scan scheduled deduction")
+)
+
+const scheduleColumns = `id, name, amount, effective_from, created_by, created_at, updated_by, updated_at, cancelled_by, cancelled_at`
+
+const (
+	selectDueSchedulesSQL = `SELECT id, name, amount, effective_from, created_by, source_ip, request_id FROM deduction_schedules
+		WHERE cancelled_at IS NULL AND applied_at IS NULL AND effective_from <= now()
+		ORDER BY effective_from, id FOR UPDATE`
+	markScheduleAppliedSQL = `UPDATE deduction_schedules SET applied_at = now() WHERE id = $1`
+	insertScheduleSQL      = `INSERT INTO deduction_schedules (name, amount, effective_from, created_by, updated_by, source_ip, request_id)
+		SELECT $1::text, $2::numeric, $3::timestamptz, $4::text, $4::text, $5::text, $6::text
+		WHERE EXISTS (SELECT 1 FROM deductions WHERE name = $1::text)
+		RETURNING ` + scheduleColumns
+	selectSchedulesSQL = `SELECT ` + scheduleColumns + ` FROM deduction_schedules
+		WHERE ($1::text = '' OR name = $1::text)
+		AND ($2::text = ''
+			OR ($2::text = 'pending' AND cancelled_at IS NULL AND effective_from > now())
+			OR ($2::text = 'applied' AND cancelled_at IS NULL AND effective_from <= now())
+			OR ($2::text = 'cancelled' AND cancelled_at IS NOT NULL))
+		ORDER BY effective_from, id`
+	selectScheduleSQL = `SELECT ` + scheduleColumns + ` FROM deduction_schedules WHERE id = $1`
+	// a schedule can only be changed while pending; pendingScheduleSQL is the
+	// condition, checked in the same statement as the change
+	pendingScheduleSQL = ` AND cancelled_at IS NULL AND applied_at IS NULL AND effective_from > now()`
+	updateScheduleSQL  = `UPDATE deduction_schedules SET amount = $2, effective_from = $3, updated_by = $4, updated_at = now()
+		WHERE id = $1` + pendingScheduleSQL + ` RETURNING ` + scheduleColumns
+	cancelScheduleSQL = `UPDATE deduction_schedules SET cancelled_by = $2, cancelled_at = now()
+		WHERE id = $1` + pendingScheduleSQL + ` RETURNING ` + scheduleColumns
+)
+
+type dueSchedule struct {
+	id            int64
+	name          string
+	amount        float64
+	effectiveFrom time.Time
+	by            deduction.Actor
+}
+
+// applyDueSchedules writes the scheduled changes whose time has come to the
+// deductions table, in order, each recorded in the audit log at its
+// effective time and in the name of the admin who scheduled it. Only writes
+// need it: reads resolve scheduled changes in their own query.
+func applyDueSchedules(tx *sql.Tx) error {
+	rows, err := tx.Query(selectDueSchedulesSQL)
+	if err != nil {
+		return err
+	}
+	due := make([]dueSchedule, 0)
+	for rows.Next() {
+		var s dueSchedule
+		if err := rows.Scan(&s.id, &s.name, &s.amount, &s.effectiveFrom, &s.by.Username, &s.by.SourceIP, &s.by.RequestID); err != nil {
+			rows.Close()
+			return ErrCannotScanSchedule
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range due {
+		err := updateDeduction(tx, s.name, s.amount, s.by, s.effectiveFrom)
+		if err != nil && !errors.Is(err, deduction.ErrDeductionNotFound) {
+			return err
+		}
+		if _, err := tx.Exec(markScheduleAppliedSQL, s.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row scanner) (deduction.Schedule, error) {
+	var s deduction.Schedule
+	var cancelledBy sql.NullString
+	var cancelledAt sql.NullTime
+	err := row.Scan(&s.ID, &s.Name, &s.Amount, &s.EffectiveFrom, &s.CreatedBy, &s.CreatedAt, &s.UpdatedBy, &s.UpdatedAt, &cancelledBy, &cancelledAt)
+	if err != nil {
+		return deduction.Schedule{}, err
+	}
+	s.CancelledBy = cancelledBy.String
+	if cancelledAt.Valid {
+		s.CancelledAt = &cancelledAt.Time
+	}
+	return s, nil
+}
+
+// CreateSchedules schedules several changes in one transaction. Nothing is
+// scheduled when one of the deductions is not in the table.
+func (p *Postgres) CreateSchedules(schedules []deduction.Schedule, by deduction.Actor) ([]deduction.Schedule, error) {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]deduction.Schedule, 0, len(schedules))
+	for _, s := range schedules {
+		created, err := scanSchedule(tx.QueryRow(insertScheduleSQL, s.Name, s.Amount, s.EffectiveFrom, by.Username, by.SourceIP, by.RequestID))
+		if err != nil {
+			_ = tx.Rollback()
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, deduction.ErrDeductionNotFound
+			}
+			return nil, err
+		}
+		result = append(result, created)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSchedules lists the scheduled changes of a deduction, or of all of them
+// when name is empty, in the order they take effect. An empty status lists
+// every schedule.
+func (p *Postgres) GetSchedules(name string, status deduction.ScheduleStatus) ([]deduction.Schedule, error) {
+	rows, err := p.DB.Query(selectSchedulesSQL, name, string(status))
+	if err != nil {
+		return nil, ErrCannotQuerySchedule
+	}
+	defer rows.Close()
+
+	result := make([]deduction.Schedule, 0)
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, ErrCannotScanSchedule
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (p *Postgres) GetSchedule(id int64) (deduction.Schedule, error) {
+	s, err := scanSchedule(p.DB.QueryRow(selectScheduleSQL, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return deduction.Schedule{}, deduction.ErrScheduleNotFound
+	}
+	if err != nil {
+		return deduction.Schedule{}, ErrCannotQuerySchedule
+	}
+	return s, nil
+}
+
+// changeSchedule runs an update of a pending schedule and tells, when no
+// schedule was updated, whether it does not exist or is no longer pending.
+func (p *Postgres) changeSchedule(id int64, query string, args ...any) (deduction.Schedule, error) {
+	s, err := scanSchedule(p.DB.QueryRow(query, append([]any{id}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := p.GetSchedule(id); err != nil {
+			return deduction.Schedule{}, err
+		}
+		return deduction.Schedule{}, deduction.ErrScheduleNotPending
+	}
+	if err != nil {
+		return deduction.Schedule{}, err
+	}
+	return s, nil
+}
+
+func (p *Postgres) UpdateSchedule(id int64, amount float64, effectiveFrom time.Time, by deduction.Actor) (deduction.Schedule, error) {
+	return p.changeSchedule(id, updateScheduleSQL, amount, effectiveFrom, by.Username)
+}
+
+func (p *Postgres) CancelSchedule(id int64, by deduction.Actor) (deduction.Schedule, error) {
+	return p.changeSchedule(id, cancelScheduleSQL, by.Username)
+}
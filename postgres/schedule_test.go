@@ -0,0 +1,267 @@
+//go:build unit
+
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var scheduleColumnNames = []string{"id", "name", "amount", "effective_from", "created_by", "created_at", "updated_by", "updated_at", "cancelled_by", "cancelled_at"}
+
+var (
+	effectiveFrom = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	scheduledAt   = time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+)
+
+func scheduleRow(rows *sqlmock.Rows, id int64, name string, amount string) *sqlmock.Rows {
+	return rows.AddRow(id, name, amount, effectiveFrom, "admin", scheduledAt, "admin", scheduledAt, nil, nil)
+}
+
+func TestApplyDueSchedules(t *testing.T) {
+	t.Run("due schedules applied in order at their effective time", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		later := effectiveFrom.Add(24 * time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules (.+) FOR UPDATE").
+			WillReturnRows(sqlmock.NewRows(dueScheduleColumns).
+				AddRow(1, "personal", "70000.00", effectiveFrom, "scheduler", "192.0.2.9", "req-9").
+				AddRow(2, "personal", "80000.00", later, "scheduler", "192.0.2.9", "req-10"))
+		mock.ExpectExec("INSERT INTO deduction_changes").
+			WithArgs(70000.00, "personal", "scheduler", "192.0.2.9", "req-9", sql.NullTime{Time: effectiveFrom, Valid: true}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE deduction_schedules SET applied_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO deduction_changes").
+			WithArgs(80000.00, "personal", "scheduler", "192.0.2.9", "req-10", sql.NullTime{Time: later, Valid: true}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^UPDATE deduction_schedules SET applied_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		tx, _ := db.Begin()
+
+		// Act
+		err = applyDueSchedules(tx)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").
+			WillReturnRows(sqlmock.NewRows(dueScheduleColumns).AddRow(1, "personal", "70000.00", effectiveFrom, "admin", "", ""))
+		mock.ExpectExec("INSERT INTO deduction_changes").WillReturnError(errors.New("unexpected error"))
+		tx, _ := db.Begin()
+
+		// Act
+		err = applyDueSchedules(tx)
+
+		// Assert
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateSchedules(t *testing.T) {
+	schedules := []deduction.Schedule{
+		{Name: "personal", Amount: 70_000, EffectiveFrom: effectiveFrom},
+		{Name: "donation", Amount: 90_000, EffectiveFrom: effectiveFrom},
+	}
+
+	t.Run("all schedules created in one transaction", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^INSERT INTO deduction_schedules").
+			WithArgs("personal", 70000.00, effectiveFrom, "admin", "192.0.2.1", "req-1").
+			WillReturnRows(scheduleRow(sqlmock.NewRows(scheduleColumnNames), 1, "personal", "70000.00"))
+		mock.ExpectQuery("^INSERT INTO deduction_schedules").
+			WithArgs("donation", 90000.00, effectiveFrom, "admin", "192.0.2.1", "req-1").
+			WillReturnRows(scheduleRow(sqlmock.NewRows(scheduleColumnNames), 2, "donation", "90000.00"))
+		mock.ExpectCommit()
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.CreateSchedules(schedules, actor)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []deduction.Schedule{
+			{ID: 1, Name: "personal", Amount: 70_000, EffectiveFrom: effectiveFrom, CreatedBy: "admin", CreatedAt: scheduledAt, UpdatedBy: "admin", UpdatedAt: scheduledAt},
+			{ID: 2, Name: "donation", Amount: 90_000, EffectiveFrom: effectiveFrom, CreatedBy: "admin", CreatedAt: scheduledAt, UpdatedBy: "admin", UpdatedAt: scheduledAt},
+		}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown deduction rolls back", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("^INSERT INTO deduction_schedules").
+			WillReturnRows(scheduleRow(sqlmock.NewRows(scheduleColumnNames), 1, "personal", "70000.00"))
+		mock.ExpectQuery("^INSERT INTO deduction_schedules").WillReturnRows(sqlmock.NewRows(scheduleColumnNames))
+		mock.ExpectRollback()
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.CreateSchedules(schedules, actor)
+
+		// Assert
+		assert.ErrorIs(t, err, deduction.ErrDeductionNotFound)
+		assert.Nil(t, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetSchedules(t *testing.T) {
+	t.Run("filtered by name and status", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		cancelledAt := scheduledAt.Add(time.Hour)
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WithArgs("personal", "cancelled").
+			WillReturnRows(sqlmock.NewRows(scheduleColumnNames).
+				AddRow(3, "personal", "70000.00", effectiveFrom, "admin", scheduledAt, "admin", scheduledAt, "other", cancelledAt))
+		pg := Postgres{DB: db}
+
+		// Act
+		got, err := pg.GetSchedules("personal", deduction.ScheduleCancelled)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []deduction.Schedule{{
+			ID:            3,
+			Name:          "personal",
+			Amount:        70_000,
+			EffectiveFrom: effectiveFrom,
+			CreatedBy:     "admin",
+			CreatedAt:     scheduledAt,
+			UpdatedBy:     "admin",
+			UpdatedAt:     scheduledAt,
+			CancelledBy:   "other",
+			CancelledAt:   &cancelledAt,
+		}}, got)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		// Arrange
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules").WillReturnError(errors.New("unexpected error"))
+		pg := Postgres{DB: db}
+
+		// Act
+		_, err = pg.GetSchedules("", "")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCannotQuerySchedule)
+	})
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	testCases := []struct {
+		name    string
+		updated *sqlmock.Rows
+		found   *sqlmock.Rows
+		wantErr error
+	}{
+		{
+			name:    "pending schedule; expect updated",
+			updated: scheduleRow(sqlmock.NewRows(scheduleColumnNames), 1, "personal", "75000.00"),
+		},
+		{
+			name:    "schedule no longer pending",
+			updated: sqlmock.NewRows(scheduleColumnNames),
+			found:   scheduleRow(sqlmock.NewRows(scheduleColumnNames), 1, "personal", "70000.00"),
+			wantErr: deduction.ErrScheduleNotPending,
+		},
+		{
+			name:    "schedule not found",
+			updated: sqlmock.NewRows(scheduleColumnNames),
+			found:   sqlmock.NewRows(scheduleColumnNames),
+			wantErr: deduction.ErrScheduleNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("^UPDATE deduction_schedules SET amount").
+				WithArgs(int64(1), 75000.00, effectiveFrom, "admin").
+				WillReturnRows(tc.updated)
+			if tc.found != nil {
+				mock.ExpectQuery("^SELECT (.+) FROM deduction_schedules WHERE id").WithArgs(int64(1)).WillReturnRows(tc.found)
+			}
+			pg := Postgres{DB: db}
+
+			// Act
+			got, err := pg.UpdateSchedule(1, 75_000, effectiveFrom, actor)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 75_000.0, got.Amount)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCancelSchedule(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	cancelledAt := scheduledAt.Add(time.Hour)
+	mock.ExpectQuery("^UPDATE deduction_schedules SET cancelled_by").WithArgs(int64(1), "admin").
+		WillReturnRows(sqlmock.NewRows(scheduleColumnNames).
+			AddRow(1, "personal", "70000.00", effectiveFrom, "admin", scheduledAt, "admin", scheduledAt, "admin", cancelledAt))
+	pg := Postgres{DB: db}
+
+	// Act
+	got, err := pg.CancelSchedule(1, actor)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, deduction.ScheduleCancelled, got.Status(scheduledAt))
+	assert.Equal(t, "admin", got.CancelledBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"errors"
 	"github.com/golfz/assessment-tax/deduction"
+	"time"
 )
 
 var (
@@ -10,26 +11,30 @@ var (
 	ErrCannotScanDeduction  = errors.New("unable to scan deduction")
 )
 
-const (
-	namePersonalDeduction = "personal"
-	nameKReceiptDeduction = "k-receipt"
-	nameDonationDeduction = "donation"
-)
-
-func applyDeductionValue(name string, amount float64, deductionData *deduction.Deduction) {
-	switch name {
-	case namePersonalDeduction:
-		deductionData.Personal = amount
-	case nameKReceiptDeduction:
-		deductionData.KReceipt = amount
-	case nameDonationDeduction:
-		deductionData.Donation = amount
-	}
-}
-
-func (p *Postgres) GetDeduction() (deduction.Deduction, error) {
-	selectSQL := `SELECT name, amount FROM deductions`
-	rows, err := p.DB.Query(selectSQL)
+// selectDeductionAtSQL resolves the amount of each deduction in force at $1.
+// A scheduled change not yet applied to the table wins, being later than
+// any change made since; otherwise the audit log tells the amount set by the
+// last change before $1, or the amount replaced by the first one after it.
+const selectDeductionAtSQL = `SELECT d.name, COALESCE(s.amount, c.amount, d.amount) FROM deductions d
+	LEFT JOIN LATERAL (
+		SELECT amount FROM deduction_schedules
+		WHERE name = d.name AND cancelled_at IS NULL AND applied_at IS NULL AND effective_from <= $1
+		ORDER BY effective_from DESC, id DESC LIMIT 1
+	) s ON true
+	LEFT JOIN LATERAL (
+		SELECT amount FROM (
+			(SELECT new_amount AS amount, 0 AS after FROM deduction_changes
+				WHERE name = d.name AND changed_at <= $1 ORDER BY changed_at DESC, id DESC LIMIT 1)
+			UNION ALL
+			(SELECT old_amount, 1 FROM deduction_changes
+				WHERE name = d.name AND changed_at > $1 ORDER BY changed_at, id LIMIT 1)
+		) change ORDER BY after LIMIT 1
+	) c ON true`
+
+// GetDeduction returns the deductions in force at asOf, taking changes
+// scheduled in advance into account.
+func (p *Postgres) GetDeduction(asOf time.Time) (deduction.Deduction, error) {
+	rows, err := p.DB.Query(selectDeductionAtSQL, asOf)
 	if err != nil {
 		return deduction.Deduction{}, ErrCannotQueryDeduction
 	}
@@ -44,7 +49,7 @@ func (p *Postgres) GetDeduction() (deduction.Deduction, error) {
 			return deduction.Deduction{}, ErrCannotScanDeduction
 		}
 
-		applyDeductionValue(name, amount, &deductionData)
+		deductionData.Set(name, amount)
 	}
 
 	return deductionData, nil
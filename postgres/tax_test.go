@@ -7,8 +7,11 @@ import (
 	"github.com/golfz/assessment-tax/deduction"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+var asOf = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
 func TestGetDeduction_Success(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -66,11 +69,11 @@ func TestGetDeduction_Success(t *testing.T) {
 				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 			}
 			defer db.Close()
-			mock.ExpectQuery(`SELECT name, amount FROM deductions`).WillReturnRows(tc.rows)
+			mock.ExpectQuery(`SELECT d.name, COALESCE\(s.amount, c.amount, d.amount\) FROM deductions d`).WithArgs(asOf).WillReturnRows(tc.rows)
 			pg := Postgres{DB: db}
 
 			// Act
-			deductionData, err := pg.GetDeduction()
+			deductionData, err := pg.GetDeduction(asOf)
 
 			// Assert
 			assert.NoError(t, err)
@@ -87,12 +90,12 @@ func TestGetDeduction_Error(t *testing.T) {
 			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 		}
 		defer db.Close()
-		mock.ExpectQuery(`SELECT name, amount FROM deductions`).WillReturnError(ErrCannotQueryDeduction)
+		mock.ExpectQuery(`FROM deductions d`).WillReturnError(ErrCannotQueryDeduction)
 		pg := Postgres{DB: db}
 		wantDeduction := deduction.Deduction{}
 
 		// Act
-		gotDeduction, err := pg.GetDeduction()
+		gotDeduction, err := pg.GetDeduction(asOf)
 
 		// Assert
 		assert.Error(t, err)
@@ -111,12 +114,12 @@ func TestGetDeduction_Error(t *testing.T) {
 			AddRow("personal", "abcdef").
 			AddRow("k-receipt", "50000.00").
 			AddRow("donation", "100000.00")
-		mock.ExpectQuery(`SELECT name, amount FROM deductions`).WillReturnRows(rows)
+		mock.ExpectQuery(`FROM deductions d`).WillReturnRows(rows)
 		pg := Postgres{DB: db}
 		wantDeduction := deduction.Deduction{}
 
 		// Act
-		gotDeduction, err := pg.GetDeduction()
+		gotDeduction, err := pg.GetDeduction(asOf)
 
 		// Assert
 		assert.Error(t, err)
@@ -11,22 +11,57 @@ import (
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
-func New(pg *postgres.Postgres, cfg *config.Config) *echo.Echo {
+func New(pg *postgres.Postgres, cfg *config.Config, batches *tax.BatchRunner) *echo.Echo {
 	e := echo.New()
+	// the client ip is taken from X-Forwarded-For only when the request came
+	// through a proxy on a private network
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 	e.Use(middleware.Logger())
+	e.Use(middleware.RequestID())
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	hTax := tax.New(pg)
+	hTax := tax.New(pg,
+		tax.WithCSVMaxBufferedRows(cfg.CSVMaxBufferedRows),
+		tax.WithCalculationWorkers(cfg.CalculationWorkers),
+		tax.WithCalculationBatchMaxItems(cfg.CalculationBatchMaxItems),
+		tax.WithZipMaxEntries(cfg.ZipMaxEntries),
+		tax.WithZipMaxUncompressedBytes(int64(cfg.ZipMaxUncompressedBytes)),
+		tax.WithBatchMaxUploadBytes(int64(cfg.BatchMaxUploadBytes)),
+		tax.WithXLSXMaxUncompressedBytes(int64(cfg.XLSXMaxUncompressedBytes)),
+		tax.WithBatchRunner(batches),
+	)
 	e.POST("/tax/calculations", hTax.CalculateTaxHandler)
+	e.POST("/tax/calculations/interim", hTax.CalculateInterimTaxHandler)
+	e.POST("/tax/calculations/amendments", hTax.CalculateAmendmentHandler)
+	e.POST("/tax/calculations/batch", hTax.CalculateBatchHandler)
 	e.POST("/tax/calculations/upload-csv", hTax.UploadCSVHandler)
+	e.POST("/tax/calculations/upload-csv/validate", hTax.ValidateCSVHandler)
+	e.GET("/tax/calculations/csv-template", hTax.CSVTemplateHandler)
+	e.POST("/tax/batches", hTax.CreateBatchHandler)
+	e.GET("/tax/batches/:id", hTax.GetBatchHandler)
+	e.GET("/tax/batches/:id/result", hTax.GetBatchResultHandler)
+	e.GET("/tax/batches/:id/events", hTax.GetBatchEventsHandler)
+	e.POST("/tax/batches/:id/cancel", hTax.CancelBatchHandler)
 
 	a := e.Group("/admin")
 	a.Use(middleware.BasicAuth(mw.BasicAuth(*cfg)))
 
 	hAdmin := admin.New(pg)
+	a.GET("/deductions", hAdmin.GetDeductionsHandler)
+	a.PATCH("/deductions", hAdmin.UpdateDeductionsHandler)
+	a.GET("/deductions/history", hAdmin.GetDeductionHistoryHandler)
+	a.GET("/deductions/schedules", hAdmin.GetDeductionSchedulesHandler)
+	a.PUT("/deductions/schedules/:id", hAdmin.UpdateDeductionScheduleHandler)
+	a.DELETE("/deductions/schedules/:id", hAdmin.CancelDeductionScheduleHandler)
+	a.GET("/deductions/:name", hAdmin.GetDeductionHandler)
 	a.POST("/deductions/personal", hAdmin.SetPersonalDeductionHandler)
 	a.POST("/deductions/k-receipt", hAdmin.SetKReceiptDeductionHandler)
+	a.POST("/deductions/donation", hAdmin.SetDonationDeductionHandler)
+	a.PUT("/deductions/:name", hAdmin.SetDeductionHandler)
+	a.GET("/exchange-rates", hAdmin.GetExchangeRatesHandler)
+	a.POST("/exchange-rates", hAdmin.SetExchangeRatesHandler)
+	a.POST("/exchange-rates/upload-csv", hAdmin.UploadExchangeRatesCSVHandler)
 
 	return e
 }
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"github.com/golfz/assessment-tax/config"
 	"github.com/golfz/assessment-tax/postgres"
+	"github.com/golfz/assessment-tax/tax"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -14,7 +15,8 @@ import (
 
 func TestNew(t *testing.T) {
 	// Arrange
-	e := New(&postgres.Postgres{DB: &sql.DB{}}, &config.Config{})
+	pg := &postgres.Postgres{DB: &sql.DB{}}
+	e := New(pg, &config.Config{}, tax.NewBatchRunner(pg, 1))
 
 	req := httptest.NewRequest(http.MethodGet, "/not/registered/uri", nil)
 	rec := httptest.NewRecorder()
@@ -26,4 +28,5 @@ func TestNew(t *testing.T) {
 	// Assert
 	assert.Greater(t, len(r), 0)
 	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.NotNil(t, e.IPExtractor)
 }
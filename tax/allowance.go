@@ -13,15 +13,24 @@ func collapseAllowance(allowances []Allowance) map[AllowanceType]float64 {
 	return result
 }
 
+// allowanceCap is the most that can be deducted for an allowance type, if it
+// is capped.
+func allowanceCap(aType AllowanceType, deduction deduction.Deduction) (float64, bool) {
+	switch aType {
+	case AllowanceTypeDonation:
+		return deduction.Donation, true
+	case AllowanceTypeKReceipt:
+		return deduction.KReceipt, true
+	}
+	return 0, false
+}
+
 func getTaxableAllowance(allowances []Allowance, deduction deduction.Deduction) map[AllowanceType]float64 {
 	result := collapseAllowance(allowances)
 
 	for aType, aAmount := range result {
-		switch aType {
-		case AllowanceTypeDonation:
-			result[aType] = math.Min(aAmount, deduction.Donation)
-		case AllowanceTypeKReceipt:
-			result[aType] = math.Min(aAmount, deduction.KReceipt)
+		if limit, ok := allowanceCap(aType, deduction); ok {
+			result[aType] = math.Min(aAmount, limit)
 		}
 	}
 	return result
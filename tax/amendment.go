@@ -0,0 +1,173 @@
+package tax
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"sort"
+)
+
+// AmendmentRequest is an original filing and its amendment. Both are
+// calculated with the deductions in force at the original's asOf: the
+// amended asOf may be left out, and must otherwise name the same time.
+type AmendmentRequest struct {
+	Original TaxInformation `json:"original"`
+	Amended  TaxInformation `json:"amended"`
+}
+
+type FieldChange struct {
+	Field      string  `json:"field"`
+	Original   float64 `json:"original"`
+	Amended    float64 `json:"amended"`
+	Difference float64 `json:"difference"`
+}
+
+type AmendmentDiff struct {
+	Inputs             []FieldChange `json:"inputs"`
+	Allowances         []FieldChange `json:"allowances"`
+	TaxLevels          []FieldChange `json:"taxLevel"`
+	SeveranceTaxLevels []FieldChange `json:"severanceTaxLevel,omitempty"`
+}
+
+type AmendmentResult struct {
+	Original          TaxResult     `json:"original"`
+	Amended           TaxResult     `json:"amended"`
+	Diff              AmendmentDiff `json:"diff"`
+	AdditionalPayment float64       `json:"additionalPayment"`
+	Refund            float64       `json:"refund"`
+}
+
+type changeCollector []FieldChange
+
+func (cc *changeCollector) add(field string, original, amended float64) {
+	if original == amended {
+		return
+	}
+	*cc = append(*cc, FieldChange{
+		Field:      field,
+		Original:   original,
+		Amended:    amended,
+		Difference: amended - original,
+	})
+}
+
+func severanceOrZero(s *Severance) Severance {
+	if s == nil {
+		return Severance{}
+	}
+	return *s
+}
+
+func sortedAllowanceTypes(maps ...map[AllowanceType]float64) []AllowanceType {
+	seen := make(map[AllowanceType]bool)
+	result := make([]AllowanceType, 0)
+	for _, m := range maps {
+		for aType := range m {
+			if !seen[aType] {
+				seen[aType] = true
+				result = append(result, aType)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func diffInputs(original, amended TaxInformation) []FieldChange {
+	changes := make(changeCollector, 0)
+	changes.add("totalIncome", original.TotalIncome, amended.TotalIncome)
+	changes.add("wht", original.WHT, amended.WHT)
+	changes.add("interimTax", original.InterimTax, amended.InterimTax)
+
+	originalClaims := collapseAllowance(original.Allowances)
+	amendedClaims := collapseAllowance(amended.Allowances)
+	for _, aType := range sortedAllowanceTypes(originalClaims, amendedClaims) {
+		changes.add("allowances."+string(aType), originalClaims[aType], amendedClaims[aType])
+	}
+
+	originalSeverance, amendedSeverance := severanceOrZero(original.Severance), severanceOrZero(amended.Severance)
+	changes.add("severance.amount", originalSeverance.Amount, amendedSeverance.Amount)
+	changes.add("severance.yearsOfService", float64(originalSeverance.YearsOfService), float64(amendedSeverance.YearsOfService))
+	changes.add("severance.lastSalary", originalSeverance.LastSalary, amendedSeverance.LastSalary)
+
+	return changes
+}
+
+func diffAllowances(original, amended TaxInformation, d deduction.Deduction) []FieldChange {
+	originalAllowances := getTaxableAllowance(original.Allowances, d)
+	amendedAllowances := getTaxableAllowance(amended.Allowances, d)
+
+	changes := make(changeCollector, 0)
+	for _, aType := range sortedAllowanceTypes(originalAllowances, amendedAllowances) {
+		changes.add(string(aType), originalAllowances[aType], amendedAllowances[aType])
+	}
+	return changes
+}
+
+func taxLevelsOf(levels []TaxLevel) map[string]float64 {
+	result := make(map[string]float64, len(levels))
+	for _, level := range levels {
+		result[level.Level] = level.Tax
+	}
+	return result
+}
+
+// diffTaxLevels compares levels in rate order; a missing side counts as zero.
+func diffTaxLevels(original, amended []TaxLevel) []FieldChange {
+	originalLevels := taxLevelsOf(original)
+	amendedLevels := taxLevelsOf(amended)
+
+	changes := make(changeCollector, 0)
+	for _, r := range rates {
+		changes.add(r.description, originalLevels[r.description], amendedLevels[r.description])
+	}
+	return changes
+}
+
+func severanceTaxLevelsOf(result TaxResult) []TaxLevel {
+	if result.Severance == nil {
+		return nil
+	}
+	return result.Severance.TaxLevels
+}
+
+func netPayable(result TaxResult) float64 {
+	return result.Tax - result.TaxRefund
+}
+
+// CalculateAmendment calculates both the original and the amended filing with
+// the same deduction and reports what changed between them.
+func CalculateAmendment(original, amended TaxInformation, d deduction.Deduction) (AmendmentResult, error) {
+	originalResult, err := CalculateTax(original, d)
+	if err != nil {
+		return AmendmentResult{}, errors.Join(err, ErrInvalidOriginal)
+	}
+
+	amendedResult, err := CalculateTax(amended, d)
+	if err != nil {
+		return AmendmentResult{}, errors.Join(err, ErrInvalidAmended)
+	}
+
+	result := AmendmentResult{
+		Original: originalResult,
+		Amended:  amendedResult,
+		Diff: AmendmentDiff{
+			Inputs:     diffInputs(original, amended),
+			Allowances: diffAllowances(original, amended, d),
+			TaxLevels:  diffTaxLevels(originalResult.TaxLevels, amendedResult.TaxLevels),
+		},
+	}
+
+	originalSeverance, amendedSeverance := severanceTaxLevelsOf(originalResult), severanceTaxLevelsOf(amendedResult)
+	if originalSeverance != nil || amendedSeverance != nil {
+		result.Diff.SeveranceTaxLevels = diffTaxLevels(originalSeverance, amendedSeverance)
+	}
+
+	difference := netPayable(amendedResult) - netPayable(originalResult)
+	if difference > 0 {
+		result.AdditionalPayment = difference
+	} else {
+		result.Refund = -difference
+	}
+
+	return result, nil
+}
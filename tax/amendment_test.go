@@ -0,0 +1,154 @@
+//go:build unit
+
+package tax
+
+import (
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCalculateAmendment_Success(t *testing.T) {
+	// Arrange
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	testCases := []struct {
+		name                  string
+		original              TaxInformation
+		amended               TaxInformation
+		wantDiff              AmendmentDiff
+		wantAdditionalPayment float64
+		wantRefund            float64
+	}{
+		{
+			name: "more allowances claimed; expect refund",
+			original: TaxInformation{
+				TotalIncome: 500_000.0,
+				Allowances:  []Allowance{{Type: AllowanceTypeDonation, Amount: 0.0}},
+			},
+			amended: TaxInformation{
+				TotalIncome: 500_000.0,
+				Allowances: []Allowance{
+					{Type: AllowanceTypeDonation, Amount: 200_000.0},
+					{Type: AllowanceTypeKReceipt, Amount: 10_000.0},
+				},
+			},
+			wantDiff: AmendmentDiff{
+				Inputs: []FieldChange{
+					{Field: "allowances.donation", Original: 0.0, Amended: 200_000.0, Difference: 200_000.0},
+					{Field: "allowances.k-receipt", Original: 0.0, Amended: 10_000.0, Difference: 10_000.0},
+				},
+				Allowances: []FieldChange{
+					{Field: "donation", Original: 0.0, Amended: 100_000.0, Difference: 100_000.0},
+					{Field: "k-receipt", Original: 0.0, Amended: 10_000.0, Difference: 10_000.0},
+				},
+				TaxLevels: []FieldChange{
+					{Field: "150,001-500,000", Original: 29_000.0, Amended: 18_000.0, Difference: -11_000.0},
+				},
+			},
+			wantRefund: 11_000.0,
+		},
+		{
+			name:     "income corrected upwards; expect additional payment",
+			original: TaxInformation{TotalIncome: 500_000.0, WHT: 29_000.0},
+			amended:  TaxInformation{TotalIncome: 600_000.0, WHT: 29_000.0},
+			wantDiff: AmendmentDiff{
+				Inputs: []FieldChange{
+					{Field: "totalIncome", Original: 500_000.0, Amended: 600_000.0, Difference: 100_000.0},
+				},
+				Allowances: []FieldChange{},
+				TaxLevels: []FieldChange{
+					{Field: "150,001-500,000", Original: 29_000.0, Amended: 35_000.0, Difference: 6_000.0},
+					{Field: "500,001-1,000,000", Original: 0.0, Amended: 6_000.0, Difference: 6_000.0},
+				},
+			},
+			wantAdditionalPayment: 12_000.0,
+		},
+		{
+			name:     "nothing changed; expect empty diff",
+			original: TaxInformation{TotalIncome: 500_000.0},
+			amended:  TaxInformation{TotalIncome: 500_000.0},
+			wantDiff: AmendmentDiff{
+				Inputs:     []FieldChange{},
+				Allowances: []FieldChange{},
+				TaxLevels:  []FieldChange{},
+			},
+		},
+		{
+			name:     "severance added; expect severance level diff",
+			original: TaxInformation{TotalIncome: 500_000.0},
+			amended: TaxInformation{
+				TotalIncome: 500_000.0,
+				Severance:   &Severance{Amount: 1_000_000.0, YearsOfService: 10, LastSalary: 30_000.0},
+			},
+			wantDiff: AmendmentDiff{
+				Inputs: []FieldChange{
+					{Field: "severance.amount", Original: 0.0, Amended: 1_000_000.0, Difference: 1_000_000.0},
+					{Field: "severance.yearsOfService", Original: 0.0, Amended: 10.0, Difference: 10.0},
+					{Field: "severance.lastSalary", Original: 0.0, Amended: 30_000.0, Difference: 30_000.0},
+				},
+				Allowances: []FieldChange{},
+				TaxLevels:  []FieldChange{},
+				SeveranceTaxLevels: []FieldChange{
+					{Field: "150,001-500,000", Original: 0.0, Amended: 11_500.0, Difference: 11_500.0},
+				},
+			},
+			wantAdditionalPayment: 11_500.0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := CalculateAmendment(tc.original, tc.amended, defaultDeduction)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantDiff, got.Diff)
+			assert.Equal(t, tc.wantAdditionalPayment, got.AdditionalPayment)
+			assert.Equal(t, tc.wantRefund, got.Refund)
+		})
+	}
+}
+
+func TestCalculateAmendment_Error(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	testCases := []struct {
+		name     string
+		original TaxInformation
+		amended  TaxInformation
+		wantErr  error
+	}{
+		{
+			name:     "invalid original",
+			original: TaxInformation{TotalIncome: -1.0},
+			amended:  TaxInformation{TotalIncome: 500_000.0},
+			wantErr:  ErrInvalidOriginal,
+		},
+		{
+			name:     "invalid amended",
+			original: TaxInformation{TotalIncome: 500_000.0},
+			amended:  TaxInformation{TotalIncome: 500_000.0, WHT: 600_000.0},
+			wantErr:  ErrInvalidAmended,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := CalculateAmendment(tc.original, tc.amended, defaultDeduction)
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.ErrorIs(t, err, ErrInvalidTaxInformation)
+		})
+	}
+}
@@ -0,0 +1,661 @@
+package tax
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type BatchStatus string
+
+const (
+	BatchStatusPending   BatchStatus = "pending"
+	BatchStatusRunning   BatchStatus = "running"
+	BatchStatusCompleted BatchStatus = "completed"
+	BatchStatusFailed    BatchStatus = "failed"
+	BatchStatusCancelled BatchStatus = "cancelled"
+)
+
+// batchCheckpointRows is how many row results are saved together with the
+// progress of a batch.
+const batchCheckpointRows = 500
+
+// batchResultPageRows is how many row results are read from the store at a
+// time when a result is written.
+const batchResultPageRows = 500
+
+const defaultBatchWorkers = 2
+
+// batchLeaseDuration is how long a claimed batch stays with the instance
+// calculating it without a renewal. A batch whose instance has stopped is
+// claimed again once its lease has expired.
+var batchLeaseDuration = time.Minute
+
+// batchClaimInterval is how often an idle worker looks for a batch to claim,
+// for batches submitted to other instances and leases that have expired.
+var batchClaimInterval = 5 * time.Second
+
+// BatchOptions are the upload options of a batch, the same as for
+// /tax/calculations/upload-csv.
+type BatchOptions struct {
+	AllowUnknownColumns bool     `json:"allowUnknownColumns"`
+	PassThroughColumns  []string `json:"passThroughColumns,omitempty"`
+	Strict              bool     `json:"strict"`
+	Encoding            string   `json:"encoding,omitempty"`
+	Delimiter           string   `json:"delimiter,omitempty"`
+	XLSX                bool     `json:"xlsx"`
+	Sheet               string   `json:"sheet,omitempty"`
+}
+
+// readerOptions are the options of a reader of the file, with xlsx workbooks
+// limited to xlsxMaxBytes once unzipped.
+func (o BatchOptions) readerOptions(xlsxMaxBytes int64) []CSVReaderOption {
+	opts := []CSVReaderOption{WithXLSXUnzipLimit(xlsxMaxBytes)}
+	if o.AllowUnknownColumns {
+		opts = append(opts, WithUnknownColumns())
+	}
+	if len(o.PassThroughColumns) > 0 {
+		opts = append(opts, WithPassThroughColumns(o.PassThroughColumns...))
+	}
+	if o.Encoding != "" {
+		opts = append(opts, WithEncoding(CSVEncoding(o.Encoding)))
+	}
+	if o.Delimiter != "" {
+		delimiter, _ := utf8.DecodeRuneInString(o.Delimiter)
+		opts = append(opts, WithDelimiter(delimiter))
+	}
+	if o.XLSX {
+		opts = append(opts, WithXLSX(o.Sheet))
+	}
+	return opts
+}
+
+// Batch is an uploaded file calculated in the background. Deduction is the
+// snapshot taken when the file was uploaded, so a batch resumed after a
+// restart is calculated with the same values. CheckpointRow is the last row
+// whose result has been saved. Owner is the runner that claimed the batch.
+type Batch struct {
+	ID            string              `json:"id"`
+	Status        BatchStatus         `json:"status"`
+	FileName      string              `json:"fileName"`
+	Options       BatchOptions        `json:"options"`
+	Deduction     deduction.Deduction `json:"-"`
+	TotalRows     int                 `json:"totalRows"`
+	ProcessedRows int                 `json:"processedRows"`
+	SucceededRows int                 `json:"succeededRows"`
+	FailedRows    int                 `json:"failedRows"`
+	Progress      float64             `json:"progress"`
+	CheckpointRow int                 `json:"-"`
+	Owner         string              `json:"-"`
+	Error         string              `json:"error,omitempty"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+	CompletedAt   *time.Time          `json:"completedAt,omitempty"`
+}
+
+func (b Batch) isFinished() bool {
+	return b.Status == BatchStatusCompleted || b.Status == BatchStatusFailed || b.Status == BatchStatusCancelled
+}
+
+// withProgress fills Progress with the percentage of rows processed.
+func (b Batch) withProgress() Batch {
+	switch {
+	case b.Status == BatchStatusCompleted:
+		b.Progress = 100
+	case b.TotalRows > 0:
+		b.Progress = math.Round(float64(b.ProcessedRows)*10_000/float64(b.TotalRows)) / 100
+	}
+	return b
+}
+
+// BatchRowResult is the saved outcome of one row: its tax with the tax of
+// each level, for the summary, or why it was skipped.
+type BatchRowResult struct {
+	Row       int
+	Tax       *CsvTaxRecord
+	TaxLevels []float64
+	Errors    []CsvRowError
+}
+
+// BatchStorer keeps the batches of every instance of the service. A batch is
+// calculated by the instance that claimed it: the updates of any other owner
+// fail with ErrBatchLeaseLost.
+type BatchStorer interface {
+	// CreateBatch stores b with the uploaded file read from content.
+	CreateBatch(b Batch, content io.Reader) error
+	GetBatch(id string) (Batch, error)
+	// GetBatchContent returns a reader of the uploaded file of a batch. The
+	// file is read from the store as it is consumed.
+	GetBatchContent(id string) (io.Reader, error)
+	// ClaimBatch atomically marks the oldest pending batch, or running batch
+	// whose lease has expired, as running on owner until leaseUntil. It
+	// fails with ErrNoBatchToClaim when there is none.
+	ClaimBatch(owner string, leaseUntil time.Time) (Batch, error)
+	RenewBatchLease(id, owner string, leaseUntil time.Time) error
+	// ReleaseBatch puts a running batch back to pending as it was at its
+	// last checkpoint.
+	ReleaseBatch(id, owner string) error
+	// CancelBatch marks a pending or running batch as cancelled, which ends
+	// the lease of its owner. It fails with ErrBatchFinished when the batch
+	// has already finished.
+	CancelBatch(id string) (Batch, error)
+	UpdateBatch(b Batch) error
+	// SaveBatchCheckpoint saves the results and the progress of b together,
+	// so that a resumed batch neither skips nor repeats a row.
+	SaveBatchCheckpoint(b Batch, results []BatchRowResult) error
+	// GetBatchResults returns up to limit results of a batch, in row order,
+	// after the row afterRow.
+	GetBatchResults(id string, afterRow, limit int) ([]BatchRowResult, error)
+}
+
+// BatchRunner calculates batches on a fixed number of background workers.
+// Each worker claims one batch at a time from the store, so batches are
+// shared out between the instances of the service.
+type BatchRunner struct {
+	store              BatchStorer
+	owner              string
+	workers            int
+	calculationWorkers int
+	xlsxMaxBytes       int64
+	wake               chan struct{}
+	watchers           *batchWatchers
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type BatchRunnerOption func(*BatchRunner)
+
+// WithBatchCalculationWorkers sets how many rows of each batch are calculated
+// at the same time. By default it is the number of CPUs.
+func WithBatchCalculationWorkers(n int) BatchRunnerOption {
+	return func(r *BatchRunner) {
+		if n > 0 {
+			r.calculationWorkers = n
+		}
+	}
+}
+
+// WithBatchXLSXMaxUncompressedBytes limits how much an xlsx workbook of a
+// batch may unzip to.
+func WithBatchXLSXMaxUncompressedBytes(n int64) BatchRunnerOption {
+	return func(r *BatchRunner) {
+		if n > 0 {
+			r.xlsxMaxBytes = n
+		}
+	}
+}
+
+func NewBatchRunner(store BatchStorer, workers int, opts ...BatchRunnerOption) *BatchRunner {
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &BatchRunner{
+		store:              store,
+		workers:            workers,
+		calculationWorkers: defaultCalculationWorkers(),
+		wake:               make(chan struct{}, workers),
+		watchers:           newBatchWatchers(),
+		running:            make(map[string]context.CancelFunc),
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func newBatchID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Start starts the workers. They take over the batches left unfinished by
+// an instance that has stopped once their lease has expired.
+func (r *BatchRunner) Start() error {
+	owner, err := newBatchID()
+	if err != nil {
+		return err
+	}
+	r.owner = owner
+
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return nil
+}
+
+func (r *BatchRunner) work() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(batchClaimInterval)
+	defer ticker.Stop()
+	for {
+		if r.claimNext() {
+			continue
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimNext calculates the next batch to claim, if any, and reports whether
+// another one should be claimed at once.
+func (r *BatchRunner) claimNext() bool {
+	if r.ctx.Err() != nil {
+		return false
+	}
+	b, err := r.store.ClaimBatch(r.owner, time.Now().Add(batchLeaseDuration))
+	if err != nil {
+		if !errors.Is(err, ErrNoBatchToClaim) {
+			log.Printf("error claiming batch: %v", err)
+		}
+		return false
+	}
+
+	err = r.process(r.ctx, b)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrBatchLeaseLost):
+		log.Printf("batch %s has been cancelled or claimed by another instance", b.ID)
+		return true
+	}
+	log.Printf("error processing batch %s: %v", b.ID, err)
+	// a batch left running would wait for its lease to expire; when the store
+	// cannot release it either, that is what happens
+	if err := r.store.ReleaseBatch(b.ID, b.Owner); err != nil && !errors.Is(err, ErrBatchLeaseLost) {
+		log.Printf("error releasing batch %s: %v", b.ID, err)
+	}
+	return false
+}
+
+// notify wakes an idle worker to claim a batch submitted to this instance.
+func (r *BatchRunner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown stops the workers. A batch in progress saves a checkpoint and goes
+// back to pending, to be resumed by the next worker to claim it.
+func (r *BatchRunner) Shutdown(ctx context.Context) error {
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit stores an uploaded file as a new pending batch and wakes a worker.
+func (r *BatchRunner) Submit(b Batch, content io.Reader) (Batch, error) {
+	id, err := newBatchID()
+	if err != nil {
+		return Batch{}, err
+	}
+	now := time.Now()
+	b.ID = id
+	b.Status = BatchStatusPending
+	b.CreatedAt = now
+	b.UpdatedAt = now
+
+	if err := r.store.CreateBatch(b, content); err != nil {
+		return Batch{}, err
+	}
+	r.notify()
+	return b, nil
+}
+
+func (r *BatchRunner) Get(id string) (Batch, error) {
+	b, err := r.store.GetBatch(id)
+	if err != nil {
+		return Batch{}, err
+	}
+	return b.withProgress(), nil
+}
+
+// Cancel stops a pending or running batch. A batch running on this instance
+// stops at once; one running on another instance stops when that instance
+// next saves a checkpoint or renews its lease. The rows calculated so far
+// stay in the result.
+func (r *BatchRunner) Cancel(id string) (Batch, error) {
+	b, err := r.store.CancelBatch(id)
+	if err != nil {
+		return Batch{}, err
+	}
+	r.mu.Lock()
+	if cancel, ok := r.running[id]; ok {
+		cancel()
+	}
+	r.mu.Unlock()
+	r.publish(b, nil)
+	return b.withProgress(), nil
+}
+
+// Finished returns a batch that has finished, or ErrBatchNotFinished.
+func (r *BatchRunner) Finished(id string) (Batch, error) {
+	b, err := r.store.GetBatch(id)
+	if err != nil {
+		return Batch{}, err
+	}
+	if !b.isFinished() {
+		return Batch{}, ErrBatchNotFinished
+	}
+	return b, nil
+}
+
+// eachResult calls fn with every saved result of a batch in row order,
+// reading them from the store a page at a time.
+func (r *BatchRunner) eachResult(id string, fn func(BatchRowResult) error) error {
+	afterRow := 0
+	for {
+		results, err := r.store.GetBatchResults(id, afterRow, batchResultPageRows)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if err := fn(result); err != nil {
+				return err
+			}
+		}
+		if len(results) < batchResultPageRows {
+			return nil
+		}
+		afterRow = results[len(results)-1].Row
+	}
+}
+
+// WriteResult writes the outcome of a finished batch to w as the json of an
+// upload response, with its summary. The results are streamed from the store
+// rather than held in memory, so the errors, when there are any, are read in
+// a second pass.
+func (r *BatchRunner) WriteResult(w io.Writer, b Batch, lang i18n.Language) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	summary := newSummaryBuilder()
+
+	_, _ = bw.WriteString(`{"taxes":[`)
+	first, failed := true, false
+	err := r.eachResult(b.ID, func(result BatchRowResult) error {
+		failed = failed || len(result.Errors) > 0
+		if result.Tax == nil {
+			summary.addFailed()
+			return nil
+		}
+		summary.add(*result.Tax, result.TaxLevels)
+		if !first {
+			_ = bw.WriteByte(',')
+		}
+		first = false
+		return enc.Encode(result.Tax)
+	})
+	if err != nil {
+		return err
+	}
+	_ = bw.WriteByte(']')
+
+	if failed {
+		_, _ = bw.WriteString(`,"errors":[`)
+		first = true
+		err := r.eachResult(b.ID, func(result BatchRowResult) error {
+			for _, rowErr := range localiseCsvRowErrors(result.Errors, lang) {
+				if !first {
+					_ = bw.WriteByte(',')
+				}
+				first = false
+				if err := enc.Encode(rowErr); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		_ = bw.WriteByte(']')
+	}
+
+	_, _ = bw.WriteString(`,"summary":`)
+	if err := enc.Encode(localiseCsvTaxSummary(summary.build(), lang)); err != nil {
+		return err
+	}
+	_ = bw.WriteByte('}')
+	return bw.Flush()
+}
+
+func countRows(cr *CSVReader) (int, error) {
+	count := 0
+	for {
+		_, _, err := cr.next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// batchFailureReason picks the message shown for a failed batch.
+func batchFailureReason(err error) error {
+	for _, reason := range []error{ErrXLSXSheetNotFound, ErrReadingXLSX, ErrInvalidCSVHeader, ErrInvalidCSVRow} {
+		if errors.Is(err, reason) {
+			return reason
+		}
+	}
+	return ErrReadingCSV
+}
+
+func (r *BatchRunner) fail(b Batch, err error) error {
+	now := time.Now()
+	b.Status = BatchStatusFailed
+	b.Error = batchFailureReason(err).Error()
+	b.UpdatedAt = now
+	b.CompletedAt = &now
+	err = errors.Join(err, r.store.UpdateBatch(b))
+	r.publish(b, nil)
+	return err
+}
+
+func (r *BatchRunner) openBatch(b Batch, content io.Reader) (*CSVReader, error) {
+	cr := NewCSVReader(content, b.Options.readerOptions(r.xlsxMaxBytes)...)
+	if err := cr.readHeader(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func batchRowResult(outcome rowOutcome) BatchRowResult {
+	if errs := outcome.rowErrors(); errs != nil {
+		return BatchRowResult{Row: outcome.row.number, Errors: csvRowErrors(errs)}
+	}
+	record := csvTaxRecord(outcome.row, outcome.result)
+	return BatchRowResult{Row: outcome.row.number, Tax: &record, TaxLevels: levelTaxes(outcome.result.TaxLevels)}
+}
+
+// keepLease renews the lease on a claimed batch until the returned func is
+// called. The returned context is cancelled when the batch has been claimed
+// by another instance or cancelled.
+func (r *BatchRunner) keepLease(ctx context.Context, b Batch) (context.Context, func()) {
+	leaseCtx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.running[b.ID] = cancel
+	r.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(batchLeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+				err := r.store.RenewBatchLease(b.ID, b.Owner, time.Now().Add(batchLeaseDuration))
+				if errors.Is(err, ErrBatchLeaseLost) {
+					cancel()
+					return
+				}
+				if err != nil {
+					log.Printf("error renewing lease of batch %s: %v", b.ID, err)
+				}
+			}
+		}
+	}()
+	return leaseCtx, func() {
+		r.mu.Lock()
+		delete(r.running, b.ID)
+		r.mu.Unlock()
+		close(done)
+		cancel()
+	}
+}
+
+// process calculates a claimed batch from its last checkpoint. When ctx is
+// cancelled it saves what has been calculated and leaves the batch pending.
+// A store error leaves the batch running, for the caller to release.
+func (r *BatchRunner) process(ctx context.Context, b Batch) error {
+	if b.isFinished() {
+		return nil
+	}
+	ctx, stopLease := r.keepLease(ctx, b)
+	defer stopLease()
+
+	// the file is read twice, to count its rows and to calculate them, each
+	// time streamed from the store
+	if b.TotalRows == 0 {
+		content, err := r.store.GetBatchContent(b.ID)
+		if err != nil {
+			return err
+		}
+		counter, err := r.openBatch(b, content)
+		if err != nil {
+			return r.fail(b, err)
+		}
+		b.TotalRows, err = countRows(counter)
+		counter.close()
+		if err != nil {
+			return r.fail(b, err)
+		}
+	}
+
+	content, err := r.store.GetBatchContent(b.ID)
+	if err != nil {
+		return err
+	}
+	cr, err := r.openBatch(b, content)
+	if err != nil {
+		return r.fail(b, err)
+	}
+	defer cr.close()
+
+	run := newBatchRun(b)
+	r.publish(b, run)
+
+	results := make([]BatchRowResult, 0, batchCheckpointRows)
+	checkpoint := func() error {
+		if len(results) == 0 {
+			return nil
+		}
+		b.CheckpointRow = results[len(results)-1].Row
+		b.UpdatedAt = time.Now()
+		if err := r.store.SaveBatchCheckpoint(b, results); err != nil {
+			return err
+		}
+		results = results[:0]
+		return nil
+	}
+
+	p := calculateRows(ctx, cr, b.Deduction, r.calculationWorkers, b.CheckpointRow)
+	defer p.stop()
+
+	for outcome := range p.outcomes {
+		if outcome.err != nil {
+			return errors.Join(checkpoint(), r.fail(b, outcome.err))
+		}
+
+		result := batchRowResult(outcome)
+		results = append(results, result)
+		b.ProcessedRows++
+		if result.Tax != nil {
+			b.SucceededRows++
+		} else {
+			b.FailedRows++
+		}
+
+		if b.Options.Strict && result.Tax == nil {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+			return r.fail(b, ErrInvalidCSVRow)
+		}
+		if len(results) >= batchCheckpointRows {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		}
+		if b.ProcessedRows%batchProgressRows == 0 {
+			r.publish(b, run)
+		}
+	}
+	if p.err() != nil {
+		if err := checkpoint(); err != nil {
+			return err
+		}
+		b.Status = BatchStatusPending
+		if err := r.store.UpdateBatch(b); err != nil {
+			return err
+		}
+		r.publish(b, nil)
+		return nil
+	}
+
+	if err := checkpoint(); err != nil {
+		return err
+	}
+	now := time.Now()
+	b.Status = BatchStatusCompleted
+	b.UpdatedAt = now
+	b.CompletedAt = &now
+	if err := r.store.UpdateBatch(b); err != nil {
+		return err
+	}
+	r.publish(b, nil)
+	return nil
+}
@@ -0,0 +1,266 @@
+package tax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const MIMETextEventStream = "text/event-stream"
+
+const (
+	batchEventProgress = "progress"
+	batchEventComplete = "complete"
+)
+
+// batchProgressRows is how many rows a running batch calculates between two
+// progress events.
+const batchProgressRows = 100
+
+// batchEventsPollInterval is how often a progress stream reads the batch from
+// the store, for batches calculated by another instance of the service, and
+// keeps an idle connection open.
+var batchEventsPollInterval = 5 * time.Second
+
+// BatchProgress is an event of a batch progress stream. ProcessedRows counts
+// the rows done so far, CalculatedRows the ones with a tax and FailedRows the
+// ones skipped. ETASeconds is estimated from the speed of the current run,
+// and ResultURL is set on the final event.
+type BatchProgress struct {
+	ID             string      `json:"id"`
+	Status         BatchStatus `json:"status"`
+	TotalRows      int         `json:"totalRows"`
+	ProcessedRows  int         `json:"processedRows"`
+	CalculatedRows int         `json:"calculatedRows"`
+	FailedRows     int         `json:"failedRows"`
+	Progress       float64     `json:"progress"`
+	ETASeconds     *float64    `json:"etaSeconds,omitempty"`
+	ResultURL      string      `json:"resultUrl,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+func batchProgress(b Batch) BatchProgress {
+	b = b.withProgress()
+	return BatchProgress{
+		ID:             b.ID,
+		Status:         b.Status,
+		TotalRows:      b.TotalRows,
+		ProcessedRows:  b.ProcessedRows,
+		CalculatedRows: b.SucceededRows,
+		FailedRows:     b.FailedRows,
+		Progress:       b.Progress,
+		Error:          b.Error,
+	}
+}
+
+// batchRun is the start of a batch run, to estimate when it ends.
+type batchRun struct {
+	started   time.Time
+	startRows int
+}
+
+func newBatchRun(b Batch) *batchRun {
+	return &batchRun{started: time.Now(), startRows: b.ProcessedRows}
+}
+
+// eta extrapolates the time taken by the rows calculated in this run to the
+// rows left. It is unknown until a row has been calculated.
+func (run *batchRun) eta(b Batch) *float64 {
+	done := b.ProcessedRows - run.startRows
+	if done <= 0 || b.TotalRows < b.ProcessedRows {
+		return nil
+	}
+	perRow := time.Since(run.started).Seconds() / float64(done)
+	eta := math.Round(perRow * float64(b.TotalRows-b.ProcessedRows))
+	return &eta
+}
+
+// batchWatchers hands the progress of the batches calculated by this instance
+// to the streams watching them. A slow stream only misses intermediate
+// events: each watcher holds the latest one.
+type batchWatchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan BatchProgress]struct{}
+}
+
+func newBatchWatchers() *batchWatchers {
+	return &batchWatchers{watchers: make(map[string]map[chan BatchProgress]struct{})}
+}
+
+// watch returns the progress events of a batch and the func to stop watching.
+func (w *batchWatchers) watch(id string) (<-chan BatchProgress, func()) {
+	ch := make(chan BatchProgress, 1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watchers[id] == nil {
+		w.watchers[id] = make(map[chan BatchProgress]struct{})
+	}
+	w.watchers[id][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.watchers[id], ch)
+		if len(w.watchers[id]) == 0 {
+			delete(w.watchers, id)
+		}
+	}
+}
+
+// watched reports whether any stream is still watching a batch.
+func (w *batchWatchers) watched(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watchers[id]) > 0
+}
+
+func (w *batchWatchers) publish(p BatchProgress) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.watchers[p.ID] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- p
+	}
+}
+
+// publish sends the progress of b to its watchers, with an estimate of the
+// time left when run is given.
+func (r *BatchRunner) publish(b Batch, run *batchRun) {
+	p := batchProgress(b)
+	if run != nil && !b.isFinished() {
+		p.ETASeconds = run.eta(b)
+	}
+	r.watchers.publish(p)
+}
+
+// Watch returns the progress events of a batch calculated by this instance
+// and the func to stop watching.
+func (r *BatchRunner) Watch(id string) (<-chan BatchProgress, func()) {
+	return r.watchers.watch(id)
+}
+
+// cancelUnwatched cancels a batch once its last stream on this instance has
+// stopped watching.
+func (r *BatchRunner) cancelUnwatched(id string) error {
+	if r.watchers.watched(id) {
+		return nil
+	}
+	_, err := r.Cancel(id)
+	if errors.Is(err, ErrBatchFinished) {
+		return nil
+	}
+	return err
+}
+
+func writeBatchEvent(c echo.Context, event string, p BatchProgress, lang i18n.Language) error {
+	p.Error = messages.Translate(lang, p.Error)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	res := c.Response()
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// GetBatchEventsHandler
+//
+//	@Summary		Stream the progress of a batch
+//	@Description	Stream server-sent events while a batch is calculated. A progress event carries the rows processed, calculated and failed so far, the progress in percent and, once the speed is known, the estimated seconds left. The stream ends with a complete event, also sent at once for a finished or cancelled batch, holding the final counts and the link to the result.
+//	@Description	Closing the connection stops the stream only, and the batch carries on in the background, unless cancelOnDisconnect is set: the batch is then cancelled once no other stream is watching it.
+//	@Tags			tax
+//	@Param			id					path	string	true	"batch id"
+//	@Param			cancelOnDisconnect	query	bool	false	"cancel the batch when the client disconnects"
+//	@Param			Accept-Language		header	string	false	"Language of messages (en, th)"
+//	@Produce		text/event-stream
+//	@Success		200	{object}	BatchProgress
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/batches/{id}/events [get]
+func (h *Handler) GetBatchEventsHandler(c echo.Context) error {
+	id := c.Param("id")
+	events, stop := h.batches.Watch(id)
+	defer stop()
+
+	b, err := h.batches.Get(id)
+	if err != nil {
+		return h.handleBatchError(c, err)
+	}
+	cancelOnDisconnect, _ := strconv.ParseBool(c.QueryParam("cancelOnDisconnect"))
+
+	lang := i18n.FromRequest(c.Request())
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMETextEventStream)
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	// send writes p and reports whether the stream is over.
+	last := batchProgress(b)
+	send := func(p BatchProgress) bool {
+		last = p
+		event := batchEventProgress
+		if p.Status == BatchStatusCompleted || p.Status == BatchStatusFailed || p.Status == BatchStatusCancelled {
+			event = batchEventComplete
+			p.ETASeconds = nil
+			p.ResultURL = "/tax/batches/" + p.ID + "/result"
+		}
+		if err := writeBatchEvent(c, event, p, lang); err != nil {
+			c.Logger().Printf("error streaming batch events: %v", err)
+			return true
+		}
+		return event == batchEventComplete
+	}
+	if send(last) {
+		return nil
+	}
+
+	ticker := time.NewTicker(batchEventsPollInterval)
+	defer ticker.Stop()
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			if cancelOnDisconnect {
+				stop()
+				if err := h.batches.cancelUnwatched(id); err != nil {
+					c.Logger().Printf("error cancelling batch %s: %v", id, err)
+				}
+			}
+			return nil
+		case p := <-events:
+			if send(p) {
+				return nil
+			}
+		case <-ticker.C:
+			b, err := h.batches.Get(id)
+			if err != nil {
+				c.Logger().Printf("error getting batch: %v", err)
+				continue
+			}
+			if p := batchProgress(b); p.Status != last.Status || p.ProcessedRows != last.ProcessedRows {
+				if send(p) {
+					return nil
+				}
+				continue
+			}
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
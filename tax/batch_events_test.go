@@ -0,0 +1,274 @@
+//go:build unit
+
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type batchEvent struct {
+	name     string
+	progress BatchProgress
+}
+
+func parseBatchEvents(t *testing.T, body string) []batchEvent {
+	events := make([]batchEvent, 0)
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event batchEvent
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.progress))
+			}
+		}
+		if event.name != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestBatchRun_ETA(t *testing.T) {
+	// Arrange
+	run := &batchRun{started: time.Now().Add(-10 * time.Second), startRows: 100}
+
+	// Act
+	before := run.eta(Batch{TotalRows: 1_000, ProcessedRows: 100})
+	after := run.eta(Batch{TotalRows: 1_000, ProcessedRows: 200})
+
+	// Assert
+	assert.Nil(t, before)
+	if assert.NotNil(t, after) {
+		assert.Equal(t, 80.0, *after)
+	}
+}
+
+func TestBatchWatchers(t *testing.T) {
+	t.Run("watcher holds the latest event", func(t *testing.T) {
+		// Arrange
+		w := newBatchWatchers()
+		events, stop := w.watch("b1")
+		defer stop()
+
+		// Act
+		w.publish(BatchProgress{ID: "b1", ProcessedRows: 100})
+		w.publish(BatchProgress{ID: "b1", ProcessedRows: 200})
+		w.publish(BatchProgress{ID: "b2", ProcessedRows: 300})
+
+		// Assert
+		assert.Equal(t, 200, (<-events).ProcessedRows)
+		assert.Len(t, events, 0)
+	})
+
+	t.Run("stopped watcher is removed", func(t *testing.T) {
+		// Arrange
+		w := newBatchWatchers()
+		_, stop := w.watch("b1")
+
+		// Act
+		stop()
+
+		// Assert
+		assert.Empty(t, w.watchers)
+	})
+}
+
+func TestBatchRunner_ProcessPublishes(t *testing.T) {
+	// Arrange
+	store := newMemoryBatchStore()
+	store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader(pipelineCSV(250)))
+	r := NewBatchRunner(store, 1)
+	events, stop := r.Watch("b1")
+	defer stop()
+
+	// Act
+	err := r.process(context.Background(), claim(t, store))
+
+	// Assert
+	assert.NoError(t, err)
+	got := <-events
+	assert.Equal(t, BatchStatusCompleted, got.Status)
+	assert.Equal(t, 250, got.ProcessedRows)
+	assert.Equal(t, 250, got.CalculatedRows+got.FailedRows)
+	assert.Equal(t, 100.0, got.Progress)
+}
+
+func TestGetBatchEventsHandler(t *testing.T) {
+	newContext := func(ctx context.Context, id string) (*httptest.ResponseRecorder, echo.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/tax/batches/"+id+"/events", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return rec, c
+	}
+
+	t.Run("finished batch; expect a single complete event", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusCompleted, TotalRows: 3, ProcessedRows: 3, SucceededRows: 2, FailedRows: 1}, nil)
+		rec, c := newContext(context.Background(), "b1")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).GetBatchEventsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, MIMETextEventStream, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, []batchEvent{{name: batchEventComplete, progress: BatchProgress{
+			ID:             "b1",
+			Status:         BatchStatusCompleted,
+			TotalRows:      3,
+			ProcessedRows:  3,
+			CalculatedRows: 2,
+			FailedRows:     1,
+			Progress:       100,
+			ResultURL:      "/tax/batches/b1/result",
+		}}}, parseBatchEvents(t, rec.Body.String()))
+	})
+
+	t.Run("running batch; expect progress until complete", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		running := Batch{ID: "b1", Status: BatchStatusRunning, TotalRows: 200, ProcessedRows: 100, SucceededRows: 100}
+		store.CreateBatch(running, nil)
+		r := NewBatchRunner(store, 1)
+		rec, c := newContext(context.Background(), "b1")
+		done := make(chan error)
+
+		// Act
+		go func() {
+			done <- New(NewMockTaxStorer(), WithBatchRunner(r)).GetBatchEventsHandler(c)
+		}()
+		completed := running
+		completed.Status = BatchStatusCompleted
+		completed.ProcessedRows, completed.SucceededRows = 200, 200
+		var err error
+	publish:
+		for {
+			r.publish(completed, nil)
+			select {
+			case err = <-done:
+				break publish
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		// Assert
+		assert.NoError(t, err)
+		got := parseBatchEvents(t, rec.Body.String())
+		assert.Equal(t, batchEventProgress, got[0].name)
+		assert.Equal(t, 50.0, got[0].progress.Progress)
+		last := got[len(got)-1]
+		assert.Equal(t, batchEventComplete, last.name)
+		assert.Equal(t, 200, last.progress.CalculatedRows)
+		assert.Equal(t, "/tax/batches/b1/result", last.progress.ResultURL)
+	})
+
+	t.Run("client disconnects; expect stream to end", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning, TotalRows: 200}, nil)
+		r := NewBatchRunner(store, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		_, c := newContext(ctx, "b1")
+		done := make(chan error)
+
+		// Act
+		go func() {
+			done <- New(NewMockTaxStorer(), WithBatchRunner(r)).GetBatchEventsHandler(c)
+		}()
+		cancel()
+
+		// Assert
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected the stream to end after the client disconnected")
+		}
+		r.watchers.mu.Lock()
+		defer r.watchers.mu.Unlock()
+		assert.Empty(t, r.watchers.watchers)
+	})
+
+	t.Run("client disconnects with cancelOnDisconnect; expect batch cancelled", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning, TotalRows: 200}, nil)
+		r := NewBatchRunner(store, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		_, c := newContext(ctx, "b1")
+		c.QueryParams().Set("cancelOnDisconnect", "true")
+		done := make(chan error)
+
+		// Act
+		go func() {
+			done <- New(NewMockTaxStorer(), WithBatchRunner(r)).GetBatchEventsHandler(c)
+		}()
+		cancel()
+
+		// Assert
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected the stream to end after the client disconnected")
+		}
+		b, _ := store.GetBatch("b1")
+		assert.Equal(t, BatchStatusCancelled, b.Status)
+	})
+
+	t.Run("another stream still watching; expect batch kept running", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning, TotalRows: 200}, nil)
+		r := NewBatchRunner(store, 1)
+		_, stopOther := r.Watch("b1")
+		defer stopOther()
+		ctx, cancel := context.WithCancel(context.Background())
+		_, c := newContext(ctx, "b1")
+		c.QueryParams().Set("cancelOnDisconnect", "true")
+		done := make(chan error)
+
+		// Act
+		go func() {
+			done <- New(NewMockTaxStorer(), WithBatchRunner(r)).GetBatchEventsHandler(c)
+		}()
+		cancel()
+
+		// Assert
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected the stream to end after the client disconnected")
+		}
+		b, _ := store.GetBatch("b1")
+		assert.Equal(t, BatchStatusRunning, b.Status)
+	})
+
+	t.Run("unknown batch; expect 404", func(t *testing.T) {
+		// Arrange
+		rec, c := newContext(context.Background(), "missing")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(newMemoryBatchStore(), 1))).GetBatchEventsHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf("%q", ErrBatchNotFound.Error()))
+	})
+}
@@ -0,0 +1,153 @@
+package tax
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+// CreateBatchHandler
+//
+//	@Summary		Upload csv or xlsx file to calculate tax in the background
+//	@Description	Store the upload as a batch and return it at once. The batch is calculated by a background worker; poll /tax/batches/{id} for its progress, or follow /tax/batches/{id}/events.
+//	@Tags			tax
+//	@Accept			multipart/form-data
+//	@Param			taxFile				formData	file	true	"csv or xlsx file"
+//	@Param			allowUnknownColumns	formData	bool	false	"pass unknown columns through as metadata instead of rejecting the file"
+//	@Param			passThroughColumns	formData	string	false	"comma-separated columns to echo as metadata on every result; they must be in the header"
+//	@Param			strict				formData	bool	false	"fail the batch on the first invalid row"
+//	@Param			sheet				formData	string	false	"xlsx sheet to read, the first one by default"
+//	@Param			encoding			formData	string	false	"csv encoding: utf-8 or tis-620 (windows-874), detected by default"
+//	@Param			delimiter			formData	string	false	"csv delimiter: comma (default), semicolon or tab"
+//	@Param			Accept-Language		header		string	false	"Language of messages (en, th)"
+//	@Produce		json
+//	@Success		202	{object}	Batch
+//	@Failure		400	{object}	Err
+//	@Failure		413	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/batches [post]
+func (h *Handler) CreateBatchHandler(c echo.Context) error {
+	file, err := c.FormFile("taxFile")
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "uploading file", ErrUploadingFile.Error())
+	}
+	if h.batchMaxUploadBytes > 0 && file.Size > h.batchMaxUploadBytes {
+		return h.handleError(c, http.StatusRequestEntityTooLarge, ErrBatchTooLarge, "uploading file", ErrBatchTooLarge.Error())
+	}
+
+	opts, err := uploadOptions(c, file)
+	if err != nil {
+		return h.handleUploadOptionsError(c, err)
+	}
+
+	deductionData, err := h.store.GetDeduction(time.Now())
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+	if err := deductionData.Validate(); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, errors.Join(err, ErrInvalidDeduction), "creating batch", ErrCalculatingTax.Error())
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "opening file", ErrUploadingFile.Error())
+	}
+	defer src.Close()
+
+	b, err := h.batches.Submit(Batch{
+		FileName:  file.Filename,
+		Options:   opts,
+		Deduction: deductionData,
+	}, src)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "creating batch", ErrCreatingBatch.Error())
+	}
+
+	return c.JSON(http.StatusAccepted, b)
+}
+
+func (h *Handler) handleBatchError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrBatchNotFound):
+		return h.handleError(c, http.StatusNotFound, err, "getting batch", ErrBatchNotFound.Error())
+	case errors.Is(err, ErrBatchNotFinished):
+		return h.handleError(c, http.StatusConflict, err, "getting batch result", ErrBatchNotFinished.Error())
+	case errors.Is(err, ErrBatchFinished):
+		return h.handleError(c, http.StatusConflict, err, "cancelling batch", ErrBatchFinished.Error())
+	default:
+		return h.handleError(c, http.StatusInternalServerError, err, "getting batch", ErrGettingBatch.Error())
+	}
+}
+
+// GetBatchHandler
+//
+//	@Summary		Get the progress of a batch
+//	@Description	Get the status, row counts and progress in percent of a batch
+//	@Tags			tax
+//	@Param			id				path	string	true	"batch id"
+//	@Param			Accept-Language	header	string	false	"Language of messages (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	Batch
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/batches/{id} [get]
+func (h *Handler) GetBatchHandler(c echo.Context) error {
+	b, err := h.batches.Get(c.Param("id"))
+	if err != nil {
+		return h.handleBatchError(c, err)
+	}
+
+	b.Error = messages.Translate(i18n.FromRequest(c.Request()), b.Error)
+	return c.JSON(http.StatusOK, b)
+}
+
+// GetBatchResultHandler
+//
+//	@Summary		Get the result of a batch
+//	@Description	Get the taxes and row errors of a finished batch
+//	@Tags			tax
+//	@Param			id				path	string	true	"batch id"
+//	@Param			Accept-Language	header	string	false	"Language of messages (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	CsvTaxResponse
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/batches/{id}/result [get]
+func (h *Handler) GetBatchResultHandler(c echo.Context) error {
+	b, err := h.batches.Finished(c.Param("id"))
+	if err != nil {
+		return h.handleBatchError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c.Response().WriteHeader(http.StatusOK)
+	// the status has been sent: an error from here on only cuts the body short
+	return h.batches.WriteResult(c.Response(), b, i18n.FromRequest(c.Request()))
+}
+
+// CancelBatchHandler
+//
+//	@Summary		Cancel a batch
+//	@Description	Stop a pending or running batch. The rows calculated so far are kept and can still be read from /tax/batches/{id}/result.
+//	@Tags			tax
+//	@Param			id				path	string	true	"batch id"
+//	@Param			Accept-Language	header	string	false	"Language of messages (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	Batch
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/batches/{id}/cancel [post]
+func (h *Handler) CancelBatchHandler(c echo.Context) error {
+	b, err := h.batches.Cancel(c.Param("id"))
+	if errors.Is(err, ErrBatchNotFound) || errors.Is(err, ErrBatchFinished) {
+		return h.handleBatchError(c, err)
+	}
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "cancelling batch", ErrCancellingBatch.Error())
+	}
+	return c.JSON(http.StatusOK, b)
+}
@@ -0,0 +1,270 @@
+//go:build unit
+
+package tax
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateBatchHandler(t *testing.T) {
+	newRequest := func(encoding string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/batches", "taxes.xlsx", []byte("workbook"), map[string]string{
+			"strict":             "true",
+			"sheet":              "payroll",
+			"encoding":           encoding,
+			"delimiter":          "semicolon",
+			"passThroughColumns": "name, department",
+		}, nil)
+	}
+
+	t.Run("stored as a pending batch", func(t *testing.T) {
+		// Arrange
+		rec, c := newRequest("windows-874")
+		mock := NewMockTaxStorer()
+		mock.deduction = batchDeduction
+		store := newMemoryBatchStore()
+
+		// Act
+		err := New(mock, WithBatchRunner(NewBatchRunner(store, 1))).CreateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var got Batch
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, BatchStatusPending, got.Status)
+		assert.Equal(t, "taxes.xlsx", got.FileName)
+		assert.Equal(t, BatchOptions{PassThroughColumns: []string{"name", "department"}, Strict: true, Encoding: "tis-620", Delimiter: ";", XLSX: true, Sheet: "payroll"}, got.Options)
+		stored := store.batches[got.ID]
+		assert.Equal(t, batchDeduction, stored.Deduction)
+		assert.Equal(t, []byte("workbook"), store.contents[got.ID])
+	})
+
+	t.Run("store error; expect 500 with ErrCreatingBatch", func(t *testing.T) {
+		// Arrange
+		rec, c := newRequest("")
+		mock := NewMockTaxStorer()
+		mock.deduction = batchDeduction
+		store := newMemoryBatchStore()
+		store.err = errors.New("unexpected error")
+
+		// Act
+		err := New(mock, WithBatchRunner(NewBatchRunner(store, 1))).CreateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrCreatingBatch.Error(), got.Message)
+	})
+
+	t.Run("unsupported encoding; expect 400", func(t *testing.T) {
+		// Arrange
+		rec, c := newRequest("ebcdic")
+		store := newMemoryBatchStore()
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).CreateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrUnsupportedCSVEncoding.Error(), got.Message)
+		assert.Empty(t, store.batches)
+	})
+
+	t.Run("file over the upload limit; expect 413", func(t *testing.T) {
+		// Arrange
+		rec, c := newRequest("")
+		mock := NewMockTaxStorer()
+		mock.deduction = batchDeduction
+		store := newMemoryBatchStore()
+
+		// Act
+		err := New(mock, WithBatchRunner(NewBatchRunner(store, 1)), WithBatchMaxUploadBytes(4)).CreateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrBatchTooLarge.Error(), got.Message)
+		assert.Empty(t, store.batches)
+	})
+}
+
+func TestGetBatchHandler(t *testing.T) {
+	newContext := func(id, lang string) (*httptest.ResponseRecorder, echo.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/tax/batches/"+id, nil)
+		if lang != "" {
+			req.Header.Set(i18n.HeaderAcceptLanguage, lang)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return rec, c
+	}
+
+	t.Run("progress of a running batch", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning, TotalRows: 3, ProcessedRows: 1}, nil)
+		rec, c := newContext("b1", "")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).GetBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got Batch
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, 33.33, got.Progress)
+	})
+
+	t.Run("failure reason is translated", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusFailed, Error: ErrInvalidCSVHeader.Error()}, nil)
+		rec, c := newContext("b1", "th")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).GetBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		var got Batch
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, ErrInvalidCSVHeader.Error()), got.Error)
+	})
+
+	t.Run("unknown batch; expect 404", func(t *testing.T) {
+		// Arrange
+		rec, c := newContext("missing", "")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(newMemoryBatchStore(), 1))).GetBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestGetBatchResultHandler(t *testing.T) {
+	newContext := func(id string) (*httptest.ResponseRecorder, echo.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/tax/batches/"+id+"/result", nil)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return rec, c
+	}
+
+	t.Run("completed batch", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusCompleted}, nil)
+		store.SaveBatchCheckpoint(store.batches["b1"], []BatchRowResult{
+			{Row: 2, Tax: &CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}, TaxLevels: []float64{0, 29_000.0, 0, 0, 0}},
+			{Row: 3, Errors: []CsvRowError{{Row: 3, Reason: ErrCSVColumnCount.Error()}}},
+		})
+		rec, c := newContext("b1")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).GetBatchResultHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, []CsvTaxRecord{{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}}, got.Taxes)
+		assert.Equal(t, []CsvRowError{{Row: 3, Reason: ErrCSVColumnCount.Error()}}, got.Errors)
+		if assert.NotNil(t, got.Summary) {
+			assert.Equal(t, 1, got.Summary.Rows)
+			assert.Equal(t, 1, got.Summary.FailedRows)
+			assert.Equal(t, CsvBracketSummary{Level: "150,001-500,000", Count: 1, Tax: 29_000.0}, got.Summary.Brackets[1])
+		}
+	})
+
+	t.Run("running batch; expect 409", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning}, nil)
+		rec, c := newContext("b1")
+
+		// Act
+		err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).GetBatchResultHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestCancelBatchHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     BatchStatus
+		id         string
+		wantStatus int
+		wantMsg    string
+	}{
+		{name: "running batch; expect cancelled", status: BatchStatusRunning, id: "b1", wantStatus: http.StatusOK},
+		{name: "completed batch; expect 409", status: BatchStatusCompleted, id: "b1", wantStatus: http.StatusConflict, wantMsg: ErrBatchFinished.Error()},
+		{name: "unknown batch; expect 404", status: BatchStatusRunning, id: "missing", wantStatus: http.StatusNotFound, wantMsg: ErrBatchNotFound.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			store := newMemoryBatchStore()
+			store.CreateBatch(Batch{ID: "b1", Status: tc.status}, nil)
+			req := httptest.NewRequest(http.MethodPost, "/tax/batches/"+tc.id+"/cancel", nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tc.id)
+
+			// Act
+			err := New(NewMockTaxStorer(), WithBatchRunner(NewBatchRunner(store, 1))).CancelBatchHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if tc.wantMsg != "" {
+				assert.JSONEq(t, `{"message":"`+tc.wantMsg+`"}`, rec.Body.String())
+				return
+			}
+			var got Batch
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, BatchStatusCancelled, got.Status)
+		})
+	}
+}
@@ -0,0 +1,510 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryBatchStore struct {
+	mu          sync.Mutex
+	batches     map[string]Batch
+	contents    map[string][]byte
+	results     map[string][]BatchRowResult
+	leases      map[string]time.Time
+	checkpoints int
+	err         error
+}
+
+func newMemoryBatchStore() *memoryBatchStore {
+	return &memoryBatchStore{
+		batches:  make(map[string]Batch),
+		contents: make(map[string][]byte),
+		results:  make(map[string][]BatchRowResult),
+		leases:   make(map[string]time.Time),
+	}
+}
+
+func (m *memoryBatchStore) CreateBatch(b Batch, content io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.batches[b.ID] = b
+	if content != nil {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		m.contents[b.ID] = data
+	}
+	return nil
+}
+
+func (m *memoryBatchStore) GetBatch(id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrBatchNotFound
+	}
+	return b, nil
+}
+
+func (m *memoryBatchStore) GetBatchContent(id string) (io.Reader, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return bytes.NewReader(m.contents[id]), nil
+}
+
+func (m *memoryBatchStore) ClaimBatch(owner string, leaseUntil time.Time) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.batches))
+	for id := range m.batches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		b := m.batches[id]
+		expired := b.Status == BatchStatusRunning && m.leases[id].Before(time.Now())
+		if b.Status == BatchStatusPending || expired {
+			b.Status = BatchStatusRunning
+			b.Owner = owner
+			m.batches[id] = b
+			m.leases[id] = leaseUntil
+			return b, nil
+		}
+	}
+	return Batch{}, ErrNoBatchToClaim
+}
+
+// owns must be called with mu held. A cancelled batch is owned by no one.
+func (m *memoryBatchStore) owns(id, owner string) bool {
+	b, ok := m.batches[id]
+	return ok && b.Owner == owner && b.Status != BatchStatusCancelled
+}
+
+func (m *memoryBatchStore) CancelBatch(id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return Batch{}, ErrBatchNotFound
+	}
+	if b.isFinished() {
+		return Batch{}, ErrBatchFinished
+	}
+	now := time.Now()
+	b.Status = BatchStatusCancelled
+	b.CompletedAt = &now
+	m.batches[id] = b
+	return b, nil
+}
+
+func (m *memoryBatchStore) RenewBatchLease(id, owner string, leaseUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owns(id, owner) || m.batches[id].Status != BatchStatusRunning {
+		return ErrBatchLeaseLost
+	}
+	m.leases[id] = leaseUntil
+	return nil
+}
+
+func (m *memoryBatchStore) ReleaseBatch(id, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owns(id, owner) || m.batches[id].Status != BatchStatusRunning {
+		return ErrBatchLeaseLost
+	}
+	b := m.batches[id]
+	b.Status = BatchStatusPending
+	m.batches[id] = b
+	return nil
+}
+
+func (m *memoryBatchStore) UpdateBatch(b Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owns(b.ID, b.Owner) {
+		return ErrBatchLeaseLost
+	}
+	m.batches[b.ID] = b
+	return nil
+}
+
+func (m *memoryBatchStore) SaveBatchCheckpoint(b Batch, results []BatchRowResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if !m.owns(b.ID, b.Owner) {
+		return ErrBatchLeaseLost
+	}
+	m.checkpoints++
+	m.batches[b.ID] = b
+	m.results[b.ID] = append(m.results[b.ID], results...)
+	return nil
+}
+
+func (m *memoryBatchStore) GetBatchResults(id string, afterRow, limit int) ([]BatchRowResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	page := make([]BatchRowResult, 0, limit)
+	for _, result := range m.results[id] {
+		if result.Row > afterRow && len(page) < limit {
+			page = append(page, result)
+		}
+	}
+	return page, nil
+}
+
+// result reads the result of a finished batch as a client of
+// /tax/batches/{id}/result would.
+func result(t *testing.T, r *BatchRunner, id string) CsvTaxResponse {
+	t.Helper()
+	b, err := r.Finished(id)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting a finished batch", err)
+	}
+	var buf bytes.Buffer
+	if err := r.WriteResult(&buf, b, i18n.LanguageEnglish); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing a result", err)
+	}
+	var got CsvTaxResponse
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected result to be valid json, got %s", buf.String())
+	}
+	return got
+}
+
+// claim claims the next batch of store, as a worker would, for the tests
+// that call process directly.
+func claim(t *testing.T, store *memoryBatchStore) Batch {
+	t.Helper()
+	b, err := store.ClaimBatch("test", time.Now().Add(batchLeaseDuration))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when claiming a batch", err)
+	}
+	return b
+}
+
+var batchDeduction = deduction.Deduction{
+	Personal: 60_000.0,
+	KReceipt: 50_000.0,
+	Donation: 100_000.0,
+}
+
+func TestBatchRunner_Process(t *testing.T) {
+	data := "totalIncome,wht,donation\n"
+	data += "500000,0,0\n"
+	data += "ABC,0,0\n"
+	data += "750000,50000,15000"
+
+	t.Run("completed with row results", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader(data))
+		r := NewBatchRunner(store, 1)
+
+		// Act
+		err := r.process(context.Background(), claim(t, store))
+
+		// Assert
+		assert.NoError(t, err)
+		b, _ := r.Get("b1")
+		assert.Equal(t, BatchStatusCompleted, b.Status)
+		assert.Equal(t, 3, b.TotalRows)
+		assert.Equal(t, 3, b.ProcessedRows)
+		assert.Equal(t, 2, b.SucceededRows)
+		assert.Equal(t, 1, b.FailedRows)
+		assert.Equal(t, 100.0, b.Progress)
+		assert.NotNil(t, b.CompletedAt)
+
+		got := result(t, r, "b1")
+		assert.Equal(t, []CsvTaxRecord{
+			{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0},
+			{Row: 4, TotalIncome: 750_000.0, Tax: 11_250.0},
+		}, got.Taxes)
+		assert.Equal(t, []CsvRowError{
+			{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()},
+		}, got.Errors)
+	})
+
+	t.Run("resumes after the checkpoint", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{
+			ID:            "b1",
+			Status:        BatchStatusPending,
+			Deduction:     batchDeduction,
+			TotalRows:     3,
+			ProcessedRows: 2,
+			SucceededRows: 1,
+			FailedRows:    1,
+			CheckpointRow: 3,
+		}, strings.NewReader(data))
+		r := NewBatchRunner(store, 1)
+
+		// Act
+		err := r.process(context.Background(), claim(t, store))
+
+		// Assert
+		assert.NoError(t, err)
+		b, _ := r.Get("b1")
+		assert.Equal(t, BatchStatusCompleted, b.Status)
+		assert.Equal(t, 3, b.ProcessedRows)
+		assert.Equal(t, 2, b.SucceededRows)
+		assert.Equal(t, []BatchRowResult{
+			{Row: 4, Tax: &CsvTaxRecord{Row: 4, TotalIncome: 750_000.0, Tax: 11_250.0}, TaxLevels: []float64{0, 35_000.0, 26_250.0, 0, 0}},
+		}, store.results["b1"])
+	})
+
+	t.Run("cancelled; expect checkpoint and pending", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader(data))
+		r := NewBatchRunner(store, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		err := r.process(ctx, claim(t, store))
+
+		// Assert
+		assert.NoError(t, err)
+		b, _ := r.Get("b1")
+		assert.Equal(t, BatchStatusPending, b.Status)
+		assert.Equal(t, 3, b.TotalRows)
+		assert.Equal(t, 0, b.ProcessedRows)
+	})
+
+	t.Run("strict; expect failed at the first invalid row", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Options: BatchOptions{Strict: true}, Deduction: batchDeduction}, strings.NewReader(data))
+		r := NewBatchRunner(store, 1)
+
+		// Act
+		err := r.process(context.Background(), claim(t, store))
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCSVRow)
+		b, _ := r.Get("b1")
+		assert.Equal(t, BatchStatusFailed, b.Status)
+		assert.Equal(t, ErrInvalidCSVRow.Error(), b.Error)
+		assert.Equal(t, 2, b.ProcessedRows)
+		assert.Len(t, store.results["b1"], 2)
+	})
+
+	t.Run("invalid header; expect failed", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader("employee\nE001"))
+		r := NewBatchRunner(store, 1)
+
+		// Act
+		err := r.process(context.Background(), claim(t, store))
+
+		// Assert
+		assert.Error(t, err)
+		b, _ := r.Get("b1")
+		assert.Equal(t, BatchStatusFailed, b.Status)
+		assert.Equal(t, ErrInvalidCSVHeader.Error(), b.Error)
+	})
+}
+
+func TestBatchRunner_ProcessLeaseLost(t *testing.T) {
+	// Arrange
+	store := newMemoryBatchStore()
+	store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader("totalIncome\n500000"))
+	claimed := claim(t, store)
+	stolen, _ := store.GetBatch("b1")
+	stolen.Owner = "other"
+	store.batches["b1"] = stolen
+	r := NewBatchRunner(store, 1)
+
+	// Act
+	err := r.process(context.Background(), claimed)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrBatchLeaseLost)
+	b, _ := store.GetBatch("b1")
+	assert.Equal(t, "other", b.Owner)
+	assert.Equal(t, BatchStatusRunning, b.Status)
+	assert.Equal(t, 0, b.ProcessedRows)
+	assert.Empty(t, store.results["b1"])
+}
+
+func TestBatchRunner_Cancel(t *testing.T) {
+	t.Run("running on this instance; expect run stopped", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader("totalIncome\n500000"))
+		claimed := claim(t, store)
+		r := NewBatchRunner(store, 1)
+		stopped := false
+		r.running["b1"] = func() { stopped = true }
+
+		// Act
+		got, err := r.Cancel("b1")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, BatchStatusCancelled, got.Status)
+		assert.True(t, stopped)
+		assert.ErrorIs(t, r.process(context.Background(), claimed), ErrBatchLeaseLost)
+		b, _ := store.GetBatch("b1")
+		assert.Equal(t, BatchStatusCancelled, b.Status)
+		assert.Empty(t, store.results["b1"])
+	})
+
+	t.Run("finished batch; expect ErrBatchFinished", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusCompleted}, nil)
+		r := NewBatchRunner(store, 1)
+
+		// Act
+		_, err := r.Cancel("b1")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrBatchFinished)
+	})
+
+	t.Run("unknown batch; expect ErrBatchNotFound", func(t *testing.T) {
+		// Arrange
+		r := NewBatchRunner(newMemoryBatchStore(), 1)
+
+		// Act
+		_, err := r.Cancel("missing")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrBatchNotFound)
+	})
+}
+
+func TestBatchRunner_ClaimNext(t *testing.T) {
+	t.Run("store error; expect batch released to pending", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader("totalIncome\n500000"))
+		store.err = errors.New("unexpected error")
+		r := NewBatchRunner(store, 1)
+		r.owner = "test"
+
+		// Act
+		again := r.claimNext()
+
+		// Assert
+		assert.False(t, again)
+		b, _ := store.GetBatch("b1")
+		assert.Equal(t, BatchStatusPending, b.Status)
+	})
+
+	t.Run("batch running on a live lease; expect not claimed", func(t *testing.T) {
+		// Arrange
+		store := newMemoryBatchStore()
+		store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader("totalIncome\n500000"))
+		claim(t, store)
+		r := NewBatchRunner(store, 1)
+		r.owner = "second"
+
+		// Act
+		again := r.claimNext()
+
+		// Assert
+		assert.False(t, again)
+		b, _ := store.GetBatch("b1")
+		assert.Equal(t, "test", b.Owner)
+		assert.Equal(t, 0, b.ProcessedRows)
+	})
+}
+
+func TestBatchRunner_ProcessCheckpoints(t *testing.T) {
+	// Arrange
+	var sb strings.Builder
+	sb.WriteString("totalIncome\n")
+	rows := batchCheckpointRows*2 + 1
+	for i := 0; i < rows; i++ {
+		sb.WriteString(fmt.Sprintf("%d\n", 500_000+i))
+	}
+	store := newMemoryBatchStore()
+	store.CreateBatch(Batch{ID: "b1", Status: BatchStatusPending, Deduction: batchDeduction}, strings.NewReader(sb.String()))
+	r := NewBatchRunner(store, 1)
+
+	// Act
+	err := r.process(context.Background(), claim(t, store))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, store.checkpoints)
+	assert.Len(t, store.results["b1"], rows)
+	assert.Equal(t, rows+1, store.batches["b1"].CheckpointRow)
+}
+
+func TestBatchRunner_Finished_NotFinished(t *testing.T) {
+	// Arrange
+	store := newMemoryBatchStore()
+	store.CreateBatch(Batch{ID: "b1", Status: BatchStatusRunning}, nil)
+	r := NewBatchRunner(store, 1)
+
+	// Act
+	_, err := r.Finished("b1")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrBatchNotFinished)
+}
+
+func TestBatchRunner_SubmitAndShutdown(t *testing.T) {
+	// Arrange
+	store := newMemoryBatchStore()
+	store.CreateBatch(Batch{ID: "left-over", Status: BatchStatusRunning, Deduction: batchDeduction}, strings.NewReader("totalIncome\n500000"))
+	r := NewBatchRunner(store, 2)
+
+	// Act
+	startErr := r.Start()
+	b, submitErr := r.Submit(Batch{Deduction: batchDeduction}, strings.NewReader("totalIncome\n600000"))
+	assert.Eventually(t, func() bool {
+		submitted, _ := r.Get(b.ID)
+		leftOver, _ := r.Get("left-over")
+		return submitted.Status == BatchStatusCompleted && leftOver.Status == BatchStatusCompleted
+	}, time.Second, 10*time.Millisecond)
+	shutdownErr := r.Shutdown(context.Background())
+
+	// Assert
+	assert.NoError(t, startErr)
+	assert.NoError(t, submitErr)
+	assert.NotEmpty(t, b.ID)
+	assert.Equal(t, BatchStatusPending, b.Status)
+	assert.NoError(t, shutdownErr)
+}
+
+func TestBatchRunner_Submit_Error(t *testing.T) {
+	// Arrange
+	store := newMemoryBatchStore()
+	store.err = errors.New("unexpected error")
+	r := NewBatchRunner(store, 1)
+
+	// Act
+	_, err := r.Submit(Batch{}, nil)
+
+	// Assert
+	assert.Error(t, err)
+}
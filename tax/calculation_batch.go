@@ -0,0 +1,296 @@
+package tax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// calculationLineMaxBytes is the longest NDJSON line accepted by
+// /tax/calculations/batch.
+const calculationLineMaxBytes = 1024 * 1024
+
+// CalculationItem is one person of a calculation batch: the same tax
+// information as /tax/calculations, identified by an id chosen by the client.
+type CalculationItem struct {
+	ID string `json:"id"`
+	TaxInformation
+}
+
+// CalculationItemResult is the outcome of one item, at the same index as in
+// the request. Exactly one of Result and Error is set.
+type CalculationItemResult struct {
+	Index  int        `json:"index"`
+	ID     string     `json:"id,omitempty"`
+	Result *TaxResult `json:"result,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+type CalculationBatchResponse struct {
+	Results   []CalculationItemResult `json:"results"`
+	Succeeded int                     `json:"succeeded"`
+	Failed    int                     `json:"failed"`
+}
+
+// decodedItem is an item of the request, or the reason it could not be read.
+type decodedItem struct {
+	item CalculationItem
+	err  error
+}
+
+func decodeItem(raw []byte) decodedItem {
+	var item CalculationItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		// keep the id, when it can be read, to tell which item failed
+		var idOnly struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(raw, &idOnly)
+		return decodedItem{item: CalculationItem{ID: idOnly.ID}, err: errors.Join(err, ErrReadingRequestBody)}
+	}
+	return decodedItem{item: item}
+}
+
+// decodeCalculationItems reads a json array of items, or one item per line
+// when the body is NDJSON. An item that is not valid json is reported on its
+// own; a body that cannot be split into items fails as a whole.
+func decodeCalculationItems(body io.Reader, contentType string, maxItems int) ([]decodedItem, error) {
+	if strings.HasPrefix(contentType, MIMEApplicationNDJSON) {
+		return decodeNDJSONItems(body, maxItems)
+	}
+
+	dec := json.NewDecoder(body)
+	if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+		return nil, ErrReadingRequestBody
+	}
+	items := make([]decodedItem, 0)
+	for dec.More() {
+		if len(items) == maxItems {
+			return nil, ErrCalculationBatchTooLarge
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, errors.Join(err, ErrReadingRequestBody)
+		}
+		items = append(items, decodeItem(raw))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, errors.Join(err, ErrReadingRequestBody)
+	}
+	return items, nil
+}
+
+func decodeNDJSONItems(body io.Reader, maxItems int) ([]decodedItem, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), calculationLineMaxBytes)
+
+	items := make([]decodedItem, 0)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxItems {
+			return nil, ErrCalculationBatchTooLarge
+		}
+		items = append(items, decodeItem(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Join(err, ErrReadingRequestBody)
+	}
+	return items, nil
+}
+
+// calculationExportColumns are the input columns of an exported calculation
+// batch: the index and id of each item.
+var calculationExportColumns = []string{"index", "id"}
+
+// writeCalculationResults writes the results of a calculation batch as the
+// rows of an export, lined up with exportHeader.
+func writeCalculationResults(tw tableWriter, response CalculationBatchResponse, lang i18n.Language) error {
+	if err := writeHeader(tw, exportHeader(calculationExportColumns, lang)); err != nil {
+		return err
+	}
+	for _, result := range response.Results {
+		values := appendExportResult([]any{float64(result.Index), result.ID}, result.Result, result.Error)
+		if err := tw.writeRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calculateItem runs the checks of /tax/calculations on one item and
+// calculates it. The error returned is the message reported for the item.
+func (h *Handler) calculateItem(validate *validator.Validate, info TaxInformation, deductionData deduction.Deduction) (TaxResult, error) {
+	if err := validate.Struct(info); err != nil {
+		return TaxResult{}, ErrInvalidTaxInformation
+	}
+
+	info, conversions, err := convertForeignAmounts(info, h.store.GetExchangeRate)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTaxInformation):
+			return TaxResult{}, ErrInvalidTaxInformation
+		case errors.Is(err, exchange.ErrRateNotFound):
+			return TaxResult{}, ErrExchangeRateNotFound
+		default:
+			return TaxResult{}, ErrGettingExchangeRate
+		}
+	}
+
+	result, err := CalculateTax(info, deductionData)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTaxInformation) {
+			return TaxResult{}, ErrInvalidTaxInformation
+		}
+		return TaxResult{}, ErrCalculatingTax
+	}
+	result.Conversions = conversions
+	return result, nil
+}
+
+// deductionResolver returns the deduction of an item: the one in force at its
+// asOf, or the one taken for the batch when it has none. Each point in time is
+// read from the store once.
+func (h *Handler) deductionResolver(batchDeduction deduction.Deduction) func(asOf string) (deduction.Deduction, error) {
+	resolved := make(map[time.Time]deduction.Deduction)
+	return func(asOf string) (deduction.Deduction, error) {
+		if asOf == "" {
+			return batchDeduction, nil
+		}
+		t, err := deductionTime(asOf)
+		if err != nil {
+			return deduction.Deduction{}, ErrInvalidTaxInformation
+		}
+		if d, ok := resolved[t]; ok {
+			return d, nil
+		}
+		d, err := h.store.GetDeduction(t)
+		if err != nil {
+			return deduction.Deduction{}, ErrGettingDeduction
+		}
+		resolved[t] = d
+		return d, nil
+	}
+}
+
+// calculateItems calculates every item without an asOf against the same
+// deduction, so a change made by an admin halfway through does not split the
+// batch.
+func (h *Handler) calculateItems(c echo.Context, items []decodedItem, deductionData deduction.Deduction) CalculationBatchResponse {
+	lang := i18n.FromRequest(c.Request())
+	validate := validator.New()
+	deductionAt := h.deductionResolver(deductionData)
+	seen := make(map[string]bool, len(items))
+	response := CalculationBatchResponse{Results: make([]CalculationItemResult, len(items))}
+
+	for i, decoded := range items {
+		id := strings.TrimSpace(decoded.item.ID)
+		err := decoded.err
+		var result TaxResult
+		switch {
+		case err != nil:
+			c.Logger().Printf("error reading batch item %d: %v", i, err)
+			err = ErrReadingRequestBody
+		case id == "":
+			err = ErrMissingCalculationID
+		case seen[id]:
+			err = ErrDuplicateCalculationID
+		default:
+			seen[id] = true
+			var itemDeduction deduction.Deduction
+			if itemDeduction, err = deductionAt(decoded.item.AsOf); err == nil {
+				result, err = h.calculateItem(validate, decoded.item.TaxInformation, itemDeduction)
+			}
+		}
+
+		response.Results[i] = CalculationItemResult{Index: i, ID: id}
+		if err != nil {
+			response.Results[i].Error = messages.Translate(lang, err.Error())
+			response.Failed++
+			continue
+		}
+		localised := localiseTaxResult(result, lang)
+		response.Results[i].Result = &localised
+		response.Succeeded++
+	}
+	return response
+}
+
+// CalculateBatchHandler
+//
+//	@Summary		Calculate tax for many people at once
+//	@Description	Calculate tax for a json array of items, or one item per line with Content-Type: application/x-ndjson. An item is the body of /tax/calculations with an id chosen by the client, and is checked and calculated the same way.
+//	@Description	Every item without an asOf is calculated with the same deduction; an item with one uses the deduction in force at that time. Each result carries the index and id of its item, and either the tax result or the reason the item failed; an id that is missing or repeats an earlier one fails its item.
+//	@Description	With Accept: application/x-ndjson the results are written one per line; text/csv and xlsx export one row per item, with its index and id, like /tax/calculations/upload-csv. The number of items is limited by the server.
+//	@Tags			tax
+//	@Accept			json
+//	@Accept			application/x-ndjson
+//	@Param			items			body	[]CalculationItem	true	"People to calculate tax for"
+//	@Param			Accept			header	string				false	"application/json (default), application/x-ndjson, text/csv or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+//	@Param			Accept-Language	header	string				false	"Language of messages and tax level labels (en, th)"
+//	@Produce		json
+//	@Produce		application/x-ndjson
+//	@Produce		text/csv
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Success		200	{object}	CalculationBatchResponse
+//	@Failure		400	{object}	Err
+//	@Failure		413	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/calculations/batch [post]
+func (h *Handler) CalculateBatchHandler(c echo.Context) error {
+	req := c.Request()
+	items, err := decodeCalculationItems(req.Body, req.Header.Get(echo.HeaderContentType), h.batchMaxItems)
+	if err != nil {
+		if errors.Is(err, ErrCalculationBatchTooLarge) {
+			return h.handleError(c, http.StatusRequestEntityTooLarge, err, "reading request body", ErrCalculationBatchTooLarge.Error())
+		}
+		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
+	}
+
+	deductionData, err := h.store.GetDeduction(time.Now())
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+
+	response := h.calculateItems(c, items, deductionData)
+	switch negotiateResultFormat(req.Header.Get(echo.HeaderAccept)) {
+	case MIMEApplicationNDJSON:
+		return h.streamCalculationResults(c, response)
+	case MIMETextCSV:
+		return h.sendCSV(c, func(tw tableWriter) error {
+			return writeCalculationResults(tw, response, i18n.FromRequest(req))
+		})
+	case MIMEApplicationXLSX:
+		return h.sendXLSX(c, func(tw tableWriter) error {
+			return writeCalculationResults(tw, response, i18n.FromRequest(req))
+		})
+	default:
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+func (h *Handler) streamCalculationResults(c echo.Context, response CalculationBatchResponse) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	res.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(res)
+	for _, result := range response.Results {
+		if err := enc.Encode(result); err != nil {
+			c.Logger().Printf("error streaming batch results: %v", err)
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeCalculationItems(t *testing.T) {
+	t.Run("json array", func(t *testing.T) {
+		// Arrange
+		body := `[{"id":"E1","totalIncome":500000},{"id":"E2","totalIncome":"ABC"}]`
+
+		// Act
+		got, err := decodeCalculationItems(strings.NewReader(body), echo.MIMEApplicationJSON, 10)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, CalculationItem{ID: "E1", TaxInformation: TaxInformation{TotalIncome: 500_000.0}}, got[0].item)
+		assert.ErrorIs(t, got[1].err, ErrReadingRequestBody)
+	})
+
+	t.Run("ndjson skips blank lines", func(t *testing.T) {
+		// Arrange
+		body := "{\"id\":\"E1\",\"totalIncome\":500000}\n\n{\"id\":\"E2\",\"totalIncome\":600000}\n"
+
+		// Act
+		got, err := decodeCalculationItems(strings.NewReader(body), MIMEApplicationNDJSON+"; charset=utf-8", 10)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "E2", got[1].item.ID)
+	})
+
+	t.Run("more items than the limit; expect too large", func(t *testing.T) {
+		// Arrange
+		body := `[{"id":"E1"},{"id":"E2"},{"id":"E3"}]`
+
+		// Act
+		_, err := decodeCalculationItems(strings.NewReader(body), echo.MIMEApplicationJSON, 2)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrCalculationBatchTooLarge)
+	})
+
+	t.Run("not an array; expect error", func(t *testing.T) {
+		// Act
+		_, err := decodeCalculationItems(strings.NewReader(`{"id":"E1"}`), echo.MIMEApplicationJSON, 10)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrReadingRequestBody)
+	})
+
+	t.Run("broken array; expect error", func(t *testing.T) {
+		// Act
+		_, err := decodeCalculationItems(strings.NewReader(`[{"id":"E1"},`), echo.MIMEApplicationJSON, 10)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrReadingRequestBody)
+	})
+}
+
+func TestCalculateBatchHandler(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	post := func(body, contentType, accept string, opts ...Option) (*httptest.ResponseRecorder, *mockTaxStorer) {
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/batch", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		mock := NewMockTaxStorer()
+		mock.deduction = defaultDeduction
+		mock.ExpectToCall(MethodGetDeduction)
+		assert.NoError(t, New(mock, opts...).CalculateBatchHandler(c))
+		return rec, mock
+	}
+
+	t.Run("per-item results and errors", func(t *testing.T) {
+		// Arrange
+		body := `[
+			{"id":"E1","totalIncome":500000,"wht":0},
+			{"id":"E2","totalIncome":-1},
+			{"totalIncome":500000},
+			{"id":"E1","totalIncome":600000},
+			{"id":"E5","totalIncome":"ABC"}
+		]`
+
+		// Act
+		rec, mock := post(body, echo.MIMEApplicationJSON, "")
+
+		// Assert
+		mock.Verify(t)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CalculationBatchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, 1, got.Succeeded)
+		assert.Equal(t, 4, got.Failed)
+		assert.Equal(t, 29_000.0, got.Results[0].Result.Tax)
+		assert.Equal(t, []CalculationItemResult{
+			{Index: 1, ID: "E2", Error: ErrInvalidTaxInformation.Error()},
+			{Index: 2, Error: ErrMissingCalculationID.Error()},
+			{Index: 3, ID: "E1", Error: ErrDuplicateCalculationID.Error()},
+			{Index: 4, ID: "E5", Error: ErrReadingRequestBody.Error()},
+		}, got.Results[1:])
+	})
+
+	t.Run("ndjson in and out", func(t *testing.T) {
+		// Arrange
+		body := "{\"id\":\"E1\",\"totalIncome\":500000}\n{\"id\":\"E2\",\"totalIncome\":500000,\"wht\":25000}\n"
+
+		// Act
+		rec, _ := post(body, MIMEApplicationNDJSON, MIMEApplicationNDJSON)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEApplicationNDJSON, rec.Header().Get(echo.HeaderContentType))
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		assert.Len(t, lines, 2)
+		var second CalculationItemResult
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+		assert.Equal(t, "E2", second.ID)
+		assert.Equal(t, 4_000.0, second.Result.Tax)
+	})
+
+	t.Run("text/csv; expect one row per item", func(t *testing.T) {
+		// Arrange
+		body := `[{"id":"E1","totalIncome":500000},{"id":"=E2","totalIncome":-1}]`
+
+		// Act
+		rec, _ := post(body, echo.MIMEApplicationJSON, MIMETextCSV)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		want := "\uFEFF"
+		want += "index,id,tax,taxRefund,\"0-150,000\",\"150,001-500,000\",\"500,001-1,000,000\",\"1,000,001-2,000,000\",\"2,000,001 ขึ้นไป\",error\n"
+		want += "0,E1,29000,0,0,29000,0,0,0,\n"
+		want += "1,'=E2,,,,,,,," + ErrInvalidTaxInformation.Error() + "\n"
+		assert.Equal(t, want, rec.Body.String())
+	})
+
+	t.Run("xlsx; expect one row per item", func(t *testing.T) {
+		// Arrange
+		body := `[{"id":"E1","totalIncome":500000}]`
+
+		// Act
+		rec, _ := post(body, echo.MIMEApplicationJSON, MIMEApplicationXLSX)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEApplicationXLSX, rec.Header().Get(echo.HeaderContentType))
+		f, err := excelize.OpenReader(rec.Body)
+		if err != nil {
+			t.Fatalf("expected response body to be xlsx, got %v", err)
+		}
+		rows, err := f.GetRows(xlsxSheetName)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"index", "id", "tax", "taxRefund"}, rows[0][:4])
+		assert.Equal(t, []string{"0", "E1", "29000", "0", "0", "29000", "0", "0", "0"}, rows[1])
+	})
+
+	t.Run("thai; expect translated errors", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/batch", bytes.NewBufferString(`[{"totalIncome":500000}]`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(i18n.HeaderAcceptLanguage, "th")
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+
+		// Act
+		err := New(NewMockTaxStorer()).CalculateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		var got CalculationBatchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, ErrMissingCalculationID.Error()), got.Results[0].Error)
+	})
+
+	t.Run("more items than the limit; expect request entity too large", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/batch", bytes.NewBufferString(`[{"id":"E1"},{"id":"E2"}]`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+
+		// Act
+		err := New(NewMockTaxStorer(), WithCalculationBatchMaxItems(1)).CalculateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("get deduction error; expect internal server error", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/batch", bytes.NewBufferString(`[{"id":"E1","totalIncome":500000}]`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		mock := NewMockTaxStorer()
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := New(mock).CalculateBatchHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestCalculateBatchHandler_AsOf(t *testing.T) {
+	// Arrange
+	asOf := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	body := `[
+		{"id":"E1","totalIncome":500000},
+		{"id":"E2","totalIncome":500000,"asOf":"2025-01-01"},
+		{"id":"E3","totalIncome":500000,"asOf":"2025-01-01"},
+		{"id":"E4","totalIncome":500000,"asOf":"soon"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/tax/calculations/batch", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	mock := NewMockTaxStorer()
+	mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+	mock.deductionAt = map[time.Time]deduction.Deduction{
+		asOf: {Personal: 100_000.0, KReceipt: 50_000.0, Donation: 100_000.0},
+	}
+
+	// Act
+	err := New(mock).CalculateBatchHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	var got CalculationBatchResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, 29_000.0, got.Results[0].Result.Tax)
+	assert.Equal(t, 25_000.0, got.Results[1].Result.Tax)
+	assert.Equal(t, 25_000.0, got.Results[2].Result.Tax)
+	assert.Equal(t, ErrInvalidTaxInformation.Error(), got.Results[3].Error)
+	assert.Equal(t, 3, got.Succeeded)
+	assert.Equal(t, 1, got.Failed)
+}
@@ -38,61 +38,84 @@ func calculateNetIncome(totalIncome, personalDeduction, totalAllowance float64)
 	return result
 }
 
-func CalculateTax(info TaxInformation, deduction deduction.Deduction) (TaxResult, error) {
+func calculateProgressiveTax(netIncome float64) (float64, []TaxLevel) {
+	total := 0.0
+	levels := make([]TaxLevel, 0, len(rates))
+	for _, r := range rates {
+		tax := calculateTaxForRate(r, netIncome)
+		total += tax
+		levels = append(levels, TaxLevel{
+			Level: r.description,
+			Tax:   tax,
+		})
+	}
+	return total, levels
+}
+
+func validateCalculation(info TaxInformation, deduction deduction.Deduction) error {
 	err := validateTaxInformation(info)
 	if err != nil {
-		err = errors.Join(err, ErrInvalidTaxInformation)
-		return TaxResult{}, err
+		return errors.Join(err, ErrInvalidTaxInformation)
 	}
 
 	err = deduction.Validate()
 	if err != nil {
-		err = errors.Join(err, ErrInvalidDeduction)
-		return TaxResult{}, err
+		return errors.Join(err, ErrInvalidDeduction)
 	}
+	return nil
+}
 
+func calculateTax(info TaxInformation, deduction deduction.Deduction) TaxResult {
 	totalAllowance := getTotalAllowance(info.Allowances, deduction)
 
-	netIncome := calculateNetIncome(info.TotalIncome, deduction.Personal, totalAllowance)
+	severance := calculateSeverance(info.Severance)
+
+	netIncome := calculateNetIncome(info.TotalIncome+severance.regularIncome, deduction.Personal, totalAllowance)
 
 	taxResult := TaxResult{
 		Tax:       0.0,
 		TaxRefund: 0.0,
-		TaxLevels: make([]TaxLevel, 0),
+		Severance: severance.result,
 	}
-	for _, r := range rates {
-		tax := calculateTaxForRate(r, netIncome)
-		taxResult.Tax += tax
-		taxResult.TaxLevels = append(taxResult.TaxLevels, TaxLevel{
-			Level: r.description,
-			Tax:   tax,
-		})
+	taxResult.Tax, taxResult.TaxLevels = calculateProgressiveTax(netIncome)
+	if severance.result != nil {
+		taxResult.Tax += severance.result.Tax
 	}
 
-	taxResult.Tax -= info.WHT
+	taxResult.Tax -= info.WHT + info.InterimTax
 	if taxResult.Tax < 0 {
 		taxResult.TaxRefund = -taxResult.Tax
 		taxResult.Tax = 0.0
 	}
 
-	return taxResult, nil
+	return taxResult
 }
 
-func CalculateTaxFromCSV(records []TaxInformation, deductionData deduction.Deduction) (CsvTaxResponse, error) {
-	result := CsvTaxResponse{}
+func CalculateTax(info TaxInformation, deduction deduction.Deduction) (TaxResult, error) {
+	if err := validateCalculation(info, deduction); err != nil {
+		return TaxResult{}, err
+	}
 
-	for _, taxInfo := range records {
-		taxResult, err := CalculateTax(taxInfo, deductionData)
-		if err != nil {
-			return CsvTaxResponse{}, ErrCalculatingTax
-		}
+	return calculateTax(info, deduction), nil
+}
 
-		result.Taxes = append(result.Taxes, CsvTaxRecord{
-			TotalIncome: taxInfo.TotalIncome,
-			Tax:         taxResult.Tax,
-			TaxRefund:   taxResult.TaxRefund,
-		})
+// calculateCSVRow calculates one csv row, or reports why the row has to be
+// skipped. The deduction must have been validated by the caller.
+func calculateCSVRow(row csvRow, deductionData deduction.Deduction) (TaxResult, []*rowError) {
+	if err := validateTaxInformation(row.info); err != nil {
+		return TaxResult{}, validationRowErrors(row, err)
 	}
 
-	return result, nil
+	return calculateTax(row.info, deductionData), nil
+}
+
+func csvTaxRecord(row csvRow, result TaxResult) CsvTaxRecord {
+	return CsvTaxRecord{
+		Row:         row.number,
+		ID:          row.id,
+		TotalIncome: row.info.TotalIncome,
+		Tax:         result.Tax,
+		TaxRefund:   result.TaxRefund,
+		Metadata:    row.metadata,
+	}
 }
@@ -470,6 +470,109 @@ func TestCalculateTax_WithTaxLevel(t *testing.T) {
 	}
 }
 
+func TestCalculateTax_WithSeverance(t *testing.T) {
+	// Arrange
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	testCases := []struct {
+		name          string
+		taxInfo       TaxInformation
+		wantTax       float64
+		wantTaxRefund float64
+		wantTaxLevels []float64
+	}{
+		{
+			name: "separate severance tax added to regular tax",
+			taxInfo: TaxInformation{
+				TotalIncome: 500_000.0,
+				Severance:   &Severance{Amount: 1_000_000.0, YearsOfService: 10, LastSalary: 30_000.0},
+			},
+			wantTax:       29_000.0 + 11_500.0,
+			wantTaxLevels: []float64{0.0, 29_000.0, 0.0, 0.0, 0.0},
+		},
+		{
+			name: "severance only; WHT credited against severance tax",
+			taxInfo: TaxInformation{
+				WHT:       20_000.0,
+				Severance: &Severance{Amount: 1_000_000.0, YearsOfService: 10, LastSalary: 30_000.0},
+			},
+			wantTaxRefund: 8_500.0,
+			wantTaxLevels: []float64{0.0, 0.0, 0.0, 0.0, 0.0},
+		},
+		{
+			name: "less than 5 years; taxable severance joins regular brackets",
+			taxInfo: TaxInformation{
+				TotalIncome: 500_000.0,
+				Severance:   &Severance{Amount: 500_000.0, YearsOfService: 4, LastSalary: 30_000.0},
+			},
+			wantTax:       35_000.0 + 6_000.0,
+			wantTaxLevels: []float64{0.0, 35_000.0, 6_000.0, 0.0, 0.0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := CalculateTax(tc.taxInfo, defaultDeduction)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTax, got.Tax)
+			assert.Equal(t, tc.wantTaxRefund, got.TaxRefund)
+			assert.NotNil(t, got.Severance)
+			for i, wantTax := range tc.wantTaxLevels {
+				assert.Equal(t, wantTax, got.TaxLevels[i].Tax)
+			}
+		})
+	}
+}
+
+func TestCalculateTax_WithInterimTax(t *testing.T) {
+	// Arrange
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	testCases := []struct {
+		name          string
+		taxInfo       TaxInformation
+		wantTax       float64
+		wantTaxRefund float64
+	}{
+		{
+			name:    "interim tax credited; expect remaining tax",
+			taxInfo: TaxInformation{TotalIncome: 600_000.0, InterimTax: 12_000.0},
+			wantTax: 29_000.0,
+		},
+		{
+			name:    "interim tax and WHT credited together",
+			taxInfo: TaxInformation{TotalIncome: 600_000.0, WHT: 20_000.0, InterimTax: 12_000.0},
+			wantTax: 9_000.0,
+		},
+		{
+			name:          "credits larger than tax; expect refund",
+			taxInfo:       TaxInformation{TotalIncome: 600_000.0, WHT: 30_000.0, InterimTax: 12_000.0},
+			wantTaxRefund: 1_000.0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := CalculateTax(tc.taxInfo, defaultDeduction)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTax, got.Tax)
+			assert.Equal(t, tc.wantTaxRefund, got.TaxRefund)
+		})
+	}
+}
+
 func TestCalculateTax_FromInvalidTaxInformation_Error(t *testing.T) {
 	// Arrange
 	defaultDeduction := deduction.Deduction{
@@ -580,77 +683,3 @@ func TestCalculateTax_FromInvalidDeduction_Error(t *testing.T) {
 		assert.Equal(t, TaxResult{}, got)
 	})
 }
-
-func TestCalculateTaxFromCSV_Success(t *testing.T) {
-	deductionData := deduction.Deduction{
-		Personal: 60_000.0,
-		KReceipt: 50_000.0,
-		Donation: 100_000.0,
-	}
-
-	t.Run("EXP06: multiple records", func(t *testing.T) {
-		// Arrange
-		records := []TaxInformation{
-			{
-				TotalIncome: 500_000.0,
-				WHT:         0.0,
-				Allowances: []Allowance{
-					{Type: AllowanceTypeDonation, Amount: 0.0},
-				},
-			},
-			{
-				TotalIncome: 600_000.0,
-				WHT:         40_000.0,
-				Allowances: []Allowance{
-					{Type: AllowanceTypeDonation, Amount: 20_000.0},
-				},
-			},
-			{
-				TotalIncome: 750_000.0,
-				WHT:         50_000.0,
-				Allowances: []Allowance{
-					{Type: AllowanceTypeDonation, Amount: 15_000.0},
-				},
-			},
-		}
-		want := CsvTaxResponse{
-			Taxes: []CsvTaxRecord{
-				{TotalIncome: 500_000.0, Tax: 29_000.0, TaxRefund: 0.0},
-				{TotalIncome: 600_000.0, Tax: 0.0, TaxRefund: 2_000.0},
-				{TotalIncome: 750_000.0, Tax: 11_250.0, TaxRefund: 0.0},
-			},
-		}
-
-		// Act
-		got, err := CalculateTaxFromCSV(records, deductionData)
-
-		// Assert
-		assert.NoError(t, err)
-		assert.Equal(t, want, got)
-	})
-}
-
-func TestCalculateTaxFromCSV_Error(t *testing.T) {
-	t.Run("empty deduction error when calculating tax", func(t *testing.T) {
-		// Arrange
-		emptyDeduction := deduction.Deduction{}
-		records := []TaxInformation{
-			{
-				TotalIncome: 500_000.0,
-				WHT:         0.0,
-				Allowances: []Allowance{
-					{Type: AllowanceTypeDonation, Amount: 0.0},
-				},
-			},
-		}
-		want := CsvTaxResponse{}
-
-		// Act
-		got, err := CalculateTaxFromCSV(records, emptyDeduction)
-
-		// Assert
-		assert.Error(t, err)
-		assert.ErrorIs(t, err, ErrCalculatingTax)
-		assert.Equal(t, want, got)
-	})
-}
@@ -2,114 +2,419 @@ package tax
 
 import (
 	"encoding/csv"
+	"errors"
 	"io"
-	"strconv"
+	"strings"
 )
 
+// recordReader yields the rows of an uploaded table one by one, io.EOF after
+// the last one. *csv.Reader is the default implementation.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
 type CSVReader struct {
-	reader io.Reader
+	reader              io.Reader
+	allowUnknownColumns bool
+	passThroughColumns  []string
+	columns             []csvColumn
+	idIndex             int
+	// ids holds the id of every accepted row, to reject duplicates. Unlike
+	// the rows, which can be streamed, it grows with the file: an ndjson
+	// response to a file with an id column keeps every id in memory, a small
+	// fraction of the file but not bounded by csvMaxBufferedRows.
+	ids map[string]struct{}
+
+	encoding  CSVEncoding
+	delimiter rune
+
+	xlsx         bool
+	xlsxSheet    string
+	xlsxMaxBytes int64
+
+	records   recordReader
+	rowNumber int
+}
+
+type CSVReaderOption func(*CSVReader)
+
+// WithUnknownColumns passes columns that are not tax fields through to the
+// result as metadata instead of rejecting the header.
+func WithUnknownColumns() CSVReaderOption {
+	return func(cr *CSVReader) {
+		cr.allowUnknownColumns = true
+	}
+}
+
+// WithPassThroughColumns echoes the named columns on every result as
+// metadata. They must be in the header, but need not be tax fields.
+func WithPassThroughColumns(names ...string) CSVReaderOption {
+	return func(cr *CSVReader) {
+		cr.passThroughColumns = append(cr.passThroughColumns, names...)
+	}
+}
+
+// WithEncoding reads a csv in enc instead of detecting its encoding. A byte
+// order mark is always skipped.
+func WithEncoding(enc CSVEncoding) CSVReaderOption {
+	return func(cr *CSVReader) {
+		cr.encoding = enc
+	}
+}
+
+// WithDelimiter separates the values of a csv with delimiter instead of a
+// comma.
+func WithDelimiter(delimiter rune) CSVReaderOption {
+	return func(cr *CSVReader) {
+		cr.delimiter = delimiter
+	}
+}
+
+// WithXLSX reads the upload as an xlsx workbook instead of a csv, from the
+// named sheet or from the first sheet when sheet is empty. The header rules
+// are the same as for a csv.
+func WithXLSX(sheet string) CSVReaderOption {
+	return func(cr *CSVReader) {
+		cr.xlsx = true
+		cr.xlsxSheet = sheet
+	}
 }
 
-func NewCSVReader(r io.Reader) *CSVReader {
-	return &CSVReader{reader: r}
+// WithXLSXUnzipLimit limits how much an xlsx workbook may unzip to.
+func WithXLSXUnzipLimit(n int64) CSVReaderOption {
+	return func(cr *CSVReader) {
+		if n > 0 {
+			cr.xlsxMaxBytes = n
+		}
+	}
+}
+
+func NewCSVReader(r io.Reader, opts ...CSVReaderOption) *CSVReader {
+	cr := &CSVReader{reader: r, idIndex: -1, ids: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(cr)
+	}
+	return cr
 }
 
 const (
 	csvHeaderRowIndex = 0
 )
 
-type csvColumn int
-
 const (
-	csvColumnTotalIncome csvColumn = iota
-	csvColumnWHT
-	csvColumnDonation
+	csvColumnTotalIncome = "totalIncome"
+	csvColumnWHT         = "wht"
+	csvColumnID          = "id"
 )
 
+// csvColumn describes a header the reader understands. A csvColumn with a nil
+// apply is an unknown column kept as metadata, except for the id column. A
+// blank value in a column that is not required is left at zero. A
+// pass-through column is also copied to metadata. example and note describe
+// the column in the downloadable template.
+type csvColumn struct {
+	name        string
+	required    bool
+	id          bool
+	passThrough bool
+	apply       func(info *TaxInformation, value float64)
+	example     string
+	note        string
+}
+
+func (col csvColumn) isMetadata() bool {
+	return col.apply == nil && !col.id
+}
+
+// allowanceColumnNotes explains an allowance column in the template. An
+// allowance type without a note is described by its name alone.
+var allowanceColumnNotes = map[AllowanceType]string{
+	AllowanceTypeDonation: "donations, deducted up to the donation cap",
+	AllowanceTypeKReceipt: "k-receipt purchases, deducted up to the k-receipt cap",
+}
+
+func allowanceColumn(aType AllowanceType) csvColumn {
+	return csvColumn{
+		name: string(aType),
+		apply: func(info *TaxInformation, value float64) {
+			info.Allowances = append(info.Allowances, Allowance{Type: aType, Amount: value})
+		},
+		example: "0",
+		note:    allowanceColumnNotes[aType],
+	}
+}
+
+// csvColumns lists every column accepted in a tax csv: the income fields
+// followed by one optional column per registered allowance type.
+func csvColumns() []csvColumn {
+	columns := []csvColumn{
+		{
+			name:     csvColumnTotalIncome,
+			required: true,
+			apply:    func(info *TaxInformation, value float64) { info.TotalIncome = value },
+			example:  "500000",
+			note:     "total income of the year",
+		},
+		{
+			name:    csvColumnWHT,
+			apply:   func(info *TaxInformation, value float64) { info.WHT = value },
+			example: "0",
+			note:    "withholding tax already paid, at most the total income",
+		},
+	}
+	for _, aType := range allowanceTypes {
+		columns = append(columns, allowanceColumn(aType))
+	}
+	return append(columns, csvColumn{
+		name:    csvColumnID,
+		id:      true,
+		example: "E001",
+		note:    "optional row identifier such as an employee number, unique within the file",
+	})
+}
+
+func findCSVColumn(name string) (csvColumn, bool) {
+	for _, col := range csvColumns() {
+		if col.name == name {
+			return col, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+func (cr *CSVReader) isPassThrough(name string) bool {
+	for _, passThrough := range cr.passThroughColumns {
+		if passThrough == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeader reports every problem of the header at once, each as a row
+// error naming its column, joined with ErrInvalidCSVHeader.
 func (cr *CSVReader) validateHeader(header []string) error {
-	if len(header) != 3 {
-		return ErrInvalidCSVHeader
+	columns := make([]csvColumn, 0, len(header))
+	seen := make(map[string]bool, len(header))
+	idIndex := -1
+	problems := make([]error, 0)
+	problem := func(name string, err error) {
+		problems = append(problems, &rowError{row: csvHeaderRowIndex + 1, column: name, err: err})
+	}
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			problem(name, ErrDuplicateCSVColumn)
+			continue
+		}
+		seen[name] = true
+
+		col, ok := findCSVColumn(name)
+		if !ok {
+			if !cr.allowUnknownColumns && !cr.isPassThrough(name) {
+				problem(name, ErrUnknownCSVColumn)
+				continue
+			}
+			col = csvColumn{name: name}
+		}
+		col.passThrough = cr.isPassThrough(name)
+		if col.id {
+			idIndex = i
+		}
+		columns = append(columns, col)
 	}
 
-	if header[0] != "totalIncome" || header[1] != "wht" || header[2] != "donation" {
-		return ErrInvalidCSVHeader
+	for _, col := range csvColumns() {
+		if col.required && !seen[col.name] {
+			problem(col.name, ErrMissingCSVColumn)
+		}
+	}
+	for _, name := range cr.passThroughColumns {
+		if !seen[name] {
+			problem(name, ErrMissingCSVColumn)
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Join(append(problems, ErrInvalidCSVHeader)...)
 	}
 
+	cr.columns = columns
+	cr.idIndex = idIndex
 	return nil
 }
 
-func (cr *CSVReader) getColumnValue(row []string, column csvColumn) (float64, error) {
-	result, err := strconv.ParseFloat(row[column], 64)
-	if err != nil {
-		return 0, ErrParsingData
-	}
-	return result, nil
+func (cr *CSVReader) getColumnValue(value string) (float64, error) {
+	return parseAmount(value)
 }
 
 func (cr *CSVReader) getTaxInformation(row []string) (TaxInformation, error) {
 	taxInfo := TaxInformation{}
 
-	if len(row) != 3 {
-		return TaxInformation{}, ErrParsingData
+	if len(row) != len(cr.columns) {
+		return TaxInformation{}, &rowError{err: ErrCSVColumnCount}
+	}
+
+	for i, col := range cr.columns {
+		if col.isMetadata() || col.id {
+			continue
+		}
+
+		if !col.required && strings.TrimSpace(row[i]) == "" {
+			continue
+		}
+
+		value, err := cr.getColumnValue(row[i])
+		if err != nil {
+			return TaxInformation{}, &rowError{column: col.name, value: row[i], err: err}
+		}
+		col.apply(&taxInfo, value)
 	}
 
-	var err error
+	return taxInfo, nil
+}
 
-	if taxInfo.TotalIncome, err = cr.getColumnValue(row, csvColumnTotalIncome); err != nil {
-		return TaxInformation{}, err
+// recordID returns the value of the id column of record, or "" when the file
+// has no id column.
+func (cr *CSVReader) recordID(record []string) string {
+	if cr.idIndex < 0 || cr.idIndex >= len(record) {
+		return ""
 	}
+	return strings.TrimSpace(record[cr.idIndex])
+}
 
-	if taxInfo.WHT, err = cr.getColumnValue(row, csvColumnWHT); err != nil {
-		return TaxInformation{}, err
+// parseRow parses a data row. The id of a row is remembered once the row is
+// accepted, parsed and valid, so that a later row with the same id is
+// rejected while a row fixing a rejected one can reuse its id.
+func (cr *CSVReader) parseRow(number int, record []string) (csvRow, *rowError) {
+	row := csvRow{number: number, id: cr.recordID(record), values: append([]string(nil), record...)}
+
+	if row.id != "" {
+		if _, ok := cr.ids[row.id]; ok {
+			return row, &rowError{row: number, id: row.id, column: csvColumnID, value: row.id, err: ErrDuplicateCSVID}
+		}
 	}
 
-	donation, err := cr.getColumnValue(row, csvColumnDonation)
+	taxInfo, err := cr.getTaxInformation(record)
 	if err != nil {
-		return TaxInformation{}, err
+		rowErr := asRowError(number, err)
+		rowErr.id = row.id
+		return row, rowErr
 	}
-	taxInfo.Allowances = []Allowance{
-		{
-			Type:   AllowanceTypeDonation,
-			Amount: donation,
-		},
+	row.info = taxInfo
+	row.raw = cr.rawValues(record)
+	row.metadata = cr.metadata(record)
+	if row.id != "" && validateTaxInformation(taxInfo) == nil {
+		cr.ids[row.id] = struct{}{}
 	}
+	return row, nil
+}
 
-	return taxInfo, nil
+func (cr *CSVReader) columnNames() []string {
+	result := make([]string, len(cr.columns))
+	for i, col := range cr.columns {
+		result[i] = col.name
+	}
+	return result
 }
 
-func (cr *CSVReader) parseRow(index int, row []string, data *[]TaxInformation) error {
-	if index == csvHeaderRowIndex {
-		return cr.validateHeader(row)
+// metadata returns the unknown and pass-through columns of a row, which are
+// echoed on its result, or nil when there are none.
+func (cr *CSVReader) metadata(row []string) map[string]string {
+	var result map[string]string
+	for i, col := range cr.columns {
+		if !col.isMetadata() && !col.passThrough {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[col.name] = row[i]
+	}
+	return result
+}
+
+func (cr *CSVReader) rawValues(row []string) map[string]string {
+	result := make(map[string]string, len(cr.columns))
+	for i, col := range cr.columns {
+		result[col.name] = row[i]
+	}
+	return result
+}
+
+func (cr *CSVReader) newCSVParser() *csv.Reader {
+	reader := csv.NewReader(decodeCSV(cr.reader, cr.encoding))
+	reader.FieldsPerRecord = -1
+	if cr.delimiter != 0 {
+		reader.Comma = cr.delimiter
+	}
+	return reader
+}
+
+func (cr *CSVReader) openRecords() (recordReader, error) {
+	if cr.xlsx {
+		return openXLSXRecords(cr.reader, cr.xlsxSheet, cr.xlsxMaxBytes)
 	}
 
-	taxInfo, err := cr.getTaxInformation(row)
+	parser := cr.newCSVParser()
+	parser.ReuseRecord = true
+	return parser, nil
+}
+
+// readHeader reads and validates the header row. It must be called once
+// before next, and close must be called when the reader is done.
+func (cr *CSVReader) readHeader() error {
+	records, err := cr.openRecords()
 	if err != nil {
 		return err
 	}
+	cr.records = records
 
-	*data = append(*data, taxInfo)
-	return nil
+	header, err := cr.records.Read()
+	if err != nil {
+		return errors.Join(err, ErrReadingCSV)
+	}
+	cr.rowNumber = csvHeaderRowIndex + 1
+
+	return cr.validateHeader(header)
 }
 
-func (cr *CSVReader) parseTaxRecords(records [][]string) ([]TaxInformation, error) {
-	result := make([]TaxInformation, 0)
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
 
-	for rowIndex, rowData := range records {
-		err := cr.parseRow(rowIndex, rowData, &result)
+// next reads one data row so that a file is never held in memory as a whole.
+// Blank rows are skipped but still counted, so row numbers match the ones a
+// spreadsheet shows. It returns io.EOF after the last row, and ErrReadingCSV
+// when the file is not a valid csv any more, after which no further row can
+// be read. A row that cannot be parsed is returned as a row error.
+func (cr *CSVReader) next() (csvRow, *rowError, error) {
+	for {
+		record, err := cr.records.Read()
+		if err == io.EOF {
+			return csvRow{}, nil, io.EOF
+		}
+		cr.rowNumber++
 		if err != nil {
-			return nil, err
+			return csvRow{}, nil, errors.Join(err, ErrReadingCSV)
+		}
+		if isBlankRecord(record) {
+			continue
 		}
-	}
 
-	return result, nil
+		row, rowErr := cr.parseRow(cr.rowNumber, record)
+		return row, rowErr, nil
+	}
 }
 
-func (cr *CSVReader) readRecords() ([]TaxInformation, error) {
-	reader := csv.NewReader(cr.reader)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, ErrReadingCSV
+func (cr *CSVReader) close() error {
+	if closer, ok := cr.records.(io.Closer); ok {
+		return closer.Close()
 	}
-
-	return cr.parseTaxRecords(records)
+	return nil
 }
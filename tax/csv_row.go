@@ -0,0 +1,136 @@
+package tax
+
+import (
+	"errors"
+	"sort"
+)
+
+// csvRow is a parsed data row with its 1-based line number in the file, the
+// header being row 1, and its value in the id column if there is one. values
+// keeps the row as read, even when it could not be parsed. metadata holds the
+// columns echoed on the result, which are not part of the calculation.
+type csvRow struct {
+	number   int
+	id       string
+	values   []string
+	info     TaxInformation
+	raw      map[string]string
+	metadata map[string]string
+}
+
+type rowError struct {
+	row    int
+	id     string
+	column string
+	value  string
+	err    error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rowError) Unwrap() error {
+	return e.err
+}
+
+func (e *rowError) toCsvRowError() CsvRowError {
+	return CsvRowError{
+		Row:    e.row,
+		ID:     e.id,
+		Column: e.column,
+		Value:  e.value,
+		Reason: e.err.Error(),
+	}
+}
+
+// csvRowErrors merges row errors into the response shape, ordered by row.
+func csvRowErrors(errs ...[]*rowError) []CsvRowError {
+	result := make([]CsvRowError, 0)
+	for _, list := range errs {
+		for _, e := range list {
+			result = append(result, e.toCsvRowError())
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Row < result[j].Row
+	})
+	return result
+}
+
+func asRowError(number int, err error) *rowError {
+	var re *rowError
+	if errors.As(err, &re) {
+		re.row = number
+		return re
+	}
+	return &rowError{row: number, err: err}
+}
+
+// rowErrorsOf returns the row errors joined in err.
+func rowErrorsOf(err error) []*rowError {
+	result := make([]*rowError, 0)
+	for _, leaf := range leafErrors(err) {
+		if re, ok := leaf.(*rowError); ok {
+			result = append(result, re)
+		}
+	}
+	return result
+}
+
+func rowErrorsAsErrors(errs []*rowError) []error {
+	result := make([]error, len(errs))
+	for i, e := range errs {
+		result[i] = e
+	}
+	return result
+}
+
+func leafErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	result := make([]error, 0)
+	for _, e := range joined.Unwrap() {
+		result = append(result, leafErrors(e)...)
+	}
+	return result
+}
+
+func negativeAllowanceColumns(info TaxInformation) []string {
+	result := make([]string, 0)
+	for _, a := range info.Allowances {
+		if a.Amount < 0 {
+			result = append(result, string(a.Type))
+		}
+	}
+	return result
+}
+
+// validationRowErrors reports one row error per failed validation rule of a
+// csv row, pointing at the column that holds the offending value.
+func validationRowErrors(row csvRow, err error) []*rowError {
+	allowanceColumns := negativeAllowanceColumns(row.info)
+	result := make([]*rowError, 0)
+
+	for _, leaf := range leafErrors(err) {
+		column := ""
+		switch {
+		case errors.Is(leaf, ErrInvalidTotalIncome):
+			column = csvColumnTotalIncome
+		case errors.Is(leaf, ErrInvalidWHT):
+			column = csvColumnWHT
+		case errors.Is(leaf, ErrInvalidAllowanceAmount) && len(allowanceColumns) > 0:
+			column, allowanceColumns = allowanceColumns[0], allowanceColumns[1:]
+		}
+		result = append(result, &rowError{
+			row:    row.number,
+			id:     row.id,
+			column: column,
+			value:  row.raw[column],
+			err:    leaf,
+		})
+	}
+	return result
+}
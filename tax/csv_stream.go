@@ -0,0 +1,97 @@
+package tax
+
+import (
+	"encoding/json"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// csvStreamFlushRows is how many rows are written between two flushes of a
+// streamed response.
+const csvStreamFlushRows = 100
+
+// CsvTaxLine is one line of a streamed csv result: the calculated tax of a row,
+// the reason a row was skipped, or, on the last line, the summary of the file.
+type CsvTaxLine struct {
+	Tax     *CsvTaxRecord  `json:"tax,omitempty"`
+	Error   *CsvRowError   `json:"error,omitempty"`
+	Summary *CsvTaxSummary `json:"summary,omitempty"`
+}
+
+func csvErrorLines(errs []CsvRowError) []CsvTaxLine {
+	lines := make([]CsvTaxLine, len(errs))
+	for i := range errs {
+		lines[i] = CsvTaxLine{Error: &errs[i]}
+	}
+	return lines
+}
+
+// streamCSV writes one NDJSON line per row as soon as the row is calculated,
+// so memory use does not grow with the size of the file. The status is sent
+// before the first row is read; errors found afterwards, including a broken
+// csv, are reported as error lines.
+func (h *Handler) streamCSV(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict bool) error {
+	lang := i18n.FromRequest(c.Request())
+	ctx := c.Request().Context()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	res.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(res)
+
+	summary := newSummaryBuilder()
+	p := calculateRows(ctx, cr, deductionData, h.calculationWorkers, 0)
+	defer p.stop()
+
+	rows := 0
+	for outcome := range p.outcomes {
+		rows++
+
+		var lines []CsvTaxLine
+		stop := false
+		switch {
+		case outcome.err != nil:
+			c.Logger().Printf("error reading csv files: %v", outcome.err)
+			lines = csvErrorLines(localiseCsvRowErrors([]CsvRowError{{Row: outcome.row.number, Reason: ErrReadingCSV.Error()}}, lang))
+			stop = true
+		case outcome.rowErrors() != nil:
+			lines = csvErrorLines(localiseCsvRowErrors(csvRowErrors(outcome.rowErrors()), lang))
+			summary.addFailed()
+			stop = strict
+		default:
+			record := csvTaxRecord(outcome.row, outcome.result)
+			lines = []CsvTaxLine{{Tax: &record}}
+			summary.add(record, levelTaxes(outcome.result.TaxLevels))
+		}
+
+		for _, line := range lines {
+			if err := enc.Encode(line); err != nil {
+				c.Logger().Printf("error streaming csv: %v", err)
+				return nil
+			}
+		}
+		if stop {
+			res.Flush()
+			return nil
+		}
+		if rows%csvStreamFlushRows == 0 {
+			res.Flush()
+		}
+	}
+	if err := p.err(); err != nil {
+		c.Logger().Printf("error streaming csv: %v", err)
+		return nil
+	}
+
+	built := localiseCsvTaxSummary(summary.build(), lang)
+	if err := enc.Encode(CsvTaxLine{Summary: &built}); err != nil {
+		c.Logger().Printf("error streaming csv: %v", err)
+		return nil
+	}
+	res.Flush()
+	return nil
+}
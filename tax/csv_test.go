@@ -4,14 +4,59 @@ package tax
 
 import (
 	"bytes"
+	"encoding/csv"
+	"github.com/golfz/assessment-tax/deduction"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"testing"
 )
 
-func TestParseTaxRecords_Success(t *testing.T) {
-	// Arrange
+// readCSV reads records the way the handlers do, the header and then every
+// row until io.EOF, and returns the parsed rows apart from the rejected ones.
+func readCSV(t *testing.T, records [][]string, opts ...CSVReaderOption) ([]csvRow, []*rowError, error) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if err := w.WriteAll(records); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := NewCSVReader(buf, opts...)
+	if err := cr.readHeader(); err != nil {
+		return nil, nil, err
+	}
+	defer cr.close()
+
+	rows := make([]csvRow, 0)
+	rowErrors := make([]*rowError, 0)
+	for {
+		row, rowErr, err := cr.next()
+		if err == io.EOF {
+			return rows, rowErrors, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if rowErr != nil {
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+		rows = append(rows, row)
+	}
+}
+
+func taxInformationOf(rows []csvRow) []TaxInformation {
+	result := make([]TaxInformation, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row.info)
+	}
+	return result
+}
+
+func TestCSVReader_Rows(t *testing.T) {
 	testCases := []struct {
 		name    string
+		opts    []CSVReaderOption
 		records [][]string
 		want    []TaxInformation
 	}{
@@ -45,164 +90,388 @@ func TestParseTaxRecords_Success(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "blank optional values",
+			records: [][]string{
+				{"totalIncome", "wht", "donation"},
+				{"1000000", "", " "},
+			},
+			want: []TaxInformation{
+				{TotalIncome: 1000000},
+			},
+		},
+		{
+			name: "columns in any order",
+			records: [][]string{
+				{"donation", "totalIncome", "wht"},
+				{"10000", "1000000", "100000"},
+			},
+			want: []TaxInformation{
+				{
+					TotalIncome: 1000000,
+					WHT:         100000,
+					Allowances:  []Allowance{{Type: AllowanceTypeDonation, Amount: 10000}},
+				},
+			},
+		},
+		{
+			name: "only required column",
+			records: [][]string{
+				{"totalIncome"},
+				{"500000"},
+			},
+			want: []TaxInformation{{TotalIncome: 500000}},
+		},
+		{
+			name: "k-receipt column",
+			records: [][]string{
+				{"totalIncome", "wht", "k-receipt", "donation"},
+				{"500000", "0", "200000", "100000"},
+			},
+			want: []TaxInformation{
+				{
+					TotalIncome: 500000,
+					Allowances: []Allowance{
+						{Type: AllowanceTypeKReceipt, Amount: 200000},
+						{Type: AllowanceTypeDonation, Amount: 100000},
+					},
+				},
+			},
+		},
+		{
+			name: "header with surrounding spaces",
+			records: [][]string{
+				{" totalIncome ", " wht"},
+				{"500000", "1000"},
+			},
+			want: []TaxInformation{{TotalIncome: 500000, WHT: 1000}},
+		},
+		{
+			name: "unknown column allowed; expect it ignored",
+			opts: []CSVReaderOption{WithUnknownColumns()},
+			records: [][]string{
+				{"name", "totalIncome", "department"},
+				{"Somchai", "500000", "HR"},
+			},
+			want: []TaxInformation{{TotalIncome: 500000}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			rows, rowErrors, err := readCSV(t, tc.records, tc.opts...)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Empty(t, rowErrors)
+			assert.Equal(t, tc.want, taxInformationOf(rows))
+		})
+	}
+}
+
+func TestCSVReader_Rows_Error(t *testing.T) {
+	testCases := []struct {
+		name    string
+		records [][]string
+	}{
+		{
+			name: "invalid row",
+			records: [][]string{
+				{"totalIncome", "wht", "donation"},
+				{"1000000", "100000"},
+			},
+		},
+		{
+			name: "totalIncome is not number",
+			records: [][]string{
+				{"totalIncome", "wht", "donation"},
+				{"string", "100000", "10000"},
+			},
+		},
+		{
+			name: "wht is not number",
+			records: [][]string{
+				{"totalIncome", "wht", "donation"},
+				{"1000000", "string", "10000"},
+			},
+		},
+		{
+			name: "donation is not number",
+			records: [][]string{
+				{"totalIncome", "wht", "donation"},
+				{"1000000", "100000", "string"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Act
-			cr := NewCSVReader(nil)
-			got, err := cr.parseTaxRecords(tc.records)
+			rows, rowErrors, err := readCSV(t, tc.records)
 
 			// Assert
 			assert.NoError(t, err)
-			assert.Equal(t, tc.want, got)
+			assert.Empty(t, rows)
+			assert.Len(t, rowErrors, 1)
 		})
 	}
 }
 
-func TestParseTaxRecords_Error(t *testing.T) {
+func TestCSVReader_RowErrors(t *testing.T) {
+	// Arrange
+	records := [][]string{
+		{"totalIncome", "wht"},
+		{"500000", "0"},
+		{"500000", "X"},
+		{"500000"},
+	}
 
-	t.Run("invalid header", func(t *testing.T) {
-		// Arrange
-		records := [][]string{
-			{"invalid", "header"},
-			{"1000000", "100000", "10000"},
-		}
+	// Act
+	rows, rowErrors, err := readCSV(t, records)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, 2, rows[0].number)
+	assert.Equal(t, []CsvRowError{
+		{Row: 3, Column: "wht", Value: "X", Reason: ErrParsingData.Error()},
+		{Row: 4, Reason: ErrCSVColumnCount.Error()},
+	}, csvRowErrors(rowErrors))
+}
 
+func TestCSVReader_HeaderError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		header     []string
+		wantErr    error
+		wantColumn string
+	}{
+		{name: "invalid header", header: []string{"invalid", "header"}, wantErr: ErrUnknownCSVColumn, wantColumn: "invalid"},
+		{name: "mis-spelled header", header: []string{"totalIncoming", "wht", "donation"}, wantErr: ErrUnknownCSVColumn, wantColumn: "totalIncoming"},
+		{name: "unknown column rejected", header: []string{"totalIncome", "name"}, wantErr: ErrUnknownCSVColumn, wantColumn: "name"},
+		{name: "duplicate column", header: []string{"totalIncome", "wht", "wht"}, wantErr: ErrDuplicateCSVColumn, wantColumn: "wht"},
+		{name: "empty column name", header: []string{"totalIncome", ""}, wantErr: ErrDuplicateCSVColumn, wantColumn: ""},
+		{name: "missing totalIncome", header: []string{"wht", "donation"}, wantErr: ErrMissingCSVColumn, wantColumn: "totalIncome"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, _, err := readCSV(t, [][]string{tc.header, {"1000000"}})
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.ErrorIs(t, err, ErrInvalidCSVHeader)
+			assert.Contains(t, csvRowErrors(rowErrorsOf(err)), CsvRowError{Row: 1, Column: tc.wantColumn, Reason: tc.wantErr.Error()})
+		})
+	}
+
+	t.Run("several problems; expect each reported with its column", func(t *testing.T) {
 		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+		_, _, err := readCSV(t, [][]string{{"wht", "name", "wht"}})
 
 		// Assert
-		assert.Error(t, err)
+		assert.Equal(t, []CsvRowError{
+			{Row: 1, Column: "name", Reason: ErrUnknownCSVColumn.Error()},
+			{Row: 1, Column: "wht", Reason: ErrDuplicateCSVColumn.Error()},
+			{Row: 1, Column: "totalIncome", Reason: ErrMissingCSVColumn.Error()},
+		}, csvRowErrors(rowErrorsOf(err)))
 	})
+}
 
-	t.Run("mis-spelled header", func(t *testing.T) {
+func TestCSVColumns_CoverRegisteredAllowanceTypes(t *testing.T) {
+	// Act
+	columns := csvColumns()
+
+	// Assert
+	assert.Equal(t, csvColumnTotalIncome, columns[0].name)
+	assert.True(t, columns[0].required)
+	for _, aType := range allowanceTypes {
+		_, ok := findCSVColumn(string(aType))
+		assert.True(t, ok, "missing column for %s", aType)
+	}
+}
+
+func TestCalculateCSVRow(t *testing.T) {
+	deductionData := deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+
+	t.Run("valid row", func(t *testing.T) {
 		// Arrange
-		records := [][]string{
-			{"totalIncoming", "wht", "donation"},
-			{"1000000", "100000", "10000"},
-		}
+		row := csvRow{number: 2, info: TaxInformation{TotalIncome: 500_000.0}, raw: map[string]string{"totalIncome": "500000"}}
 
 		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+		got, rowErrors := calculateCSVRow(row, deductionData)
 
 		// Assert
-		assert.Error(t, err)
+		assert.Nil(t, rowErrors)
+		assert.Equal(t, CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}, csvTaxRecord(row, got))
 	})
 
 	t.Run("invalid row", func(t *testing.T) {
 		// Arrange
-		records := [][]string{
-			{"totalIncome", "wht", "donation"},
-			{"1000000", "100000"},
-		}
+		row := csvRow{number: 3, info: TaxInformation{TotalIncome: -1}, raw: map[string]string{"totalIncome": "-1"}}
 
 		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+		_, rowErrors := calculateCSVRow(row, deductionData)
 
 		// Assert
-		assert.Error(t, err)
+		assert.Equal(t, []CsvRowError{
+			{Row: 3, Column: "totalIncome", Value: "-1", Reason: ErrInvalidTotalIncome.Error()},
+		}, csvRowErrors(rowErrors))
 	})
+}
 
-	t.Run("totalIncome is not number", func(t *testing.T) {
-		// Arrange
-		records := [][]string{
-			{"totalIncome", "wht", "donation"},
-			{"string", "100000", "10000"},
-		}
+func TestCSVReader_Next(t *testing.T) {
+	// Arrange
+	data := "totalIncome,wht\n"
+	data += "500000,0\n"
+	data += "500000,X\n"
+	data += "600000,1000"
+	cr := NewCSVReader(bytes.NewBufferString(data))
 
-		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+	// Act
+	headerErr := cr.readHeader()
+	first, firstErr, err1 := cr.next()
+	_, secondErr, err2 := cr.next()
+	third, thirdErr, err3 := cr.next()
+	_, _, errEOF := cr.next()
 
-		// Assert
-		assert.Error(t, err)
-	})
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.NoError(t, err1)
+	assert.Nil(t, firstErr)
+	assert.Equal(t, csvRow{
+		number: 2,
+		values: []string{"500000", "0"},
+		info:   TaxInformation{TotalIncome: 500_000.0},
+		raw:    map[string]string{"totalIncome": "500000", "wht": "0"},
+	}, first)
+	assert.NoError(t, err2)
+	assert.Equal(t, CsvRowError{Row: 3, Column: "wht", Value: "X", Reason: ErrParsingData.Error()}, secondErr.toCsvRowError())
+	assert.NoError(t, err3)
+	assert.Nil(t, thirdErr)
+	assert.Equal(t, 4, third.number)
+	assert.Equal(t, 1000.0, third.info.WHT)
+	assert.Equal(t, io.EOF, errEOF)
+}
 
-	t.Run("wht is not number", func(t *testing.T) {
-		// Arrange
-		records := [][]string{
-			{"totalIncome", "wht", "donation"},
-			{"1000000", "string", "10000"},
-		}
+func TestCSVReader_Next_BrokenCSV(t *testing.T) {
+	// Arrange
+	cr := NewCSVReader(bytes.NewBufferString("totalIncome\n\"500000"))
 
-		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+	// Act
+	headerErr := cr.readHeader()
+	_, _, err := cr.next()
 
-		// Assert
-		assert.Error(t, err)
-	})
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.ErrorIs(t, err, ErrReadingCSV)
+}
+
+func TestCSVReader_IDColumn(t *testing.T) {
+	// Arrange
+	data := "id,totalIncome,wht\n"
+	data += "E001,500000,0\n"
+	data += "E002,500000,X\n"
+	data += "E001,600000,0\n"
+	data += ",700000,0\n"
+	data += ",800000,0"
+	cr := NewCSVReader(bytes.NewBufferString(data))
+
+	// Act
+	headerErr := cr.readHeader()
+	first, firstErr, _ := cr.next()
+	_, secondErr, _ := cr.next()
+	_, thirdErr, _ := cr.next()
+	_, fourthErr, _ := cr.next()
+	_, fifthErr, _ := cr.next()
 
-	t.Run("donation is not number", func(t *testing.T) {
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.Nil(t, firstErr)
+	assert.Equal(t, "E001", first.id)
+	assert.Equal(t, TaxInformation{TotalIncome: 500_000.0}, first.info)
+	assert.Equal(t, CsvRowError{Row: 3, ID: "E002", Column: "wht", Value: "X", Reason: ErrParsingData.Error()}, secondErr.toCsvRowError())
+	assert.Equal(t, CsvRowError{Row: 4, ID: "E001", Column: "id", Value: "E001", Reason: ErrDuplicateCSVID.Error()}, thirdErr.toCsvRowError())
+	assert.Nil(t, fourthErr)
+	assert.Nil(t, fifthErr)
+}
+
+func TestCSVReader_IDOfRejectedRow(t *testing.T) {
+	// Arrange
+	data := "id,totalIncome,wht\n"
+	data += "E001,500000,X\n"
+	data += "E002,-1,0\n"
+	data += "E001,500000,0\n"
+	data += "E002,600000,0\n"
+	data += "E002,700000,0"
+	cr := NewCSVReader(bytes.NewBufferString(data))
+
+	// Act
+	headerErr := cr.readHeader()
+	_, parseErr, _ := cr.next()
+	invalid, invalidErr, _ := cr.next()
+	_, fixedFirstErr, _ := cr.next()
+	_, fixedSecondErr, _ := cr.next()
+	_, duplicateErr, _ := cr.next()
+
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.NotNil(t, parseErr)
+	assert.Nil(t, invalidErr)
+	assert.Error(t, validateTaxInformation(invalid.info))
+	assert.Nil(t, fixedFirstErr)
+	assert.Nil(t, fixedSecondErr)
+	assert.Equal(t, CsvRowError{Row: 6, ID: "E002", Column: "id", Value: "E002", Reason: ErrDuplicateCSVID.Error()}, duplicateErr.toCsvRowError())
+}
+
+func TestCSVReader_PassThroughColumns(t *testing.T) {
+	t.Run("designated columns are echoed as metadata", func(t *testing.T) {
 		// Arrange
-		records := [][]string{
-			{"totalIncome", "wht", "donation"},
-			{"1000000", "100000", "string"},
-		}
+		data := "id,name,totalIncome,wht\n"
+		data += "E001,Somchai,500000,1000"
+		cr := NewCSVReader(bytes.NewBufferString(data), WithPassThroughColumns("name", "wht"))
 
 		// Act
-		cr := NewCSVReader(nil)
-		_, err := cr.parseTaxRecords(records)
+		headerErr := cr.readHeader()
+		row, rowErr, err := cr.next()
 
 		// Assert
-		assert.Error(t, err)
+		assert.NoError(t, headerErr)
+		assert.NoError(t, err)
+		assert.Nil(t, rowErr)
+		assert.Equal(t, TaxInformation{TotalIncome: 500_000.0, WHT: 1_000.0}, row.info)
+		assert.Equal(t, map[string]string{"name": "Somchai", "wht": "1000"}, row.metadata)
 	})
-}
 
-func TestReadRecords(t *testing.T) {
-	// Arrange
-	t.Run("success", func(t *testing.T) {
-		data := "totalIncome,wht,donation" + "\n"
-		data += "1000000,100000,10000" + "\n"
-		data += "2000000,200000,20000"
-		want := []TaxInformation{
-			{
-				TotalIncome: 1000000,
-				WHT:         100000,
-				Allowances: []Allowance{
-					{
-						Type:   AllowanceTypeDonation,
-						Amount: 10000,
-					},
-				},
-			},
-			{
-				TotalIncome: 2000000,
-				WHT:         200000,
-				Allowances: []Allowance{
-					{
-						Type:   AllowanceTypeDonation,
-						Amount: 20000,
-					},
-				},
-			},
-		}
-		r := bytes.NewReader([]byte(data))
+	t.Run("unknown columns are echoed as metadata", func(t *testing.T) {
+		// Arrange
+		data := "name,totalIncome,department\n"
+		data += "Somchai,500000,HR"
+		cr := NewCSVReader(bytes.NewBufferString(data), WithUnknownColumns())
 
 		// Act
-		cr := NewCSVReader(r)
-		got, err := cr.readRecords()
+		headerErr := cr.readHeader()
+		row, rowErr, err := cr.next()
 
 		// Assert
+		assert.NoError(t, headerErr)
 		assert.NoError(t, err)
-		assert.Equal(t, want, got)
+		assert.Nil(t, rowErr)
+		assert.Equal(t, TaxInformation{TotalIncome: 500_000.0}, row.info)
+		assert.Equal(t, map[string]string{"name": "Somchai", "department": "HR"}, row.metadata)
 	})
 
-	t.Run("error", func(t *testing.T) {
+	t.Run("designated column missing; expect ErrMissingCSVColumn", func(t *testing.T) {
 		// Arrange
-		data := "totalIncome,wht,donation" + "\n"
-		data += "1000000,100000" + "\n"
-		r := bytes.NewReader([]byte(data))
+		cr := NewCSVReader(bytes.NewBufferString("totalIncome\n500000"), WithPassThroughColumns("department"))
 
 		// Act
-		cr := NewCSVReader(r)
-		_, err := cr.readRecords()
+		err := cr.readHeader()
 
 		// Assert
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, ErrMissingCSVColumn)
+		assert.ErrorIs(t, err, ErrInvalidCSVHeader)
 	})
 }
@@ -0,0 +1,93 @@
+package tax
+
+import (
+	"github.com/golfz/assessment-tax/deduction"
+	"io"
+)
+
+// whtWarningRatio is the share of total income above which a withholding tax
+// is unusual enough to be worth a second look.
+const whtWarningRatio = 0.2
+
+// CsvRowWarning points at a value that is valid but likely a mistake. The row
+// is still calculated.
+type CsvRowWarning struct {
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CsvValidationReport is the result of checking a csv without calculating it.
+// Rows counts the data rows, ValidRows the ones that would be calculated.
+type CsvValidationReport struct {
+	Rows        int             `json:"rows"`
+	ValidRows   int             `json:"validRows"`
+	InvalidRows int             `json:"invalidRows"`
+	Errors      []CsvRowError   `json:"errors"`
+	Warnings    []CsvRowWarning `json:"warnings"`
+}
+
+// validateCSV reads the remaining rows of cr and reports every error and
+// warning in them. It returns an error only when the file cannot be read.
+func validateCSV(cr *CSVReader, deductionData deduction.Deduction) (CsvValidationReport, error) {
+	report := CsvValidationReport{Warnings: make([]CsvRowWarning, 0)}
+	rowErrors := make([]*rowError, 0)
+
+	for {
+		row, rowErr, err := cr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CsvValidationReport{}, err
+		}
+		report.Rows++
+
+		if rowErr != nil {
+			report.InvalidRows++
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+		if err := validateTaxInformation(row.info); err != nil {
+			report.InvalidRows++
+			rowErrors = append(rowErrors, validationRowErrors(row, err)...)
+			continue
+		}
+
+		report.ValidRows++
+		report.Warnings = append(report.Warnings, csvRowWarnings(row, deductionData)...)
+	}
+
+	report.Errors = csvRowErrors(rowErrors)
+	return report, nil
+}
+
+// csvRowWarnings checks a valid row for a withholding tax above
+// whtWarningRatio of its income and for allowances above their cap.
+func csvRowWarnings(row csvRow, deductionData deduction.Deduction) []CsvRowWarning {
+	result := make([]CsvRowWarning, 0)
+	warn := func(column string, reason error) {
+		result = append(result, CsvRowWarning{
+			Row:    row.number,
+			ID:     row.id,
+			Column: column,
+			Value:  row.raw[column],
+			Reason: reason.Error(),
+		})
+	}
+
+	if row.info.TotalIncome > 0 && row.info.WHT > row.info.TotalIncome*whtWarningRatio {
+		warn(csvColumnWHT, WarnHighWHT)
+	}
+
+	allowances := collapseAllowance(row.info.Allowances)
+	for _, aType := range allowanceTypes {
+		limit, ok := allowanceCap(aType, deductionData)
+		if ok && allowances[aType] > limit {
+			warn(string(aType), WarnAllowanceAboveCap)
+		}
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateCSV(t *testing.T) {
+	deductionData := deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+
+	t.Run("errors and warnings are reported", func(t *testing.T) {
+		// Arrange
+		data := "id,totalIncome,wht,donation,k-receipt\n"
+		data += "E1,500000,0,0,0\n"
+		data += "E2,ABC,0,0,0\n"
+		data += "E3,600000,150000,200000,0\n"
+		data += "E4,700000,-1,0,0\n"
+		data += "E5,800000,0,0,60000\n"
+		cr := NewCSVReader(bytes.NewBufferString(data))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		got, err := validateCSV(cr, deductionData)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, CsvValidationReport{
+			Rows:        5,
+			ValidRows:   3,
+			InvalidRows: 2,
+			Errors: []CsvRowError{
+				{Row: 3, ID: "E2", Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()},
+				{Row: 5, ID: "E4", Column: "wht", Value: "-1", Reason: ErrInvalidWHT.Error()},
+			},
+			Warnings: []CsvRowWarning{
+				{Row: 4, ID: "E3", Column: "wht", Value: "150000", Reason: WarnHighWHT.Error()},
+				{Row: 4, ID: "E3", Column: "donation", Value: "200000", Reason: WarnAllowanceAboveCap.Error()},
+				{Row: 6, ID: "E5", Column: "k-receipt", Value: "60000", Reason: WarnAllowanceAboveCap.Error()},
+			},
+		}, got)
+	})
+
+	t.Run("wht of exactly 20% of income; expect no warning", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString("totalIncome,wht\n500000,100000\n"))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		got, err := validateCSV(cr, deductionData)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, got.ValidRows)
+		assert.Empty(t, got.Warnings)
+		assert.Empty(t, got.Errors)
+	})
+
+	t.Run("broken csv; expect error", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString("totalIncome\n500000\n\"600000"))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		_, err := validateCSV(cr, deductionData)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrReadingCSV)
+	})
+}
@@ -0,0 +1,72 @@
+package tax
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/exchange"
+	"math"
+	"time"
+)
+
+type RateGetter func(currency string, date time.Time) (exchange.Rate, error)
+
+func roundTHB(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func convertForeignAmount(conversionType ConversionType, foreign ForeignAmount, getRate RateGetter) (CurrencyConversion, error) {
+	date, err := exchange.ParseDate(foreign.Date)
+	if err != nil {
+		return CurrencyConversion{}, errors.Join(err, ErrInvalidTaxInformation)
+	}
+
+	currency := exchange.NormaliseCurrency(foreign.Currency)
+	rate := exchange.Rate{Currency: currency, Date: date, Rate: 1}
+	if !exchange.IsBaseCurrency(currency) {
+		if err := exchange.ValidateCurrency(currency); err != nil {
+			return CurrencyConversion{}, errors.Join(err, ErrInvalidTaxInformation)
+		}
+		if rate, err = getRate(currency, date); err != nil {
+			return CurrencyConversion{}, err
+		}
+	}
+
+	return CurrencyConversion{
+		Type:      conversionType,
+		Currency:  currency,
+		Date:      date.Format(exchange.DateLayout),
+		Amount:    foreign.Amount,
+		Rate:      rate.Rate,
+		RateDate:  rate.Date.Format(exchange.DateLayout),
+		AmountTHB: roundTHB(foreign.Amount * rate.Rate),
+	}, nil
+}
+
+// convertForeignAmounts adds the THB value of every foreign income and WHT
+// entry to TotalIncome and WHT, and returns the conversions it applied.
+func convertForeignAmounts(info TaxInformation, getRate RateGetter) (TaxInformation, []CurrencyConversion, error) {
+	if len(info.ForeignIncomes) == 0 && len(info.ForeignWHTs) == 0 {
+		return info, nil, nil
+	}
+
+	conversions := make([]CurrencyConversion, 0, len(info.ForeignIncomes)+len(info.ForeignWHTs))
+
+	for _, foreign := range info.ForeignIncomes {
+		conversion, err := convertForeignAmount(ConversionTypeIncome, foreign, getRate)
+		if err != nil {
+			return TaxInformation{}, nil, err
+		}
+		info.TotalIncome += conversion.AmountTHB
+		conversions = append(conversions, conversion)
+	}
+
+	for _, foreign := range info.ForeignWHTs {
+		conversion, err := convertForeignAmount(ConversionTypeWHT, foreign, getRate)
+		if err != nil {
+			return TaxInformation{}, nil, err
+		}
+		info.WHT += conversion.AmountTHB
+		conversions = append(conversions, conversion)
+	}
+
+	return info, conversions, nil
+}
@@ -0,0 +1,136 @@
+//go:build unit
+
+package tax
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func stubRateGetter(rates map[string]float64) RateGetter {
+	return func(currency string, date time.Time) (exchange.Rate, error) {
+		rate, ok := rates[currency]
+		if !ok {
+			return exchange.Rate{}, exchange.ErrRateNotFound
+		}
+		return exchange.Rate{Currency: currency, Date: date, Rate: rate}, nil
+	}
+}
+
+func TestConvertForeignAmounts_Success(t *testing.T) {
+	getRate := stubRateGetter(map[string]float64{"USD": 35.5, "JPY": 0.2345})
+
+	testCases := []struct {
+		name            string
+		info            TaxInformation
+		wantTotalIncome float64
+		wantWHT         float64
+		wantConversions []CurrencyConversion
+	}{
+		{
+			name:            "no foreign amount; expect unchanged and no conversion",
+			info:            TaxInformation{TotalIncome: 500_000.0, WHT: 1_000.0},
+			wantTotalIncome: 500_000.0,
+			wantWHT:         1_000.0,
+		},
+		{
+			name: "foreign income added to THB income",
+			info: TaxInformation{
+				TotalIncome:    100_000.0,
+				ForeignIncomes: []ForeignAmount{{Amount: 1_000.0, Currency: "USD", Date: "2024-03-01"}},
+			},
+			wantTotalIncome: 135_500.0,
+			wantConversions: []CurrencyConversion{
+				{Type: ConversionTypeIncome, Currency: "USD", Date: "2024-03-01", Amount: 1_000.0, Rate: 35.5, RateDate: "2024-03-01", AmountTHB: 35_500.0},
+			},
+		},
+		{
+			name: "converted amount rounded to satang",
+			info: TaxInformation{
+				ForeignIncomes: []ForeignAmount{{Amount: 333.0, Currency: "JPY", Date: "2024-03-01"}},
+				ForeignWHTs:    []ForeignAmount{{Amount: 20.0, Currency: "jpy", Date: "2024-03-01"}},
+			},
+			wantTotalIncome: 78.09,
+			wantWHT:         4.69,
+			wantConversions: []CurrencyConversion{
+				{Type: ConversionTypeIncome, Currency: "JPY", Date: "2024-03-01", Amount: 333.0, Rate: 0.2345, RateDate: "2024-03-01", AmountTHB: 78.09},
+				{Type: ConversionTypeWHT, Currency: "JPY", Date: "2024-03-01", Amount: 20.0, Rate: 0.2345, RateDate: "2024-03-01", AmountTHB: 4.69},
+			},
+		},
+		{
+			name: "THB entry does not look up a rate",
+			info: TaxInformation{
+				ForeignIncomes: []ForeignAmount{{Amount: 1_000.0, Currency: "THB", Date: "2024-03-01"}},
+			},
+			wantTotalIncome: 1_000.0,
+			wantConversions: []CurrencyConversion{
+				{Type: ConversionTypeIncome, Currency: "THB", Date: "2024-03-01", Amount: 1_000.0, Rate: 1, RateDate: "2024-03-01", AmountTHB: 1_000.0},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, conversions, err := convertForeignAmounts(tc.info, getRate)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTotalIncome, got.TotalIncome)
+			assert.Equal(t, tc.wantWHT, got.WHT)
+			assert.Equal(t, tc.wantConversions, conversions)
+		})
+	}
+}
+
+func TestConvertForeignAmounts_Error(t *testing.T) {
+	testCases := []struct {
+		name    string
+		info    TaxInformation
+		getRate RateGetter
+		wantErr error
+	}{
+		{
+			name:    "rate not found",
+			info:    TaxInformation{ForeignIncomes: []ForeignAmount{{Amount: 1.0, Currency: "EUR", Date: "2024-03-01"}}},
+			getRate: stubRateGetter(nil),
+			wantErr: exchange.ErrRateNotFound,
+		},
+		{
+			name: "rate getter error on WHT",
+			info: TaxInformation{ForeignWHTs: []ForeignAmount{{Amount: 1.0, Currency: "EUR", Date: "2024-03-01"}}},
+			getRate: func(string, time.Time) (exchange.Rate, error) {
+				return exchange.Rate{}, errors.New("db down")
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "invalid date",
+			info:    TaxInformation{ForeignIncomes: []ForeignAmount{{Amount: 1.0, Currency: "USD", Date: "2024-13-01"}}},
+			getRate: stubRateGetter(nil),
+			wantErr: ErrInvalidTaxInformation,
+		},
+		{
+			name:    "invalid currency",
+			info:    TaxInformation{ForeignIncomes: []ForeignAmount{{Amount: 1.0, Currency: "US1", Date: "2024-03-01"}}},
+			getRate: stubRateGetter(nil),
+			wantErr: ErrInvalidTaxInformation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, _, err := convertForeignAmounts(tc.info, tc.getRate)
+
+			// Assert
+			assert.Error(t, err)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+		})
+	}
+}
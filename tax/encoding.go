@@ -0,0 +1,139 @@
+package tax
+
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/text/encoding/charmap"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVEncoding is the character encoding of an uploaded csv.
+type CSVEncoding string
+
+const (
+	// CSVEncodingAuto reads UTF-8 when the file starts with a byte order mark
+	// or its beginning is valid UTF-8, and TIS-620 otherwise.
+	CSVEncodingAuto CSVEncoding = ""
+	CSVEncodingUTF8 CSVEncoding = "utf-8"
+	// CSVEncodingTIS620 also covers Windows-874, the code page Thai Excel
+	// saves csv files in.
+	CSVEncodingTIS620 CSVEncoding = "tis-620"
+)
+
+// encodingSniffBytes is how much of a file is looked at to detect its
+// encoding. Thai text that appears only after it needs an explicit encoding.
+const encodingSniffBytes = 64 * 1024
+
+var csvEncodingNames = map[string]CSVEncoding{
+	"":            CSVEncodingAuto,
+	"auto":        CSVEncodingAuto,
+	"utf-8":       CSVEncodingUTF8,
+	"utf8":        CSVEncodingUTF8,
+	"tis-620":     CSVEncodingTIS620,
+	"tis620":      CSVEncodingTIS620,
+	"windows-874": CSVEncodingTIS620,
+	"cp874":       CSVEncodingTIS620,
+}
+
+func parseCSVEncoding(name string) (CSVEncoding, error) {
+	enc, ok := csvEncodingNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return CSVEncodingAuto, ErrUnsupportedCSVEncoding
+	}
+	return enc, nil
+}
+
+var csvDelimiterNames = map[string]rune{
+	"":          ',',
+	"comma":     ',',
+	",":         ',',
+	"semicolon": ';',
+	";":         ';',
+	"tab":       '\t',
+	"\t":        '\t',
+}
+
+func parseCSVDelimiter(name string) (rune, error) {
+	if name != "\t" {
+		name = strings.ToLower(strings.TrimSpace(name))
+	}
+	delimiter, ok := csvDelimiterNames[name]
+	if !ok {
+		return 0, ErrUnsupportedCSVDelimiter
+	}
+	return delimiter, nil
+}
+
+// validUTF8Prefix reports whether b is valid UTF-8, ignoring a rune cut off
+// at the end of b.
+func validUTF8Prefix(b []byte) bool {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// decodeCSV returns r as UTF-8 without a byte order mark.
+func decodeCSV(r io.Reader, enc CSVEncoding) io.Reader {
+	br := bufio.NewReaderSize(r, encodingSniffBytes)
+	if bom, _ := br.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+		if enc != CSVEncodingTIS620 {
+			return br
+		}
+	}
+
+	switch enc {
+	case CSVEncodingUTF8:
+		return br
+	case CSVEncodingTIS620:
+		return charmap.Windows874.NewDecoder().Reader(br)
+	}
+
+	prefix, _ := br.Peek(encodingSniffBytes)
+	if validUTF8Prefix(prefix) {
+		return br
+	}
+	return charmap.Windows874.NewDecoder().Reader(br)
+}
+
+var groupedNumber = regexp.MustCompile(`^[+-]?\d{1,3}(,\d{3})+(\.\d*)?$`)
+
+// parseAmount parses a number the way spreadsheets format it, with thousands
+// separators, such as 1,250,000.00, and with parentheses for negatives.
+func parseAmount(value string) (float64, error) {
+	s := strings.TrimSpace(value)
+
+	negative := false
+	if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if strings.Contains(s, ",") {
+		if !groupedNumber.MatchString(s) {
+			return 0, ErrParsingData
+		}
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	result, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, ErrParsingData
+	}
+	if negative {
+		if result < 0 {
+			return 0, ErrParsingData
+		}
+		result = -result
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"io"
+	"strings"
+	"testing"
+)
+
+func mustTIS620(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := charmap.Windows874.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("cannot encode %q as tis-620: %v", s, err)
+	}
+	return b
+}
+
+func TestParseAmount(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "1250000", want: 1_250_000.0},
+		{value: " 1250000.50 ", want: 1_250_000.5},
+		{value: "1,250,000.00", want: 1_250_000.0},
+		{value: "250,000", want: 250_000.0},
+		{value: "-1,000", want: -1_000.0},
+		{value: "(1,250.00)", want: -1_250.0},
+		{value: "( 500 )", want: -500.0},
+		{value: "1,2", wantErr: true},
+		{value: "1,0000", wantErr: true},
+		{value: "1.000,00", wantErr: true},
+		{value: "(-500)", wantErr: true},
+		{value: "()", wantErr: true},
+		{value: "ABC", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			// Act
+			got, err := parseAmount(tc.value)
+
+			// Assert
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrParsingData)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseCSVEncodingAndDelimiter(t *testing.T) {
+	// Act
+	auto, autoErr := parseCSVEncoding("")
+	tis620, tis620Err := parseCSVEncoding(" Windows-874 ")
+	_, unknownEncodingErr := parseCSVEncoding("ebcdic")
+	comma, commaErr := parseCSVDelimiter("")
+	semicolon, semicolonErr := parseCSVDelimiter("Semicolon")
+	tab, tabErr := parseCSVDelimiter("\t")
+	_, unknownDelimiterErr := parseCSVDelimiter("|")
+
+	// Assert
+	assert.NoError(t, autoErr)
+	assert.Equal(t, CSVEncodingAuto, auto)
+	assert.NoError(t, tis620Err)
+	assert.Equal(t, CSVEncodingTIS620, tis620)
+	assert.ErrorIs(t, unknownEncodingErr, ErrUnsupportedCSVEncoding)
+	assert.NoError(t, commaErr)
+	assert.Equal(t, ',', comma)
+	assert.NoError(t, semicolonErr)
+	assert.Equal(t, ';', semicolon)
+	assert.NoError(t, tabErr)
+	assert.Equal(t, '\t', tab)
+	assert.ErrorIs(t, unknownDelimiterErr, ErrUnsupportedCSVDelimiter)
+}
+
+func TestDecodeCSV(t *testing.T) {
+	const text = "ชื่อ,totalIncome\nสมชาย,500000\n"
+
+	testCases := []struct {
+		name    string
+		content []byte
+		enc     CSVEncoding
+	}{
+		{name: "utf-8 detected", content: []byte(text)},
+		{name: "utf-8 with bom", content: append(append([]byte{}, utf8BOM...), text...)},
+		{name: "tis-620 detected", content: mustTIS620(t, text)},
+		{name: "tis-620 given", content: mustTIS620(t, text), enc: CSVEncodingTIS620},
+		{name: "utf-8 given with bom", content: append(append([]byte{}, utf8BOM...), text...), enc: CSVEncodingUTF8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := io.ReadAll(decodeCSV(bytes.NewReader(tc.content), tc.enc))
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, text, string(got))
+		})
+	}
+}
+
+func TestValidUTF8Prefix(t *testing.T) {
+	// Arrange
+	thai := []byte(strings.Repeat("ก", 3))
+
+	// Act & Assert
+	assert.True(t, validUTF8Prefix(thai[:len(thai)-1]))
+	assert.False(t, validUTF8Prefix(mustTIS620(t, "กขค")))
+}
+
+func TestCSVReader_ThaiLocale(t *testing.T) {
+	// Arrange
+	data := "\xEF\xBB\xBFtotalIncome;wht;donation\n"
+	data += "\"1,250,000.00\";(1,000);50,000\n"
+	cr := NewCSVReader(strings.NewReader(data), WithDelimiter(';'))
+
+	// Act
+	headerErr := cr.readHeader()
+	row, rowErr, err := cr.next()
+
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.NoError(t, err)
+	assert.Nil(t, rowErr)
+	assert.Equal(t, TaxInformation{
+		TotalIncome: 1_250_000.0,
+		WHT:         -1_000.0,
+		Allowances:  []Allowance{{Type: AllowanceTypeDonation, Amount: 50_000.0}},
+	}, row.info)
+}
@@ -14,15 +14,81 @@ var (
 	ErrInvalidTotalIncome     = errors.New("total income must be greater than or equal to 0")
 	ErrInvalidWHT             = errors.New("WHT must be greater than or equal to 0 and less than total income")
 	ErrInvalidAllowanceAmount = errors.New("allowance amount must be greater than or equal to 0")
+	ErrInvalidSeverance       = errors.New("severance amount, years of service and last salary must be greater than or equal to 0")
+	ErrInvalidInterimTax      = errors.New("interim tax must be greater than or equal to 0")
+	ErrNotApplicableToInterim = errors.New("severance and interim tax are not applicable to interim calculation")
+)
+
+var (
+	ErrInvalidOriginal = errors.New("invalid original tax information")
+	ErrInvalidAmended  = errors.New("invalid amended tax information")
+	ErrAmendedAsOf     = errors.New("amended asOf must be empty or the same as the original's")
 )
 
 var (
 	ErrInvalidDeduction = errors.New("invalid deduction")
 )
 
+var (
+	ErrGettingExchangeRate  = errors.New("error getting exchange rate")
+	ErrExchangeRateNotFound = errors.New("exchange rate not found for currency and date")
+)
+
 var (
 	ErrUploadingFile    = errors.New("cannot uploading file")
 	ErrReadingCSV       = errors.New("cannot reading csv")
 	ErrParsingData      = errors.New("cannot parsing data")
 	ErrInvalidCSVHeader = errors.New("invalid csv header")
+
+	ErrReadingXLSX       = errors.New("cannot reading xlsx")
+	ErrXLSXSheetNotFound = errors.New("xlsx sheet not found")
+
+	ErrUnknownCSVColumn   = errors.New("unknown csv column")
+	ErrDuplicateCSVColumn = errors.New("duplicate or empty csv column")
+	ErrMissingCSVColumn   = errors.New("missing required csv column")
+	ErrCSVColumnCount     = errors.New("number of values does not match the csv header")
+	ErrDuplicateCSVID     = errors.New("duplicate id in csv file")
+	ErrCSVTooLarge        = errors.New("csv file has too many rows, request application/x-ndjson to stream the result")
+
+	ErrUnsupportedCSVEncoding  = errors.New("unsupported csv encoding, use utf-8 or tis-620")
+	ErrUnsupportedCSVDelimiter = errors.New("unsupported csv delimiter, use comma, semicolon or tab")
+
+	ErrCreatingTemplate = errors.New("error creating csv template")
+)
+
+var (
+	ErrReadingZip          = errors.New("cannot reading zip")
+	ErrZipTooManyEntries   = errors.New("zip file has too many entries")
+	ErrZipTooLarge         = errors.New("zip file is too large when uncompressed")
+	ErrInvalidZipEntry     = errors.New("zip file has an entry outside the archive")
+	ErrUnsupportedZipEntry = errors.New("only csv and xlsx files can be read from a zip file")
+)
+
+var (
+	ErrCalculationBatchTooLarge = errors.New("too many items in calculation batch")
+	ErrMissingCalculationID     = errors.New("item id is required")
+	ErrDuplicateCalculationID   = errors.New("duplicate item id in calculation batch")
+)
+
+// Warnings are reported by the csv validation without rejecting the row.
+var (
+	WarnHighWHT           = errors.New("wht is more than 20% of total income")
+	WarnAllowanceAboveCap = errors.New("allowance is above its deduction cap, only the cap is deducted")
+)
+
+var (
+	ErrCreatingBatch    = errors.New("error creating batch")
+	ErrGettingBatch     = errors.New("error getting batch")
+	ErrBatchNotFound    = errors.New("batch not found")
+	ErrBatchNotFinished = errors.New("batch is not finished yet")
+	ErrInvalidCSVRow    = errors.New("csv file has an invalid row")
+	ErrBatchTooLarge    = errors.New("batch file is too large")
+	ErrBatchFinished    = errors.New("batch has already finished")
+	ErrCancellingBatch  = errors.New("error cancelling batch")
+
+	// ErrNoBatchToClaim and ErrBatchLeaseLost are reported by a BatchStorer
+	// to the runner and never shown to a client. A lease is lost when the
+	// batch is claimed by another instance or cancelled.
+	ErrNoBatchToClaim = errors.New("no batch to claim")
+	ErrBatchLeaseLost = errors.New("batch is no longer running on this instance")
 )
@@ -0,0 +1,368 @@
+package tax
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMETextCSV         = "text/csv"
+	MIMEApplicationXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+const (
+	exportColumnTax       = "tax"
+	exportColumnTaxRefund = "taxRefund"
+	exportColumnError     = "error"
+
+	exportFileName  = "taxes"
+	xlsxSheetName   = "taxes"
+	exportErrorsSep = "; "
+)
+
+// utf8BOM lets spreadsheet programs detect that an exported csv is UTF-8, so
+// Thai text is not garbled when the file is opened by double-click.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// resultFormats are the media types a csv upload can answer with. The first
+// one is the default.
+var resultFormats = []string{
+	echo.MIMEApplicationJSON,
+	MIMEApplicationNDJSON,
+	MIMETextCSV,
+	MIMEApplicationXLSX,
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+func parseMediaRange(part string) (mediaRange, bool) {
+	fields := strings.Split(part, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+	if mediaType == "" {
+		return mediaRange{}, false
+	}
+
+	quality := 1.0
+	for _, param := range fields[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return mediaRange{}, false
+		}
+		quality = q
+	}
+
+	return mediaRange{mediaType: mediaType, quality: quality}, true
+}
+
+// negotiateResultFormat picks the result format with the highest quality value
+// from an Accept header, falling back to json for wildcards and unsupported
+// types.
+func negotiateResultFormat(accept string) string {
+	ranges := make([]mediaRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		r, ok := parseMediaRange(part)
+		if !ok || r.quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		for _, format := range resultFormats {
+			if r.mediaType == format {
+				return format
+			}
+		}
+	}
+	return resultFormats[0]
+}
+
+// exportHeader is the header of an exported result: the uploaded columns in
+// file order, then the tax, the refund, one column per tax level in bracket
+// order and the reason a row was skipped.
+func exportHeader(inputColumns []string, lang i18n.Language) []string {
+	header := make([]string, 0, len(inputColumns)+len(rates)+3)
+	header = append(header, inputColumns...)
+	header = append(header, exportColumnTax, exportColumnTaxRefund)
+	for _, r := range rates {
+		header = append(header, messages.Translate(lang, r.description))
+	}
+	return append(header, exportColumnError)
+}
+
+// exportRow lines a row up with exportHeader. Values are strings for the
+// uploaded columns and the error, float64 for amounts and nil for blanks.
+func exportRow(row csvRow, width int, result *TaxResult, errs []CsvRowError) []any {
+	values := make([]any, 0, width+len(rates)+3)
+	for i := 0; i < width; i++ {
+		if i < len(row.values) {
+			values = append(values, row.values[i])
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	reasons := make([]string, len(errs))
+	for i, e := range errs {
+		reasons[i] = e.Reason
+	}
+	return appendExportResult(values, result, strings.Join(reasons, exportErrorsSep))
+}
+
+// appendExportResult appends the columns exportHeader adds after the input
+// ones: the amounts of result, blank when there is none, and the error.
+func appendExportResult(values []any, result *TaxResult, reason string) []any {
+	if result != nil {
+		values = append(values, result.Tax, result.TaxRefund)
+		for _, level := range result.TaxLevels {
+			values = append(values, level.Tax)
+		}
+	} else {
+		for i := 0; i < len(rates)+2; i++ {
+			values = append(values, nil)
+		}
+	}
+	return append(values, reason)
+}
+
+// neutraliseFormula keeps a spreadsheet from evaluating an uploaded value as a
+// formula by prefixing it with a quote. Plain numbers, negative ones
+// included, are left alone.
+func neutraliseFormula(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return s
+	}
+	return "'" + s
+}
+
+type tableWriter interface {
+	writeRow(values []any) error
+}
+
+type csvTableWriter struct {
+	w *csv.Writer
+}
+
+func (tw csvTableWriter) writeRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			record[i] = neutraliseFormula(v)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return tw.w.Write(record)
+}
+
+type xlsxTableWriter struct {
+	sw  *excelize.StreamWriter
+	row int
+}
+
+// writeRow stores the uploaded values as text, exactly as they were sent, and
+// only the calculated amounts as numeric cells.
+func (tw *xlsxTableWriter) writeRow(values []any) error {
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = v
+		if s, ok := v.(string); ok {
+			cells[i] = neutraliseFormula(s)
+		}
+	}
+
+	tw.row++
+	cell, err := excelize.CoordinatesToCellName(1, tw.row)
+	if err != nil {
+		return err
+	}
+	return tw.sw.SetRow(cell, cells)
+}
+
+// writeHeader writes the header of an export.
+func writeHeader(tw tableWriter, header []string) error {
+	values := make([]any, len(header))
+	for i, name := range header {
+		values[i] = name
+	}
+	return tw.writeRow(values)
+}
+
+// exportRows calculates every remaining row of cr into tw. In strict mode it
+// stops after writing the first skipped row and returns its error, joined
+// with ErrReadingCSV when the row could not be parsed or ErrInvalidCSVRow
+// when it has an invalid value, the way collectCSV does. More than rowLimit
+// rows, unless it is negative, fail with ErrCSVTooLarge.
+func exportRows(ctx context.Context, cr *CSVReader, deductionData deduction.Deduction, workers int, strict bool, rowLimit int, lang i18n.Language, tw tableWriter) error {
+	width := len(cr.columns)
+	if err := writeHeader(tw, exportHeader(cr.columnNames(), lang)); err != nil {
+		return err
+	}
+
+	p := calculateRows(ctx, cr, deductionData, workers, 0)
+	defer p.stop()
+
+	rows := 0
+	for outcome := range p.outcomes {
+		if outcome.err != nil {
+			return outcome.err
+		}
+		if rowLimit >= 0 && rows >= rowLimit {
+			return ErrCSVTooLarge
+		}
+		rows++
+
+		var values []any
+		var stopErr error
+		switch {
+		case outcome.rowErr != nil:
+			values = exportRow(outcome.row, width, nil, localiseCsvRowErrors(csvRowErrors(outcome.rowErrors()), lang))
+			stopErr = errors.Join(outcome.rowErr, ErrReadingCSV)
+		case outcome.errs != nil:
+			values = exportRow(outcome.row, width, nil, localiseCsvRowErrors(csvRowErrors(outcome.errs), lang))
+			stopErr = errors.Join(append(rowErrorsAsErrors(outcome.errs), ErrInvalidCSVRow)...)
+		default:
+			values = exportRow(outcome.row, width, &outcome.result, nil)
+		}
+
+		if err := tw.writeRow(values); err != nil {
+			return err
+		}
+		if strict && stopErr != nil {
+			return stopErr
+		}
+	}
+	return p.err()
+}
+
+func attachment(name string) string {
+	return `attachment; filename="` + name + `"`
+}
+
+// exportCSV streams the result as a UTF-8 csv. In strict mode the result is
+// buffered instead, within the same limit as a json result, so that an
+// invalid row is answered with an error rather than a file cut short.
+func (h *Handler) exportCSV(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict bool) error {
+	write := func(rowLimit int) func(tw tableWriter) error {
+		return func(tw tableWriter) error {
+			return exportRows(c.Request().Context(), cr, deductionData, h.calculationWorkers, strict, rowLimit, i18n.FromRequest(c.Request()), tw)
+		}
+	}
+	if strict {
+		return h.sendBufferedCSV(c, write(h.csvRowLimit()))
+	}
+	return h.sendCSV(c, write(-1))
+}
+
+// exportXLSX sends the result as a spreadsheet.
+func (h *Handler) exportXLSX(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict bool) error {
+	return h.sendXLSX(c, func(tw tableWriter) error {
+		return exportRows(c.Request().Context(), cr, deductionData, h.calculationWorkers, strict, -1, i18n.FromRequest(c.Request()), tw)
+	})
+}
+
+// sendCSV streams the rows written by write as a UTF-8 csv. The status is
+// sent before the first row is written, so an error found later only ends the
+// file early.
+func (h *Handler) sendCSV(c echo.Context, write func(tw tableWriter) error) error {
+	res := startCSV(c)
+	if _, err := res.Write(utf8BOM); err != nil {
+		c.Logger().Printf("error exporting csv: %v", err)
+		return nil
+	}
+
+	w := csv.NewWriter(res)
+	if err := write(csvTableWriter{w: w}); err != nil {
+		c.Logger().Printf("error exporting csv: %v", err)
+	}
+	w.Flush()
+	return nil
+}
+
+// sendBufferedCSV writes the rows written by write to memory and sends them
+// only once all are written, so that an error can still be answered as json.
+func (h *Handler) sendBufferedCSV(c echo.Context, write func(tw tableWriter) error) error {
+	buf := new(bytes.Buffer)
+	buf.Write(utf8BOM)
+	w := csv.NewWriter(buf)
+	if err := write(csvTableWriter{w: w}); err != nil {
+		return h.handleCollectCSVError(c, err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "exporting csv", ErrCalculatingTax.Error())
+	}
+
+	if _, err := startCSV(c).Write(buf.Bytes()); err != nil {
+		c.Logger().Printf("error exporting csv: %v", err)
+	}
+	return nil
+}
+
+// startCSV sends the status and headers of a csv export.
+func startCSV(c echo.Context) *echo.Response {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMETextCSV+"; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, attachment(exportFileName+".csv"))
+	res.WriteHeader(http.StatusOK)
+	return res
+}
+
+// sendXLSX builds a spreadsheet of the rows written by write before sending
+// it. The stream writer spills large sheets to a temporary file, so memory
+// does not grow with the number of rows, and errors can still be answered as
+// json.
+func (h *Handler) sendXLSX(c echo.Context, write func(tw tableWriter) error) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), xlsxSheetName); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "exporting xlsx", ErrCalculatingTax.Error())
+	}
+	sw, err := f.NewStreamWriter(xlsxSheetName)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "exporting xlsx", ErrCalculatingTax.Error())
+	}
+
+	if err := write(&xlsxTableWriter{sw: sw}); err != nil {
+		return h.handleCollectCSVError(c, err)
+	}
+	if err := sw.Flush(); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "exporting xlsx", ErrCalculatingTax.Error())
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationXLSX)
+	res.Header().Set(echo.HeaderContentDisposition, attachment(exportFileName+".xlsx"))
+	res.WriteHeader(http.StatusOK)
+	if err := f.Write(res); err != nil {
+		c.Logger().Printf("error exporting xlsx: %v", err)
+	}
+	return nil
+}
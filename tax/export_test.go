@@ -0,0 +1,145 @@
+//go:build unit
+
+package tax
+
+import (
+	"encoding/csv"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateResultFormat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "no header", accept: "", want: echo.MIMEApplicationJSON},
+		{name: "wildcard", accept: "*/*", want: echo.MIMEApplicationJSON},
+		{name: "csv", accept: "text/csv", want: MIMETextCSV},
+		{name: "xlsx", accept: MIMEApplicationXLSX, want: MIMEApplicationXLSX},
+		{name: "ndjson", accept: MIMEApplicationNDJSON, want: MIMEApplicationNDJSON},
+		{name: "highest quality wins", accept: "text/csv;q=0.5, " + MIMEApplicationXLSX + ";q=0.9", want: MIMEApplicationXLSX},
+		{name: "unsupported type skipped", accept: "text/html, text/csv;q=0.1", want: MIMETextCSV},
+		{name: "zero quality ignored", accept: "text/csv;q=0", want: echo.MIMEApplicationJSON},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := negotiateResultFormat(tc.accept)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestExportHeader(t *testing.T) {
+	// Act
+	got := exportHeader([]string{"employee", "totalIncome"}, i18n.LanguageEnglish)
+
+	// Assert
+	assert.Equal(t, []string{
+		"employee", "totalIncome", "tax", "taxRefund",
+		"0-150,000", "150,001-500,000", "500,001-1,000,000", "1,000,001-2,000,000", "2,000,001 and above",
+		"error",
+	}, got)
+}
+
+func TestNeutraliseFormula(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "สมชาย", want: "สมชาย"},
+		{value: "00123", want: "00123"},
+		{value: "-500", want: "-500"},
+		{value: "+1.5", want: "+1.5"},
+		{value: "=HYPERLINK(\"http://example.com\")", want: "'=HYPERLINK(\"http://example.com\")"},
+		{value: "+1+1", want: "'+1+1"},
+		{value: "-2+3", want: "'-2+3"},
+		{value: "@SUM(A1:A2)", want: "'@SUM(A1:A2)"},
+		{value: "-Inf", want: "'-Inf"},
+		{value: "\t=1", want: "'\t=1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			// Act
+			got := neutraliseFormula(tc.value)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCsvTableWriter_WriteRow(t *testing.T) {
+	// Arrange
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+
+	// Act
+	err := csvTableWriter{w: w}.writeRow([]any{"=1+1", "00123", 29_000.0, nil})
+	w.Flush()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "'=1+1,00123,29000,\n", buf.String())
+}
+
+func TestXLSXTableWriter_WriteRow(t *testing.T) {
+	// Arrange
+	f := excelize.NewFile()
+	defer f.Close()
+	sw, err := f.NewStreamWriter(f.GetSheetName(0))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating a stream writer", err)
+	}
+
+	// Act
+	err = (&xlsxTableWriter{sw: sw}).writeRow([]any{"00123", "NaN", "@cmd", 29_000.0})
+	flushErr := sw.Flush()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, flushErr)
+	rows, _ := f.GetRows(f.GetSheetName(0))
+	assert.Equal(t, [][]string{{"00123", "NaN", "'@cmd", "29000"}}, rows)
+	textType, _ := f.GetCellType(f.GetSheetName(0), "A1")
+	nanType, _ := f.GetCellType(f.GetSheetName(0), "B1")
+	numberType, _ := f.GetCellType(f.GetSheetName(0), "D1")
+	assert.Equal(t, textType, nanType)
+	assert.NotEqual(t, textType, numberType)
+}
+
+func TestExportRow(t *testing.T) {
+	t.Run("calculated row", func(t *testing.T) {
+		// Arrange
+		row := csvRow{number: 2, values: []string{"500000"}}
+		result := TaxResult{Tax: 29_000.0, TaxLevels: []TaxLevel{{Tax: 0}, {Tax: 29_000.0}, {Tax: 0}, {Tax: 0}, {Tax: 0}}}
+
+		// Act
+		got := exportRow(row, 1, &result, nil)
+
+		// Assert
+		assert.Equal(t, []any{"500000", 29_000.0, 0.0, 0.0, 29_000.0, 0.0, 0.0, 0.0, ""}, got)
+	})
+
+	t.Run("skipped row with missing value", func(t *testing.T) {
+		// Arrange
+		row := csvRow{number: 3, values: []string{"ABC"}}
+
+		// Act
+		got := exportRow(row, 2, nil, []CsvRowError{{Reason: "a"}, {Reason: "b"}})
+
+		// Assert
+		assert.Equal(t, []any{"ABC", nil, nil, nil, nil, nil, nil, nil, nil, "a; b"}, got)
+	})
+}
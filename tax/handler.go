@@ -1,32 +1,230 @@
 package tax
 
 import (
+	"context"
 	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/golfz/assessment-tax/i18n"
 	"github.com/labstack/echo/v4"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Storer interface {
-	GetDeduction() (deduction.Deduction, error)
+	GetDeduction(asOf time.Time) (deduction.Deduction, error)
+	GetExchangeRate(currency string, date time.Time) (exchange.Rate, error)
 }
 
+const (
+	defaultCalculationBatchMaxItems = 1_000
+
+	defaultZipMaxEntries           = 100
+	defaultZipMaxUncompressedBytes = 100 * 1024 * 1024
+)
+
 type Handler struct {
-	store Storer
+	store              Storer
+	batches            *BatchRunner
+	csvMaxBufferedRows int
+	calculationWorkers int
+	batchMaxItems      int
+
+	zipMaxEntries           int
+	zipMaxUncompressedBytes int64
+	batchMaxUploadBytes     int64
+	xlsxMaxBytes            int64
+}
+
+type Option func(*Handler)
+
+// WithCSVMaxBufferedRows limits how many csv rows are kept in memory to build
+// a single json response. Larger files have to be streamed. There is no limit
+// by default; the server sets it from config.
+func WithCSVMaxBufferedRows(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.csvMaxBufferedRows = n
+		}
+	}
+}
+
+// WithCalculationWorkers sets how many rows of an upload are calculated at
+// the same time. By default it is the number of CPUs.
+func WithCalculationWorkers(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.calculationWorkers = n
+		}
+	}
+}
+
+// WithCalculationBatchMaxItems limits how many people can be calculated in
+// one request to /tax/calculations/batch.
+func WithCalculationBatchMaxItems(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.batchMaxItems = n
+		}
+	}
+}
+
+// WithZipMaxEntries limits how many files an uploaded zip may hold.
+func WithZipMaxEntries(n int) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.zipMaxEntries = n
+		}
+	}
+}
+
+// WithZipMaxUncompressedBytes limits the size of all the files of an uploaded
+// zip once uncompressed.
+func WithZipMaxUncompressedBytes(n int64) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.zipMaxUncompressedBytes = n
+		}
+	}
+}
+
+// WithBatchMaxUploadBytes limits the size of a file uploaded to /tax/batches.
+// There is no limit by default.
+func WithBatchMaxUploadBytes(n int64) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.batchMaxUploadBytes = n
+		}
+	}
+}
+
+// WithXLSXMaxUncompressedBytes limits how much an uploaded xlsx workbook may
+// unzip to.
+func WithXLSXMaxUncompressedBytes(n int64) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.xlsxMaxBytes = n
+		}
+	}
+}
+
+// WithBatchRunner serves /tax/batches from r.
+func WithBatchRunner(r *BatchRunner) Option {
+	return func(h *Handler) {
+		h.batches = r
+	}
 }
 
-func New(db Storer) *Handler {
-	return &Handler{store: db}
+func New(db Storer, opts ...Option) *Handler {
+	h := &Handler{
+		store:              db,
+		calculationWorkers: defaultCalculationWorkers(),
+		batchMaxItems:      defaultCalculationBatchMaxItems,
+
+		zipMaxEntries:           defaultZipMaxEntries,
+		zipMaxUncompressedBytes: defaultZipMaxUncompressedBytes,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
+// Err is an error response. Errors lists the rows that made a file fail in
+// strict mode.
 type Err struct {
-	Message string `json:"message"`
+	Message string        `json:"message"`
+	Errors  []CsvRowError `json:"errors,omitempty"`
 }
 
 func (h *Handler) handleError(c echo.Context, errStatus int, err error, action, errMsg string) error {
 	c.Logger().Printf("error %s: %v", action, err)
-	return c.JSON(errStatus, Err{Message: errMsg})
+	return c.JSON(errStatus, Err{Message: messages.Translate(i18n.FromRequest(c.Request()), errMsg)})
+}
+
+// handleRowError answers 400 for a file rejected in strict mode, with the
+// errors of the row that stopped it, if any.
+func (h *Handler) handleRowError(c echo.Context, err error, errMsg string) error {
+	c.Logger().Printf("error reading csv files: %v", err)
+	lang := i18n.FromRequest(c.Request())
+	response := Err{Message: messages.Translate(lang, errMsg)}
+	if rowErrs := rowErrorsOf(err); len(rowErrs) > 0 {
+		response.Errors = localiseCsvRowErrors(csvRowErrors(rowErrs), lang)
+	}
+	return c.JSON(http.StatusBadRequest, response)
+}
+
+func (h *Handler) handleConversionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidTaxInformation):
+		return h.handleError(c, http.StatusBadRequest, err, "converting currency", ErrInvalidTaxInformation.Error())
+	case errors.Is(err, exchange.ErrRateNotFound):
+		return h.handleError(c, http.StatusBadRequest, err, "converting currency", ErrExchangeRateNotFound.Error())
+	default:
+		return h.handleError(c, http.StatusInternalServerError, err, "converting currency", ErrGettingExchangeRate.Error())
+	}
+}
+
+type CalculatorFunc func(TaxInformation, deduction.Deduction) (TaxResult, error)
+
+// deductionTime is when the deductions of a calculation are resolved: the
+// asOf of the request, a date meaning the start of that day, or now.
+func deductionTime(asOf string) (time.Time, error) {
+	if asOf == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, asOf); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, asOf)
+	if err != nil {
+		return time.Time{}, errors.Join(err, ErrInvalidTaxInformation)
+	}
+	return t, nil
+}
+
+func (h *Handler) processCalculation(c echo.Context, calculate CalculatorFunc) error {
+	var taxInfo TaxInformation
+	err := c.Bind(&taxInfo)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(taxInfo); err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInvalidTaxInformation.Error())
+	}
+
+	asOf, err := deductionTime(taxInfo.AsOf)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInvalidTaxInformation.Error())
+	}
+
+	taxInfo, conversions, err := convertForeignAmounts(taxInfo, h.store.GetExchangeRate)
+	if err != nil {
+		return h.handleConversionError(c, err)
+	}
+
+	deductionData, err := h.store.GetDeduction(asOf)
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+
+	result, err := calculate(taxInfo, deductionData)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTaxInformation) {
+			return h.handleError(c, http.StatusBadRequest, err, "calculating tax", ErrInvalidTaxInformation.Error())
+		}
+		return h.handleError(c, http.StatusInternalServerError, err, "calculating tax", ErrCalculatingTax.Error())
+	}
+	result.Conversions = conversions
+
+	return c.JSON(http.StatusOK, localiseTaxResult(result, i18n.FromRequest(c.Request())))
 }
 
 // CalculateTaxHandler
@@ -35,50 +233,200 @@ func (h *Handler) handleError(c echo.Context, errStatus int, err error, action,
 //	@Description	Calculate tax
 //	@Tags			tax
 //	@Accept			json
-//	@Param			amount	body	TaxInformation	true	"Amount to calculate tax"
+//	@Param			amount			body	TaxInformation	true	"Amount to calculate tax"
+//	@Param			Accept-Language	header	string			false	"Language of messages and tax level labels (en, th)"
 //	@Produce		json
 //	@Success		200	{object}	TaxResult
 //	@Failure		400	{object}	Err
 //	@Failure		500	{object}	Err
 //	@Router			/tax/calculations [post]
 func (h *Handler) CalculateTaxHandler(c echo.Context) error {
-	var taxInfo TaxInformation
-	err := c.Bind(&taxInfo)
-	if err != nil {
+	return h.processCalculation(c, CalculateTax)
+}
+
+// CalculateInterimTaxHandler
+//
+//	@Summary		Calculate half-year (PND.94) interim tax
+//	@Description	Calculate interim tax on January-June income of categories 40(5)-40(8) with half-year allowances
+//	@Tags			tax
+//	@Accept			json
+//	@Param			amount			body	TaxInformation	true	"Half-year income to calculate interim tax"
+//	@Param			Accept-Language	header	string			false	"Language of messages and tax level labels (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	TaxResult
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/calculations/interim [post]
+func (h *Handler) CalculateInterimTaxHandler(c echo.Context) error {
+	return h.processCalculation(c, CalculateInterimTax)
+}
+
+// CalculateAmendmentHandler
+//
+//	@Summary		Compare an amended filing with the original
+//	@Description	Calculate the original and the amended tax, report changed inputs, allowances and tax levels, and the additional payment or refund
+//	@Description	Both are calculated with the deductions in force at the original's asOf. The amended asOf may be left out; any other time than the original's is rejected.
+//	@Tags			tax
+//	@Accept			json
+//	@Param			amendment		body	AmendmentRequest	true	"Original and amended tax information"
+//	@Param			Accept-Language	header	string				false	"Language of messages and tax level labels (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	AmendmentResult
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/calculations/amendments [post]
+func (h *Handler) CalculateAmendmentHandler(c echo.Context) error {
+	var req AmendmentRequest
+	if err := c.Bind(&req); err != nil {
 		return h.handleError(c, http.StatusBadRequest, err, "reading request body", ErrReadingRequestBody.Error())
 	}
 
 	validate := validator.New()
-	if err := validate.Struct(taxInfo); err != nil {
+	if err := validate.Struct(req); err != nil {
 		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInvalidTaxInformation.Error())
 	}
 
-	deductionData, err := h.store.GetDeduction()
+	asOf, err := deductionTime(req.Original.AsOf)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInvalidOriginal.Error())
+	}
+	if req.Amended.AsOf != "" {
+		amendedAsOf, err := deductionTime(req.Amended.AsOf)
+		if err != nil {
+			return h.handleError(c, http.StatusBadRequest, err, "validating request body", ErrInvalidAmended.Error())
+		}
+		if req.Original.AsOf == "" || !amendedAsOf.Equal(asOf) {
+			return h.handleError(c, http.StatusBadRequest, ErrAmendedAsOf, "validating request body", ErrAmendedAsOf.Error())
+		}
+	}
+
+	original, originalConversions, err := convertForeignAmounts(req.Original, h.store.GetExchangeRate)
+	if err != nil {
+		return h.handleConversionError(c, err)
+	}
+	amended, amendedConversions, err := convertForeignAmounts(req.Amended, h.store.GetExchangeRate)
+	if err != nil {
+		return h.handleConversionError(c, err)
+	}
+
+	deductionData, err := h.store.GetDeduction(asOf)
 	if err != nil {
 		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
 	}
 
-	result, err := CalculateTax(taxInfo, deductionData)
+	result, err := CalculateAmendment(original, amended, deductionData)
 	if err != nil {
-		if errors.Is(err, ErrInvalidTaxInformation) {
-			return h.handleError(c, http.StatusBadRequest, err, "calculating tax", ErrInvalidTaxInformation.Error())
+		switch {
+		case errors.Is(err, ErrInvalidTaxInformation) && errors.Is(err, ErrInvalidOriginal):
+			return h.handleError(c, http.StatusBadRequest, err, "calculating tax", ErrInvalidOriginal.Error())
+		case errors.Is(err, ErrInvalidTaxInformation):
+			return h.handleError(c, http.StatusBadRequest, err, "calculating tax", ErrInvalidAmended.Error())
+		default:
+			return h.handleError(c, http.StatusInternalServerError, err, "calculating tax", ErrCalculatingTax.Error())
 		}
-		return h.handleError(c, http.StatusInternalServerError, err, "calculating tax", ErrCalculatingTax.Error())
 	}
+	result.Original.Conversions = originalConversions
+	result.Amended.Conversions = amendedConversions
 
-	return c.JSON(http.StatusOK, result)
+	return c.JSON(http.StatusOK, localiseAmendmentResult(result, i18n.FromRequest(c.Request())))
+}
+
+func formBool(c echo.Context, name string) bool {
+	result, err := strconv.ParseBool(c.FormValue(name))
+	return err == nil && result
+}
+
+// formList splits a comma-separated form value, dropping blank items.
+func formList(c echo.Context, name string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(c.FormValue(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func isXLSXUpload(file *multipart.FileHeader) bool {
+	return strings.EqualFold(filepath.Ext(file.Filename), ".xlsx") ||
+		file.Header.Get(echo.HeaderContentType) == MIMEApplicationXLSX
+}
+
+// uploadOptions reads the form fields that tell how to read an uploaded file.
+func uploadOptions(c echo.Context, file *multipart.FileHeader) (BatchOptions, error) {
+	enc, err := parseCSVEncoding(c.FormValue("encoding"))
+	if err != nil {
+		return BatchOptions{}, err
+	}
+	delimiter, err := parseCSVDelimiter(c.FormValue("delimiter"))
+	if err != nil {
+		return BatchOptions{}, err
+	}
+
+	return BatchOptions{
+		AllowUnknownColumns: formBool(c, "allowUnknownColumns"),
+		PassThroughColumns:  formList(c, "passThroughColumns"),
+		Strict:              formBool(c, "strict"),
+		Encoding:            string(enc),
+		Delimiter:           string(delimiter),
+		XLSX:                isXLSXUpload(file),
+		Sheet:               c.FormValue("sheet"),
+	}, nil
+}
+
+func (h *Handler) handleUploadOptionsError(c echo.Context, err error) error {
+	if errors.Is(err, ErrUnsupportedCSVDelimiter) {
+		return h.handleError(c, http.StatusBadRequest, err, "reading csv files", ErrUnsupportedCSVDelimiter.Error())
+	}
+	return h.handleError(c, http.StatusBadRequest, err, "reading csv files", ErrUnsupportedCSVEncoding.Error())
+}
+
+func (h *Handler) handleReadHeaderError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrXLSXSheetNotFound):
+		return h.handleError(c, http.StatusBadRequest, err, "reading xlsx files", ErrXLSXSheetNotFound.Error())
+	case errors.Is(err, ErrReadingXLSX):
+		return h.handleError(c, http.StatusBadRequest, err, "reading xlsx files", ErrReadingXLSX.Error())
+	case errors.Is(err, ErrInvalidCSVHeader):
+		return h.handleRowError(c, err, ErrInvalidCSVHeader.Error())
+	default:
+		return h.handleError(c, http.StatusBadRequest, err, "reading csv files", ErrReadingCSV.Error())
+	}
 }
 
 // UploadCSVHandler
 //
-//	@Summary		Upload csv file and calculate tax
-//	@Description	Upload csv file and calculate tax
+//	@Summary		Upload csv or xlsx file and calculate tax
+//	@Description	Upload csv or xlsx file and calculate tax. An xlsx file, recognised by its .xlsx extension, is read from the first sheet or the one named in sheet. Invalid rows are skipped and reported in errors unless strict is set, in which case any invalid row fails the upload.
+//	@Description	A csv may be UTF-8, with or without a byte order mark, or TIS-620; the encoding is detected unless given. Amounts may have thousands separators, as in 1,250,000.00, and negatives may be written in parentheses.
+//	@Description	An optional id column, such as an employee number, is echoed on every result and error; a row repeating an earlier id is rejected.
+//	@Description	The json result ends with a summary: totals, the count and tax of each bracket, and the min, max and median tax. With summaryOnly the response is that summary alone.
+//	@Description	With Accept: application/x-ndjson the result is streamed row by row as one CsvTaxLine per line, the last line holding the summary; in strict mode the stream stops after the first error line, without a summary. A json response is limited to the server's buffer size and larger files are rejected with 413.
+//	@Description	With Accept: text/csv or the xlsx media type the result is a file with the uploaded columns followed by tax, taxRefund, one column per tax level and error. A csv is streamed, except in strict mode, where it is sent once complete so that an invalid row fails the upload as it does for json.
+//	@Description	A .zip of csv and xlsx files is answered with a ZipTaxResponse instead: the result of each file by name, with the same options, and the summary of all of them. A file that cannot be read, or has an invalid row in strict mode, fails on its own. The number of files and their uncompressed size are limited by the server.
 //	@Tags			tax
 //	@Accept			multipart/form-data
-//	@Param			taxFile	formData	file	true	"this is a test file"
+//	@Param			taxFile				formData	file	true	"this is a test file"
+//	@Param			allowUnknownColumns	formData	bool	false	"pass unknown columns through as metadata instead of rejecting the file"
+//	@Param			passThroughColumns	formData	string	false	"comma-separated columns to echo as metadata on every result; they must be in the header"
+//	@Param			strict				formData	bool	false	"fail the whole upload on the first invalid row"
+//	@Param			summaryOnly			formData	bool	false	"answer with the CsvTaxSummary of the file only, whatever its size"
+//	@Param			sheet				formData	string	false	"xlsx sheet to read, the first one by default"
+//	@Param			encoding			formData	string	false	"csv encoding: utf-8 or tis-620 (windows-874), detected by default"
+//	@Param			delimiter			formData	string	false	"csv delimiter: comma (default), semicolon or tab"
+//	@Param			Accept				header		string	false	"application/json (default), application/x-ndjson, text/csv or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+//	@Param			Accept-Language		header		string	false	"Language of messages (en, th)"
 //	@Produce		json
+//	@Produce		application/x-ndjson
+//	@Produce		text/csv
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 //	@Success		200	{object}	CsvTaxResponse
+//	@Success		200	{object}	ZipTaxResponse
 //	@Failure		400	{object}	Err
+//	@Failure		413	{object}	Err
 //	@Failure		500	{object}	Err
 //	@Router			/tax/calculations/upload-csv [post]
 func (h *Handler) UploadCSVHandler(c echo.Context) error {
@@ -93,21 +441,208 @@ func (h *Handler) UploadCSVHandler(c echo.Context) error {
 	}
 	defer src.Close()
 
-	cr := NewCSVReader(src)
-	records, err := cr.readRecords()
+	opts, err := uploadOptions(c, file)
 	if err != nil {
-		return h.handleError(c, http.StatusBadRequest, err, "reading csv files", ErrReadingCSV.Error())
+		return h.handleUploadOptionsError(c, err)
+	}
+
+	if isZIPUpload(file) {
+		return h.uploadZIP(c, src, file.Size, opts)
+	}
+
+	cr := NewCSVReader(src, opts.readerOptions(h.xlsxMaxBytes)...)
+	if err := cr.readHeader(); err != nil {
+		return h.handleReadHeaderError(c, err)
 	}
+	defer cr.close()
 
-	deductionData, err := h.store.GetDeduction()
+	deductionData, err := h.store.GetDeduction(time.Now())
 	if err != nil {
 		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
 	}
+	if err := deductionData.Validate(); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, errors.Join(err, ErrInvalidDeduction), "calculating tax", ErrCalculatingTax.Error())
+	}
+
+	strict := opts.Strict
+	if formBool(c, "summaryOnly") {
+		return h.summarizeCSV(c, cr, deductionData, strict)
+	}
+	switch negotiateResultFormat(c.Request().Header.Get(echo.HeaderAccept)) {
+	case MIMEApplicationNDJSON:
+		return h.streamCSV(c, cr, deductionData, strict)
+	case MIMETextCSV:
+		return h.exportCSV(c, cr, deductionData, strict)
+	case MIMEApplicationXLSX:
+		return h.exportXLSX(c, cr, deductionData, strict)
+	default:
+		return h.respondCSV(c, cr, deductionData, strict)
+	}
+}
+
+// respondCSV calculates the whole file into a single CsvTaxResponse, keeping
+// at most csvMaxBufferedRows rows in memory.
+func (h *Handler) respondCSV(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict bool) error {
+	return h.calculateCSV(c, cr, deductionData, strict, false)
+}
+
+// summarizeCSV answers with the summary of the file alone. As no row is kept,
+// the file may be larger than csvMaxBufferedRows.
+func (h *Handler) summarizeCSV(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict bool) error {
+	return h.calculateCSV(c, cr, deductionData, strict, true)
+}
+
+// csvRowLimit is the rowLimit of collectCSV for a json response.
+func (h *Handler) csvRowLimit() int {
+	if h.csvMaxBufferedRows == 0 {
+		return -1
+	}
+	return h.csvMaxBufferedRows
+}
+
+func (h *Handler) calculateCSV(c echo.Context, cr *CSVReader, deductionData deduction.Deduction, strict, summaryOnly bool) error {
+	lang := i18n.FromRequest(c.Request())
+	summary := newSummaryBuilder()
+	rowLimit := h.csvRowLimit()
+	if summaryOnly {
+		rowLimit = -1
+	}
 
-	result, err := CalculateTaxFromCSV(records, deductionData)
+	taxes, rowErrors, err := h.collectCSV(c.Request().Context(), cr, deductionData, strict, !summaryOnly, rowLimit, summary)
 	if err != nil {
-		return h.handleError(c, http.StatusInternalServerError, err, "calculating tax", ErrCalculatingTax.Error())
+		return h.handleCollectCSVError(c, err)
+	}
+
+	built := localiseCsvTaxSummary(summary.build(), lang)
+	if summaryOnly {
+		return c.JSON(http.StatusOK, built)
 	}
 
+	result := CsvTaxResponse{Taxes: taxes, Summary: &built}
+	if len(rowErrors) > 0 {
+		result.Errors = localiseCsvRowErrors(csvRowErrors(rowErrors), lang)
+	}
 	return c.JSON(http.StatusOK, result)
 }
+
+// collectCSV calculates the remaining rows of cr into summary, keeping the
+// records and row errors when keepRows is set. rowLimit, unless negative, is
+// the most rows read before failing with ErrCSVTooLarge. A broken file, or an
+// invalid row in strict mode, fails with its error joined with ErrReadingCSV
+// or ErrCalculatingTax.
+func (h *Handler) collectCSV(ctx context.Context, cr *CSVReader, deductionData deduction.Deduction, strict, keepRows bool, rowLimit int, summary *summaryBuilder) ([]CsvTaxRecord, []*rowError, error) {
+	taxes := make([]CsvTaxRecord, 0)
+	rowErrors := make([]*rowError, 0)
+
+	p := calculateRows(ctx, cr, deductionData, h.calculationWorkers, 0)
+	defer p.stop()
+
+	rows := 0
+	for outcome := range p.outcomes {
+		if outcome.err != nil {
+			return nil, nil, errors.Join(outcome.err, ErrReadingCSV)
+		}
+		if rowLimit >= 0 && rows >= rowLimit {
+			return nil, nil, ErrCSVTooLarge
+		}
+		rows++
+
+		if outcome.rowErr != nil {
+			if strict {
+				return nil, nil, errors.Join(outcome.rowErr, ErrReadingCSV)
+			}
+			summary.addFailed()
+			if keepRows {
+				rowErrors = append(rowErrors, outcome.rowErr)
+			}
+			continue
+		}
+		if outcome.errs != nil {
+			if strict {
+				return nil, nil, errors.Join(append(rowErrorsAsErrors(outcome.errs), ErrInvalidCSVRow)...)
+			}
+			summary.addFailed()
+			if keepRows {
+				rowErrors = append(rowErrors, outcome.errs...)
+			}
+			continue
+		}
+
+		record := csvTaxRecord(outcome.row, outcome.result)
+		summary.add(record, levelTaxes(outcome.result.TaxLevels))
+		if keepRows {
+			taxes = append(taxes, record)
+		}
+	}
+	if err := p.err(); err != nil {
+		return nil, nil, errors.Join(err, ErrCalculatingTax)
+	}
+	return taxes, rowErrors, nil
+}
+
+func (h *Handler) handleCollectCSVError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrCSVTooLarge):
+		return h.handleError(c, http.StatusRequestEntityTooLarge, err, "reading csv files", ErrCSVTooLarge.Error())
+	case errors.Is(err, ErrInvalidCSVRow):
+		return h.handleRowError(c, err, ErrInvalidCSVRow.Error())
+	case errors.Is(err, ErrReadingCSV):
+		return h.handleRowError(c, err, ErrReadingCSV.Error())
+	default:
+		return h.handleError(c, http.StatusInternalServerError, err, "calculating tax", ErrCalculatingTax.Error())
+	}
+}
+
+// ValidateCSVHandler
+//
+//	@Summary		Validate csv or xlsx file without calculating tax
+//	@Description	Check every row of an upload the way upload-csv would, without calculating it. The report counts the valid and invalid rows and lists every error with its row and column.
+//	@Description	Warnings flag valid values that are likely mistakes: a wht above 20% of total income, or a donation or k-receipt above its deduction cap.
+//	@Tags			tax
+//	@Accept			multipart/form-data
+//	@Param			taxFile				formData	file	true	"this is a test file"
+//	@Param			allowUnknownColumns	formData	bool	false	"pass unknown columns through as metadata instead of rejecting the file"
+//	@Param			passThroughColumns	formData	string	false	"comma-separated columns to echo as metadata on every result; they must be in the header"
+//	@Param			sheet				formData	string	false	"xlsx sheet to read, the first one by default"
+//	@Param			encoding			formData	string	false	"csv encoding: utf-8 or tis-620 (windows-874), detected by default"
+//	@Param			delimiter			formData	string	false	"csv delimiter: comma (default), semicolon or tab"
+//	@Param			Accept-Language		header		string	false	"Language of messages (en, th)"
+//	@Produce		json
+//	@Success		200	{object}	CsvValidationReport
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Router			/tax/calculations/upload-csv/validate [post]
+func (h *Handler) ValidateCSVHandler(c echo.Context) error {
+	file, err := c.FormFile("taxFile")
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "uploading file", ErrUploadingFile.Error())
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "opening file", ErrUploadingFile.Error())
+	}
+	defer src.Close()
+
+	opts, err := uploadOptions(c, file)
+	if err != nil {
+		return h.handleUploadOptionsError(c, err)
+	}
+
+	cr := NewCSVReader(src, opts.readerOptions(h.xlsxMaxBytes)...)
+	if err := cr.readHeader(); err != nil {
+		return h.handleReadHeaderError(c, err)
+	}
+	defer cr.close()
+
+	deductionData, err := h.store.GetDeduction(time.Now())
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+
+	report, err := validateCSV(cr, deductionData)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading csv files", ErrReadingCSV.Error())
+	}
+	return c.JSON(http.StatusOK, localiseCsvValidationReport(report, i18n.FromRequest(c.Request())))
+}
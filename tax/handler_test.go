@@ -7,25 +7,35 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/exchange"
+	"github.com/golfz/assessment-tax/i18n"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
-	MethodGetDeduction = "GetDeduction"
+	MethodGetDeduction    = "GetDeduction"
+	MethodGetExchangeRate = "GetExchangeRate"
 )
 
 type mockTaxStorer struct {
-	result       TaxResult
-	deduction    deduction.Deduction
-	err          error
-	methodToCall map[string]bool
+	result          TaxResult
+	deduction       deduction.Deduction
+	deductionAt     map[time.Time]deduction.Deduction
+	whatIsAsOf      time.Time
+	err             error
+	exchangeRate    exchange.Rate
+	exchangeRateErr error
+	methodToCall    map[string]bool
 }
 
 func NewMockTaxStorer() *mockTaxStorer {
@@ -34,11 +44,20 @@ func NewMockTaxStorer() *mockTaxStorer {
 	}
 }
 
-func (m *mockTaxStorer) GetDeduction() (deduction.Deduction, error) {
+func (m *mockTaxStorer) GetDeduction(asOf time.Time) (deduction.Deduction, error) {
 	m.methodToCall[MethodGetDeduction] = true
+	m.whatIsAsOf = asOf
+	if d, ok := m.deductionAt[asOf]; ok {
+		return d, m.err
+	}
 	return m.deduction, m.err
 }
 
+func (m *mockTaxStorer) GetExchangeRate(currency string, date time.Time) (exchange.Rate, error) {
+	m.methodToCall[MethodGetExchangeRate] = true
+	return m.exchangeRate, m.exchangeRateErr
+}
+
 func (m *mockTaxStorer) ExpectToCall(methodName string) {
 	if m.methodToCall == nil {
 		m.methodToCall = make(map[string]bool)
@@ -466,218 +485,1258 @@ func TestCalculateTaxHandler_Error(t *testing.T) {
 	})
 }
 
-func TestUploadCSVHandler_Success(t *testing.T) {
-	// Arrange
-	body := new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-	part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
-	data := "totalIncome,wht,donation" + "\n"
-	data += "500000,0,0" + "\n"
-	data += "600000,40000,20000" + "\n"
-	data += "750000,50000,15000"
-	part.Write([]byte(data))
-	writer.Close()
-
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+func TestCalculateTaxHandler_AcceptLanguage(t *testing.T) {
+	taxInfo := TaxInformation{TotalIncome: 3_160_000.0}
 
-	mock := NewMockTaxStorer()
-	mock.ExpectToCall("UploadCSV")
-	mock.deduction = deduction.Deduction{
-		Personal: 60_000.0,
-		KReceipt: 50_000.0,
-		Donation: 100_000.0,
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		wantLastLevel  string
+	}{
+		{name: "no header; expect original label", acceptLanguage: "", wantLastLevel: "2,000,001 ขึ้นไป"},
+		{name: "english; expect english label", acceptLanguage: "en-US,en;q=0.9", wantLastLevel: "2,000,001 and above"},
+		{name: "thai; expect thai label", acceptLanguage: "th", wantLastLevel: "2,000,001 ขึ้นไป"},
 	}
 
-	// Act
-	h := New(mock)
-	err := h.UploadCSVHandler(c)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", taxInfo)
+			c.Request().Header.Set(i18n.HeaderAcceptLanguage, tc.acceptLanguage)
+			mock.deduction = deduction.Deduction{
+				Personal: 60_000.0,
+				KReceipt: 50_000.0,
+				Donation: 100_000.0,
+			}
 
-	// Assert
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, rec.Code)
+			// Act
+			err := h.CalculateTaxHandler(c)
 
-	var gotCsvTaxResponse CsvTaxResponse
-	if err := json.Unmarshal(rec.Body.Bytes(), &gotCsvTaxResponse); err != nil {
-		t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var got TaxResult
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+			}
+			assert.Equal(t, tc.wantLastLevel, got.TaxLevels[len(got.TaxLevels)-1].Level)
+		})
 	}
-	assert.Equal(t, 3, len(gotCsvTaxResponse.Taxes))
-	assert.Equal(t, 500000.0, gotCsvTaxResponse.Taxes[0].TotalIncome)
-	assert.Equal(t, 29000.0, gotCsvTaxResponse.Taxes[0].Tax)
-}
 
-func TestUploadCSVHandler_Error(t *testing.T) {
-	t.Run("wrong form-field expect 400 with ErrUploadingFile", func(t *testing.T) {
+	t.Run("thai error message", func(t *testing.T) {
 		// Arrange
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, _ := writer.CreateFormFile("wrongField", "taxes.csv")
-		data := "totalIncome,wht,donation" + "\n"
-		data += "500000,0,0"
-		part.Write([]byte(data))
-		writer.Close()
-
-		e := echo.New()
-		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+		resp, c, h, _ := setup(http.MethodPost, "/tax/calculations", TaxInformation{TotalIncome: -1})
+		c.Request().Header.Set(i18n.HeaderAcceptLanguage, "th")
 
 		// Act
-		h := New(NewMockTaxStorer())
-		err := h.UploadCSVHandler(c)
+		err := h.CalculateTaxHandler(c)
 
 		// Assert
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
 		var got Err
-		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
-			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
 		}
-		assert.NotEmpty(t, got.Message)
-		assert.Equal(t, ErrUploadingFile.Error(), got.Message)
+		assert.Equal(t, "ข้อมูลภาษีไม่ถูกต้อง", got.Message)
 	})
+}
 
-	t.Run("no content-type expect 400 with ErrUploadingFile", func(t *testing.T) {
-		// Arrange
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
-		data := "totalIncome,wht,donation" + "\n"
-		data += "500000,0,0"
-		part.Write([]byte(data))
-		writer.Close()
+func TestCalculateTaxHandler_ForeignIncome(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
 
-		e := echo.New()
-		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+	t.Run("foreign income and WHT; expect converted tax and conversions", func(t *testing.T) {
+		// Arrange
+		taxInfo := TaxInformation{
+			ForeignIncomes: []ForeignAmount{{Amount: 10_000.0, Currency: "usd", Date: "2024-01-13"}},
+			ForeignWHTs:    []ForeignAmount{{Amount: 500.0, Currency: "USD", Date: "2024-01-13"}},
+		}
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", taxInfo)
+		mock.deduction = defaultDeduction
+		mock.exchangeRate = exchange.Rate{Currency: "USD", Date: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC), Rate: 50.0}
+		mock.ExpectToCall(MethodGetExchangeRate)
+		mock.ExpectToCall(MethodGetDeduction)
 
 		// Act
-		h := New(NewMockTaxStorer())
-		err := h.UploadCSVHandler(c)
+		err := h.CalculateTaxHandler(c)
 
 		// Assert
+		mock.Verify(t)
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		var got Err
-		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
-			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var got TaxResult
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
 		}
-		assert.NotEmpty(t, got.Message)
-		assert.Equal(t, ErrUploadingFile.Error(), got.Message)
+		assert.Equal(t, 4_000.0, got.Tax)
+		assert.Equal(t, []CurrencyConversion{
+			{Type: ConversionTypeIncome, Currency: "USD", Date: "2024-01-13", Amount: 10_000.0, Rate: 50.0, RateDate: "2024-01-12", AmountTHB: 500_000.0},
+			{Type: ConversionTypeWHT, Currency: "USD", Date: "2024-01-13", Amount: 500.0, Rate: 50.0, RateDate: "2024-01-12", AmountTHB: 25_000.0},
+		}, got.Conversions)
 	})
 
-	t.Run("invalid csv format expect 400 with ErrReadingCSV", func(t *testing.T) {
-		// Arrange
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
-		part.Write([]byte("not csv format"))
-		writer.Close()
+	testCases := []struct {
+		name        string
+		foreign     ForeignAmount
+		rateErr     error
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "rate not found; expect 400",
+			foreign:     ForeignAmount{Amount: 1_000.0, Currency: "JPY", Date: "2024-01-13"},
+			rateErr:     exchange.ErrRateNotFound,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrExchangeRateNotFound.Error(),
+		},
+		{
+			name:        "store error; expect 500",
+			foreign:     ForeignAmount{Amount: 1_000.0, Currency: "JPY", Date: "2024-01-13"},
+			rateErr:     errors.New("connection refused"),
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: ErrGettingExchangeRate.Error(),
+		},
+		{
+			name:        "invalid currency; expect 400",
+			foreign:     ForeignAmount{Amount: 1_000.0, Currency: "DOLLAR", Date: "2024-01-13"},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInvalidTaxInformation.Error(),
+		},
+		{
+			name:        "invalid date; expect 400",
+			foreign:     ForeignAmount{Amount: 1_000.0, Currency: "USD", Date: "13/01/2024"},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: ErrInvalidTaxInformation.Error(),
+		},
+	}
 
-		e := echo.New()
-		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			taxInfo := TaxInformation{ForeignIncomes: []ForeignAmount{tc.foreign}}
+			resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", taxInfo)
+			mock.deduction = defaultDeduction
+			mock.exchangeRateErr = tc.rateErr
+
+			// Act
+			err := h.CalculateTaxHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, resp.Code)
+			var got Err
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+			}
+			assert.Equal(t, tc.wantMessage, got.Message)
+		})
+	}
+}
+
+func TestCalculateTaxHandler_WithSeverance(t *testing.T) {
+	// Arrange
+	taxInfo := TaxInformation{
+		Severance: &Severance{Amount: 3_000_000.0, YearsOfService: 10, LastSalary: 150_000.0},
+	}
+	resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", taxInfo)
+	c.Request().Header.Set(i18n.HeaderAcceptLanguage, "en")
+	mock.deduction = deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	// Act
+	err := h.CalculateTaxHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var got TaxResult
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+	}
+	assert.Equal(t, 143_000.0, got.Tax)
+	assert.Equal(t, &SeveranceResult{
+		SeparateTaxation: true,
+		Exemption:        600_000.0,
+		ServiceDeduction: 70_000.0,
+		TaxableIncome:    1_165_000.0,
+		Tax:              143_000.0,
+		TaxLevels: []TaxLevel{
+			{Level: "0-150,000", Tax: 0.0},
+			{Level: "150,001-500,000", Tax: 35_000.0},
+			{Level: "500,001-1,000,000", Tax: 75_000.0},
+			{Level: "1,000,001-2,000,000", Tax: 33_000.0},
+			{Level: "2,000,001 and above", Tax: 0.0},
+		},
+	}, got.Severance)
+}
 
+func TestDeductionTime(t *testing.T) {
+	t.Run("empty; expect now", func(t *testing.T) {
 		// Act
-		h := New(NewMockTaxStorer())
-		err := h.UploadCSVHandler(c)
+		got, err := deductionTime("")
 
 		// Assert
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		var got Err
-		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
-			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
-		}
-		assert.NotEmpty(t, got.Message)
-		assert.Equal(t, ErrReadingCSV.Error(), got.Message)
+		assert.WithinDuration(t, time.Now(), got, time.Second)
 	})
 
-	t.Run("invalid parsing csv expect 400 with ErrReadingCSV", func(t *testing.T) {
-		// Arrange
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
-		data := "totalIncome,wht,donation" + "\n"
-		data += "ABC,0,0"
-		part.Write([]byte(data))
-		writer.Close()
+	testCases := []struct {
+		asOf string
+		want time.Time
+	}{
+		{asOf: "2025-01-01", want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{asOf: "2025-01-01T12:00:00+07:00", want: time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.asOf, func(t *testing.T) {
+			// Act
+			got, err := deductionTime(tc.asOf)
 
-		e := echo.New()
-		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, tc.want.Equal(got))
+		})
+	}
 
-		mock := NewMockTaxStorer()
-		mock.ExpectToCall("UploadCSV")
-		mock.deduction = deduction.Deduction{
-			Personal: 60_000.0,
-			KReceipt: 50_000.0,
-			Donation: 100_000.0,
+	t.Run("invalid; expect error", func(t *testing.T) {
+		// Act
+		_, err := deductionTime("01/01/2025")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidTaxInformation)
+	})
+}
+
+func TestCalculateTaxHandler_AsOf(t *testing.T) {
+	t.Run("asOf; expect deduction in force at that date", func(t *testing.T) {
+		// Arrange
+		asOf := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", TaxInformation{TotalIncome: 500_000.0, AsOf: "2025-01-01"})
+		mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+		mock.deductionAt = map[time.Time]deduction.Deduction{
+			asOf: {Personal: 100_000.0, KReceipt: 50_000.0, Donation: 100_000.0},
 		}
+		mock.ExpectToCall(MethodGetDeduction)
 
 		// Act
-		h := New(mock)
-		err := h.UploadCSVHandler(c)
+		err := h.CalculateTaxHandler(c)
 
 		// Assert
+		mock.Verify(t)
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-
-		var gotErr Err
-		if err := json.Unmarshal(rec.Body.Bytes(), &gotErr); err != nil {
-			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.True(t, asOf.Equal(mock.whatIsAsOf))
+		var got TaxResult
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
 		}
-		assert.Equal(t, ErrReadingCSV.Error(), gotErr.Message)
+		assert.Equal(t, 25_000.0, got.Tax)
 	})
 
-	t.Run("get deduction error expect 500 with ErrGettingDeduction", func(t *testing.T) {
+	t.Run("no asOf; expect deduction in force now", func(t *testing.T) {
 		// Arrange
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
-		data := "totalIncome,wht,donation" + "\n"
-		data += "500000,0,0" + "\n"
-		data += "600000,40000,20000" + "\n"
-		data += "750000,50000,15000"
-		part.Write([]byte(data))
-		writer.Close()
-
-		e := echo.New()
-		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-		rec := httptest.NewRecorder()
-		c := e.NewContext(req, rec)
-
-		mock := NewMockTaxStorer()
-		mock.ExpectToCall("UploadCSV")
-		mock.err = ErrGettingDeduction
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", TaxInformation{TotalIncome: 500_000.0})
+		mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
 
 		// Act
-		h := New(mock)
-		err := h.UploadCSVHandler(c)
+		err := h.CalculateTaxHandler(c)
 
 		// Assert
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusInternalServerError, rec.Code)
-
-		var gotErr Err
-		if err := json.Unmarshal(rec.Body.Bytes(), &gotErr); err != nil {
-			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
-		}
-		assert.Equal(t, ErrGettingDeduction.Error(), gotErr.Message)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.WithinDuration(t, time.Now(), mock.whatIsAsOf, time.Second)
 	})
 
-	t.Run("zero-deduction expect 500 with ErrCalculatingTax", func(t *testing.T) {
+	t.Run("invalid asOf; expect 400", func(t *testing.T) {
 		// Arrange
-		body := new(bytes.Buffer)
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations", TaxInformation{TotalIncome: 500_000.0, AsOf: "next year"})
+
+		// Act
+		err := h.CalculateTaxHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), ErrInvalidTaxInformation.Error())
+		assert.NotContains(t, mock.methodToCall, MethodGetDeduction)
+	})
+}
+
+func TestCalculateInterimTaxHandler(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	t.Run("half-year income; expect interim tax", func(t *testing.T) {
+		// Arrange
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations/interim", TaxInformation{TotalIncome: 300_000.0})
+		mock.deduction = defaultDeduction
+		mock.ExpectToCall(MethodGetDeduction)
+
+		// Act
+		err := h.CalculateInterimTaxHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var got TaxResult
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+		}
+		assert.Equal(t, 12_000.0, got.Tax)
+	})
+
+	t.Run("severance given; expect 400", func(t *testing.T) {
+		// Arrange
+		taxInfo := TaxInformation{TotalIncome: 300_000.0, Severance: &Severance{Amount: 100_000.0}}
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations/interim", taxInfo)
+		mock.deduction = defaultDeduction
+
+		// Act
+		err := h.CalculateInterimTaxHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		var got Err
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+		}
+		assert.Equal(t, ErrInvalidTaxInformation.Error(), got.Message)
+	})
+}
+
+func TestCalculateAmendmentHandler(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	t.Run("amended filing; expect both results and diff", func(t *testing.T) {
+		// Arrange
+		req := AmendmentRequest{
+			Original: TaxInformation{TotalIncome: 500_000.0},
+			Amended:  TaxInformation{TotalIncome: 3_000_000.0},
+		}
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations/amendments", req)
+		c.Request().Header.Set(i18n.HeaderAcceptLanguage, "en")
+		mock.deduction = defaultDeduction
+		mock.ExpectToCall(MethodGetDeduction)
+
+		// Act
+		err := h.CalculateAmendmentHandler(c)
+
+		// Assert
+		mock.Verify(t)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var got AmendmentResult
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+		}
+		assert.Equal(t, 29_000.0, got.Original.Tax)
+		assert.Equal(t, 639_000.0, got.Amended.Tax)
+		assert.Equal(t, 610_000.0, got.AdditionalPayment)
+		assert.Equal(t, "2,000,001 and above", got.Diff.TaxLevels[len(got.Diff.TaxLevels)-1].Field)
+	})
+
+	t.Run("amended asOf the same as the original; expect deductions at that time", func(t *testing.T) {
+		// Arrange
+		req := AmendmentRequest{
+			Original: TaxInformation{TotalIncome: 500_000.0, AsOf: "2024-01-01"},
+			Amended:  TaxInformation{TotalIncome: 600_000.0, AsOf: "2024-01-01T07:00:00+07:00"},
+		}
+		resp, c, h, mock := setup(http.MethodPost, "/tax/calculations/amendments", req)
+		mock.deduction = defaultDeduction
+
+		// Act
+		err := h.CalculateAmendmentHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.True(t, mock.whatIsAsOf.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	testCases := []struct {
+		name        string
+		req         AmendmentRequest
+		wantMessage string
+	}{
+		{
+			name: "invalid original; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0, WHT: 200_000.0},
+				Amended:  TaxInformation{TotalIncome: 100_000.0},
+			},
+			wantMessage: ErrInvalidOriginal.Error(),
+		},
+		{
+			name: "invalid amended; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0},
+				Amended:  TaxInformation{TotalIncome: 100_000.0, WHT: 200_000.0},
+			},
+			wantMessage: ErrInvalidAmended.Error(),
+		},
+		{
+			name: "missing amended; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0},
+			},
+			wantMessage: ErrInvalidTaxInformation.Error(),
+		},
+		{
+			name: "amended asOf differs from the original; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0, AsOf: "2024-01-01"},
+				Amended:  TaxInformation{TotalIncome: 200_000.0, AsOf: "2025-01-01"},
+			},
+			wantMessage: ErrAmendedAsOf.Error(),
+		},
+		{
+			name: "amended asOf without an original one; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0},
+				Amended:  TaxInformation{TotalIncome: 200_000.0, AsOf: "2025-01-01"},
+			},
+			wantMessage: ErrAmendedAsOf.Error(),
+		},
+		{
+			name: "invalid amended asOf; expect 400",
+			req: AmendmentRequest{
+				Original: TaxInformation{TotalIncome: 100_000.0, AsOf: "2024-01-01"},
+				Amended:  TaxInformation{TotalIncome: 200_000.0, AsOf: "01/01/2024"},
+			},
+			wantMessage: ErrInvalidAmended.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			resp, c, h, mock := setup(http.MethodPost, "/tax/calculations/amendments", tc.req)
+			mock.deduction = defaultDeduction
+
+			// Act
+			err := h.CalculateAmendmentHandler(c)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			var got Err
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Errorf("expected response body to be valid json, got %s", resp.Body.String())
+			}
+			assert.Equal(t, tc.wantMessage, got.Message)
+		})
+	}
+}
+
+// newUploadContext builds a multipart request uploading content as taxFile,
+// with the given form fields and headers.
+func newUploadContext(target, fileName string, content []byte, fields map[string]string, header map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("taxFile", fileName)
+	part.Write(content)
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	return rec, echo.New().NewContext(req, rec)
+}
+
+func TestUploadCSVHandler_Success(t *testing.T) {
+	// Arrange
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
+	data := "totalIncome,wht,donation" + "\n"
+	data += "500000,0,0" + "\n"
+	data += "600000,40000,20000" + "\n"
+	data += "750000,50000,15000"
+	part.Write([]byte(data))
+	writer.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mock := NewMockTaxStorer()
+	mock.ExpectToCall("UploadCSV")
+	mock.deduction = deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	// Act
+	h := New(mock)
+	err := h.UploadCSVHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var gotCsvTaxResponse CsvTaxResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &gotCsvTaxResponse); err != nil {
+		t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+	}
+	assert.Equal(t, 3, len(gotCsvTaxResponse.Taxes))
+	assert.Equal(t, 500000.0, gotCsvTaxResponse.Taxes[0].TotalIncome)
+	assert.Equal(t, 29000.0, gotCsvTaxResponse.Taxes[0].Tax)
+}
+
+func TestUploadCSVHandler_HeaderDriven(t *testing.T) {
+	upload := func(data string, fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/calculations/upload-csv", "taxes.csv", []byte(data), fields, nil)
+	}
+	deductionData := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	t.Run("k-receipt column and unknown column passed through", func(t *testing.T) {
+		// Arrange
+		data := "employee,k-receipt,totalIncome\n"
+		data += "E001,200000,500000"
+		rec, c := upload(data, map[string]string{"allowUnknownColumns": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, []CsvTaxRecord{
+			{Row: 2, TotalIncome: 500_000.0, Tax: 24_000.0, Metadata: map[string]string{"employee": "E001"}},
+		}, got.Taxes)
+	})
+
+	t.Run("thai excel export in tis-620 with semicolons", func(t *testing.T) {
+		// Arrange
+		data := "ชื่อ;totalIncome;wht\n"
+		data += "สมชาย;\"500,000.00\";0"
+		content, _ := charmap.Windows874.NewEncoder().String(data)
+		rec, c := upload(content, map[string]string{"allowUnknownColumns": "true", "delimiter": "semicolon"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, []CsvTaxRecord{
+			{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0, Metadata: map[string]string{"ชื่อ": "สมชาย"}},
+		}, got.Taxes)
+	})
+
+	t.Run("id and pass-through columns echoed; duplicate id rejected", func(t *testing.T) {
+		// Arrange
+		data := "id,department,totalIncome\n"
+		data += "E001,HR,500000\n"
+		data += "E002,IT,500000\n"
+		data += "E001,HR,600000"
+		rec, c := upload(data, map[string]string{"passThroughColumns": "department"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, []CsvTaxRecord{
+			{Row: 2, ID: "E001", TotalIncome: 500_000.0, Tax: 29_000.0, Metadata: map[string]string{"department": "HR"}},
+			{Row: 3, ID: "E002", TotalIncome: 500_000.0, Tax: 29_000.0, Metadata: map[string]string{"department": "IT"}},
+		}, got.Taxes)
+		assert.Equal(t, []CsvRowError{
+			{Row: 4, ID: "E001", Column: "id", Value: "E001", Reason: ErrDuplicateCSVID.Error()},
+		}, got.Errors)
+	})
+
+	t.Run("unsupported delimiter; expect 400", func(t *testing.T) {
+		// Arrange
+		rec, c := upload("totalIncome\n500000", map[string]string{"delimiter": "pipe"})
+
+		// Act
+		err := New(NewMockTaxStorer()).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrUnsupportedCSVDelimiter.Error(), got.Message)
+	})
+
+	t.Run("unknown column without flag; expect 400", func(t *testing.T) {
+		// Arrange
+		data := "employee,totalIncome\n"
+		data += "E001,500000"
+		rec, c := upload(data, nil)
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestUploadCSVHandler_RowErrors(t *testing.T) {
+	upload := func(data string, fields map[string]string, lang string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/calculations/upload-csv", "taxes.csv", []byte(data), fields, map[string]string{i18n.HeaderAcceptLanguage: lang})
+	}
+	deductionData := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	data := "totalIncome,wht,donation\n"
+	data += "500000,0,0\n"
+	data += "ABC,0,0\n"
+	data += "600000,-1,-5\n"
+	data += "700000,0\n"
+	data += "750000,50000,15000"
+
+	t.Run("invalid rows are reported and valid rows calculated", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, nil, "")
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, []CsvTaxRecord{
+			{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0},
+			{Row: 6, TotalIncome: 750_000.0, Tax: 11_250.0},
+		}, got.Taxes)
+		assert.Equal(t, []CsvRowError{
+			{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()},
+			{Row: 4, Column: "wht", Value: "-1", Reason: ErrInvalidWHT.Error()},
+			{Row: 4, Column: "donation", Value: "-5", Reason: ErrInvalidAllowanceAmount.Error()},
+			{Row: 5, Reason: ErrCSVColumnCount.Error()},
+		}, got.Errors)
+	})
+
+	t.Run("reasons are translated", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, nil, "th")
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		var got CsvTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, ErrParsingData.Error()), got.Errors[0].Reason)
+	})
+
+	t.Run("summary only, beyond the buffer limit and translated", func(t *testing.T) {
+		// Arrange
+		data := "totalIncome,wht\n"
+		data += "500000,0\n"
+		data += "ABC,0\n"
+		data += "750000,50000"
+		rec, c := upload(data, map[string]string{"summaryOnly": "true"}, "th")
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock, WithCSVMaxBufferedRows(1)).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvTaxSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, 2, got.Rows)
+		assert.Equal(t, 1, got.FailedRows)
+		assert.Equal(t, 1_250_000.0, got.TotalIncome)
+		assert.Equal(t, 42_500.0, got.TotalTax)
+		assert.Equal(t, 29_000.0, got.MaxTax)
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, "2,000,001 ขึ้นไป"), got.Brackets[4].Level)
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, "150,001-500,000"), got.Brackets[1].Level)
+	})
+
+	t.Run("invalid value in strict mode expect 400 with the row error", func(t *testing.T) {
+		// Arrange
+		rec, c := upload("totalIncome,wht\n500000,-1", map[string]string{"strict": "true"}, "")
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrInvalidCSVRow.Error(), got.Message)
+		assert.Equal(t, []CsvRowError{{Row: 2, Column: "wht", Value: "-1", Reason: ErrInvalidWHT.Error()}}, got.Errors)
+	})
+}
+
+func TestUploadCSVHandler_Stream(t *testing.T) {
+	upload := func(data string, fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/calculations/upload-csv", "taxes.csv", []byte(data), fields, map[string]string{echo.HeaderAccept: MIMEApplicationNDJSON})
+	}
+	readLines := func(t *testing.T, rec *httptest.ResponseRecorder) []CsvTaxLine {
+		lines := make([]CsvTaxLine, 0)
+		dec := json.NewDecoder(rec.Body)
+		for dec.More() {
+			var line CsvTaxLine
+			if err := dec.Decode(&line); err != nil {
+				t.Fatalf("expected response body to be ndjson, got %v", err)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	deductionData := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	data := "totalIncome,wht,donation\n"
+	data += "500000,0,0\n"
+	data += "ABC,0,0\n"
+	data += "750000,50000,15000"
+
+	t.Run("one line per row and a summary line", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, nil)
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEApplicationNDJSON, rec.Header().Get(echo.HeaderContentType))
+		lines := readLines(t, rec)
+		assert.Len(t, lines, 4)
+		assert.Equal(t, []CsvTaxLine{
+			{Tax: &CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}},
+			{Error: &CsvRowError{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}},
+			{Tax: &CsvTaxRecord{Row: 4, TotalIncome: 750_000.0, Tax: 11_250.0}},
+		}, lines[:3])
+		if assert.NotNil(t, lines[3].Summary) {
+			assert.Equal(t, 2, lines[3].Summary.Rows)
+			assert.Equal(t, 1, lines[3].Summary.FailedRows)
+			assert.Equal(t, 40_250.0, lines[3].Summary.TotalTax)
+		}
+	})
+
+	t.Run("strict mode stops after the first error line", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, map[string]string{"strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []CsvTaxLine{
+			{Tax: &CsvTaxRecord{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}},
+			{Error: &CsvRowError{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}},
+		}, readLines(t, rec))
+	})
+
+	t.Run("more rows than the buffer limit are streamed", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, nil)
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock, WithCSVMaxBufferedRows(1)).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, readLines(t, rec), 4)
+	})
+}
+
+func TestUploadCSVHandler_Export(t *testing.T) {
+	upload := func(data, accept string, fields map[string]string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/calculations/upload-csv", "taxes.csv", []byte(data), fields, map[string]string{echo.HeaderAccept: accept})
+	}
+	deductionData := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	data := "name,totalIncome,wht\n"
+	data += "สมชาย,500000,0\n"
+	data += "สมหญิง,ABC,0"
+	fields := map[string]string{"allowUnknownColumns": "true"}
+
+	t.Run("text/csv", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, MIMETextCSV, fields)
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		want := "\uFEFF"
+		want += "name,totalIncome,wht,tax,taxRefund,\"0-150,000\",\"150,001-500,000\",\"500,001-1,000,000\",\"1,000,001-2,000,000\",\"2,000,001 ขึ้นไป\",error\n"
+		want += "สมชาย,500000,0,29000,0,0,29000,0,0,0,\n"
+		want += "สมหญิง,ABC,0,,,,,,,,cannot parsing data\n"
+		assert.Equal(t, want, rec.Body.String())
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, MIMEApplicationXLSX, fields)
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEApplicationXLSX, rec.Header().Get(echo.HeaderContentType))
+		f, err := excelize.OpenReader(rec.Body)
+		if err != nil {
+			t.Fatalf("expected response body to be xlsx, got %v", err)
+		}
+		rows, err := f.GetRows(xlsxSheetName)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"name", "totalIncome", "wht", "tax", "taxRefund", "0-150,000", "150,001-500,000", "500,001-1,000,000", "1,000,001-2,000,000", "2,000,001 ขึ้นไป", "error"}, rows[0])
+		assert.Equal(t, []string{"สมชาย", "500000", "0", "29000", "0", "0", "29000", "0", "0", "0"}, rows[1])
+		assert.Equal(t, []string{"สมหญิง", "ABC", "0", "", "", "", "", "", "", "", "cannot parsing data"}, rows[2])
+		textType, _ := f.GetCellType(xlsxSheetName, "A2")
+		inputType, _ := f.GetCellType(xlsxSheetName, "B2")
+		numberType, _ := f.GetCellType(xlsxSheetName, "D2")
+		assert.Equal(t, textType, inputType)
+		assert.NotEqual(t, textType, numberType)
+	})
+
+	t.Run("xlsx in strict mode expect 400 with ErrReadingCSV", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, MIMEApplicationXLSX, map[string]string{"allowUnknownColumns": "true", "strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrReadingCSV.Error(), got.Message)
+	})
+
+	t.Run("text/csv in strict mode expect 400 instead of a partial file", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, MIMETextCSV, map[string]string{"allowUnknownColumns": "true", "strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrReadingCSV.Error(), got.Message)
+		assert.Equal(t, []CsvRowError{{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}}, got.Errors)
+	})
+
+	t.Run("text/csv in strict mode without invalid rows expect the whole file", func(t *testing.T) {
+		// Arrange
+		rec, c := upload("totalIncome\n500000", MIMETextCSV, map[string]string{"strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		want := "\uFEFF"
+		want += "totalIncome,tax,taxRefund,\"0-150,000\",\"150,001-500,000\",\"500,001-1,000,000\",\"1,000,001-2,000,000\",\"2,000,001 ขึ้นไป\",error\n"
+		want += "500000,29000,0,0,29000,0,0,0,\n"
+		assert.Equal(t, want, rec.Body.String())
+	})
+
+	t.Run("text/csv in strict mode over the buffer limit expect 413", func(t *testing.T) {
+		// Arrange
+		rec, c := upload("totalIncome\n500000\n600000\n700000", MIMETextCSV, map[string]string{"strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock, WithCSVMaxBufferedRows(2)).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("xlsx in strict mode with an invalid value expect 400 with the row", func(t *testing.T) {
+		// Arrange
+		invalid := "totalIncome,wht\n500000,0\n500000,600000"
+		rec, c := upload(invalid, MIMEApplicationXLSX, map[string]string{"strict": "true"})
+		mock := NewMockTaxStorer()
+		mock.deduction = deductionData
+
+		// Act
+		err := New(mock).UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrInvalidCSVRow.Error(), got.Message)
+		assert.Len(t, got.Errors, 1)
+		assert.Equal(t, 3, got.Errors[0].Row)
+		assert.Equal(t, "wht", got.Errors[0].Column)
+	})
+}
+
+func TestUploadCSVHandler_XLSX(t *testing.T) {
+	// Arrange
+	workbook := newTestWorkbook(t, "payroll", map[string]any{
+		"A1": "totalIncome", "B1": "wht", "C1": "donation",
+		"A2": 500_000, "B2": 0, "C2": 0,
+		"A3": 750_000, "B3": 50_000, "C3": 15_000,
+	})
+	rec, c := newUploadContext("/tax/calculations/upload-csv", "taxes.xlsx", workbook.Bytes(), map[string]string{"sheet": "payroll"}, nil)
+
+	mock := NewMockTaxStorer()
+	mock.deduction = deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	// Act
+	err := New(mock).UploadCSVHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got CsvTaxResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+	}
+	assert.Equal(t, []CsvTaxRecord{
+		{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0},
+		{Row: 3, TotalIncome: 750_000.0, Tax: 11_250.0},
+	}, got.Taxes)
+}
+
+func TestUploadCSVHandler_BufferLimit(t *testing.T) {
+	// Arrange
+	rec, c := newUploadContext("/tax/calculations/upload-csv", "taxes.csv", []byte("totalIncome\n500000\n600000\n700000"), nil, nil)
+
+	mock := NewMockTaxStorer()
+	mock.deduction = deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	// Act
+	err := New(mock, WithCSVMaxBufferedRows(2)).UploadCSVHandler(c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var got Err
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+	}
+	assert.Equal(t, ErrCSVTooLarge.Error(), got.Message)
+}
+
+func TestHandler_CSVRowLimit(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{name: "not configured; expect no limit", want: -1},
+		{name: "configured", opts: []Option{WithCSVMaxBufferedRows(500)}, want: 500},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := New(NewMockTaxStorer(), tc.opts...).csvRowLimit()
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestUploadCSVHandler_Error(t *testing.T) {
+	t.Run("wrong form-field expect 400 with ErrUploadingFile", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("wrongField", "taxes.csv")
+		data := "totalIncome,wht,donation" + "\n"
+		data += "500000,0,0"
+		part.Write([]byte(data))
+		writer.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		// Act
+		h := New(NewMockTaxStorer())
+		err := h.UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.NotEmpty(t, got.Message)
+		assert.Equal(t, ErrUploadingFile.Error(), got.Message)
+	})
+
+	t.Run("no content-type expect 400 with ErrUploadingFile", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
+		data := "totalIncome,wht,donation" + "\n"
+		data += "500000,0,0"
+		part.Write([]byte(data))
+		writer.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		// Act
+		h := New(NewMockTaxStorer())
+		err := h.UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.NotEmpty(t, got.Message)
+		assert.Equal(t, ErrUploadingFile.Error(), got.Message)
+	})
+
+	t.Run("invalid csv header expect 400 with ErrInvalidCSVHeader naming the columns", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
+		part.Write([]byte("not csv format"))
+		writer.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		// Act
+		h := New(NewMockTaxStorer())
+		err := h.UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrInvalidCSVHeader.Error(), got.Message)
+		assert.Equal(t, []CsvRowError{
+			{Row: 1, Column: "not csv format", Reason: ErrUnknownCSVColumn.Error()},
+			{Row: 1, Column: "totalIncome", Reason: ErrMissingCSVColumn.Error()},
+		}, got.Errors)
+	})
+
+	t.Run("invalid parsing csv in strict mode expect 400 with ErrReadingCSV", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
+		data := "totalIncome,wht,donation" + "\n"
+		data += "ABC,0,0"
+		part.Write([]byte(data))
+		writer.WriteField("strict", "true")
+		writer.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mock := NewMockTaxStorer()
+		mock.ExpectToCall("UploadCSV")
+		mock.deduction = deduction.Deduction{
+			Personal: 60_000.0,
+			KReceipt: 50_000.0,
+			Donation: 100_000.0,
+		}
+
+		// Act
+		h := New(mock)
+		err := h.UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var gotErr Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &gotErr); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrReadingCSV.Error(), gotErr.Message)
+		assert.Equal(t, []CsvRowError{{Row: 2, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}}, gotErr.Errors)
+	})
+
+	t.Run("get deduction error expect 500 with ErrGettingDeduction", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
+		data := "totalIncome,wht,donation" + "\n"
+		data += "500000,0,0" + "\n"
+		data += "600000,40000,20000" + "\n"
+		data += "750000,50000,15000"
+		part.Write([]byte(data))
+		writer.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mock := NewMockTaxStorer()
+		mock.ExpectToCall("UploadCSV")
+		mock.err = ErrGettingDeduction
+
+		// Act
+		h := New(mock)
+		err := h.UploadCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		var gotErr Err
+		if err := json.Unmarshal(rec.Body.Bytes(), &gotErr); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, ErrGettingDeduction.Error(), gotErr.Message)
+	})
+
+	t.Run("zero-deduction expect 500 with ErrCalculatingTax", func(t *testing.T) {
+		// Arrange
+		body := new(bytes.Buffer)
 		writer := multipart.NewWriter(body)
 		part, _ := writer.CreateFormFile("taxFile", "taxes.csv")
 		data := "totalIncome,wht,donation" + "\n"
@@ -711,3 +1770,79 @@ func TestUploadCSVHandler_Error(t *testing.T) {
 		assert.Equal(t, ErrCalculatingTax.Error(), gotErr.Message)
 	})
 }
+
+func TestValidateCSVHandler(t *testing.T) {
+	upload := func(data string, lang string) (*httptest.ResponseRecorder, echo.Context) {
+		return newUploadContext("/tax/calculations/upload-csv/validate", "taxes.csv", []byte(data), nil, map[string]string{i18n.HeaderAcceptLanguage: lang})
+	}
+	data := "totalIncome,wht,donation\n500000,0,0\nABC,0,0\n600000,150000,0\n"
+
+	t.Run("report with errors and warnings", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, "")
+		mock := NewMockTaxStorer()
+		mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+
+		// Act
+		err := New(mock).ValidateCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got CsvValidationReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, CsvValidationReport{
+			Rows:        3,
+			ValidRows:   2,
+			InvalidRows: 1,
+			Errors:      []CsvRowError{{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}},
+			Warnings:    []CsvRowWarning{{Row: 4, Column: "wht", Value: "150000", Reason: WarnHighWHT.Error()}},
+		}, got)
+	})
+
+	t.Run("thai; expect translated warnings", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, "th")
+		mock := NewMockTaxStorer()
+		mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+
+		// Act
+		err := New(mock).ValidateCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		var got CsvValidationReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Equal(t, messages.Translate(i18n.LanguageThai, WarnHighWHT.Error()), got.Warnings[0].Reason)
+	})
+
+	t.Run("missing required column; expect bad request", func(t *testing.T) {
+		// Arrange
+		rec, c := upload("wht\n0\n", "")
+
+		// Act
+		err := New(NewMockTaxStorer()).ValidateCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("get deduction error; expect internal server error", func(t *testing.T) {
+		// Arrange
+		rec, c := upload(data, "")
+		mock := NewMockTaxStorer()
+		mock.err = errors.New("unexpected error")
+
+		// Act
+		err := New(mock).ValidateCSVHandler(c)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
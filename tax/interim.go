@@ -0,0 +1,38 @@
+package tax
+
+import (
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+)
+
+// halfYearDeduction halves the allowances that are granted per year. The
+// donation cap is kept since donations are deducted as actually paid.
+func halfYearDeduction(d deduction.Deduction) deduction.Deduction {
+	return deduction.Deduction{
+		Personal: d.Personal / 2,
+		KReceipt: d.KReceipt / 2,
+		Donation: d.Donation,
+	}
+}
+
+func validateInterimTaxInformation(info TaxInformation) (err error) {
+	if info.Severance != nil || info.InterimTax != 0 {
+		err = errors.Join(err, ErrNotApplicableToInterim)
+	}
+	return
+}
+
+// CalculateInterimTax calculates the half-year (PND.94) tax on January-June
+// income of categories 40(5)-40(8). The result is credited through
+// TaxInformation.InterimTax in the annual calculation.
+func CalculateInterimTax(info TaxInformation, deduction deduction.Deduction) (TaxResult, error) {
+	if err := validateCalculation(info, deduction); err != nil {
+		return TaxResult{}, err
+	}
+
+	if err := validateInterimTaxInformation(info); err != nil {
+		return TaxResult{}, errors.Join(err, ErrInvalidTaxInformation)
+	}
+
+	return calculateTax(info, halfYearDeduction(deduction)), nil
+}
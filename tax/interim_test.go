@@ -0,0 +1,113 @@
+//go:build unit
+
+package tax
+
+import (
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHalfYearDeduction(t *testing.T) {
+	// Arrange
+	d := deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+
+	// Act
+	got := halfYearDeduction(d)
+
+	// Assert
+	assert.Equal(t, deduction.Deduction{Personal: 30_000.0, KReceipt: 25_000.0, Donation: 100_000.0}, got)
+}
+
+func TestCalculateInterimTax_Success(t *testing.T) {
+	// Arrange
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+	testCases := []struct {
+		name          string
+		taxInfo       TaxInformation
+		wantTax       float64
+		wantTaxRefund float64
+	}{
+		{
+			name:    "half-year personal deduction; expect interim tax",
+			taxInfo: TaxInformation{TotalIncome: 300_000.0},
+			wantTax: 12_000.0,
+		},
+		{
+			name: "k-receipt capped at half-year cap",
+			taxInfo: TaxInformation{
+				TotalIncome: 300_000.0,
+				Allowances:  []Allowance{{Type: AllowanceTypeKReceipt, Amount: 50_000.0}},
+			},
+			wantTax: 9_500.0,
+		},
+		{
+			name:          "WHT larger than interim tax; expect refund",
+			taxInfo:       TaxInformation{TotalIncome: 300_000.0, WHT: 15_000.0},
+			wantTaxRefund: 3_000.0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := CalculateInterimTax(tc.taxInfo, defaultDeduction)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTax, got.Tax)
+			assert.Equal(t, tc.wantTaxRefund, got.TaxRefund)
+		})
+	}
+}
+
+func TestCalculateInterimTax_Error(t *testing.T) {
+	defaultDeduction := deduction.Deduction{
+		Personal: 60_000.0,
+		KReceipt: 50_000.0,
+		Donation: 100_000.0,
+	}
+
+	testCases := []struct {
+		name      string
+		taxInfo   TaxInformation
+		deduction deduction.Deduction
+		wantErrs  []error
+	}{
+		{
+			name:      "severance is not applicable",
+			taxInfo:   TaxInformation{TotalIncome: 300_000.0, Severance: &Severance{Amount: 100_000.0}},
+			deduction: defaultDeduction,
+			wantErrs:  []error{ErrNotApplicableToInterim, ErrInvalidTaxInformation},
+		},
+		{
+			name:      "interim tax is not applicable",
+			taxInfo:   TaxInformation{TotalIncome: 300_000.0, InterimTax: 1_000.0},
+			deduction: defaultDeduction,
+			wantErrs:  []error{ErrNotApplicableToInterim, ErrInvalidTaxInformation},
+		},
+		{
+			name:      "invalid deduction",
+			taxInfo:   TaxInformation{TotalIncome: 300_000.0},
+			deduction: deduction.Deduction{},
+			wantErrs:  []error{ErrInvalidDeduction},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := CalculateInterimTax(tc.taxInfo, tc.deduction)
+
+			// Assert
+			assert.Equal(t, TaxResult{}, got)
+			for _, want := range tc.wantErrs {
+				assert.ErrorIs(t, err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package tax
+
+import "github.com/golfz/assessment-tax/i18n"
+
+var messages = i18n.Catalogue{
+	i18n.LanguageEnglish: {
+		"2,000,001 ขึ้นไป": "2,000,001 and above",
+	},
+	i18n.LanguageThai: {
+		ErrReadingRequestBody.Error(): "ไม่สามารถอ่านข้อมูลที่ส่งมาได้",
+		ErrGettingDeduction.Error():   "เกิดข้อผิดพลาดในการดึงข้อมูลค่าลดหย่อน",
+		ErrCalculatingTax.Error():     "เกิดข้อผิดพลาดในการคำนวณภาษี",
+
+		ErrInvalidTaxInformation.Error():  "ข้อมูลภาษีไม่ถูกต้อง",
+		ErrInvalidTotalIncome.Error():     "รายได้รวมต้องมากกว่าหรือเท่ากับ 0",
+		ErrInvalidWHT.Error():             "ภาษีหัก ณ ที่จ่ายต้องมากกว่าหรือเท่ากับ 0 และไม่เกินรายได้รวม",
+		ErrInvalidAllowanceAmount.Error(): "จำนวนค่าลดหย่อนต้องมากกว่าหรือเท่ากับ 0",
+		ErrInvalidSeverance.Error():       "เงินชดเชย อายุงาน และเงินเดือนสุดท้ายต้องมากกว่าหรือเท่ากับ 0",
+		ErrInvalidInterimTax.Error():      "ภาษีครึ่งปีที่ชำระแล้วต้องมากกว่าหรือเท่ากับ 0",
+		ErrNotApplicableToInterim.Error(): "ไม่สามารถใช้เงินชดเชยและภาษีครึ่งปีในการคำนวณภาษีครึ่งปีได้",
+
+		ErrInvalidOriginal.Error(): "ข้อมูลภาษีของแบบเดิมไม่ถูกต้อง",
+		ErrInvalidAmended.Error():  "ข้อมูลภาษีของแบบเพิ่มเติมไม่ถูกต้อง",
+		ErrAmendedAsOf.Error():     "วันที่ใช้ค่าลดหย่อนของแบบเพิ่มเติมต้องว่างหรือตรงกับแบบเดิม",
+
+		ErrInvalidDeduction.Error(): "ค่าลดหย่อนไม่ถูกต้อง",
+
+		ErrGettingExchangeRate.Error():  "เกิดข้อผิดพลาดในการดึงอัตราแลกเปลี่ยน",
+		ErrExchangeRateNotFound.Error(): "ไม่พบอัตราแลกเปลี่ยนของสกุลเงินและวันที่ที่ระบุ",
+
+		ErrUploadingFile.Error():    "ไม่สามารถอัปโหลดไฟล์ได้",
+		ErrReadingCSV.Error():       "ไม่สามารถอ่านไฟล์ csv ได้",
+		ErrParsingData.Error():      "ไม่สามารถแปลงข้อมูลได้",
+		ErrInvalidCSVHeader.Error(): "หัวคอลัมน์ของไฟล์ csv ไม่ถูกต้อง",
+
+		ErrReadingXLSX.Error():       "ไม่สามารถอ่านไฟล์ xlsx ได้",
+		ErrXLSXSheetNotFound.Error(): "ไม่พบชีตที่ระบุในไฟล์ xlsx",
+
+		ErrCreatingBatch.Error():    "เกิดข้อผิดพลาดในการสร้างงานคำนวณภาษี",
+		ErrGettingBatch.Error():     "เกิดข้อผิดพลาดในการดึงข้อมูลงานคำนวณภาษี",
+		ErrBatchNotFound.Error():    "ไม่พบงานคำนวณภาษี",
+		ErrBatchNotFinished.Error(): "งานคำนวณภาษียังไม่เสร็จ",
+		ErrInvalidCSVRow.Error():    "ไฟล์ csv มีแถวที่ไม่ถูกต้อง",
+		ErrBatchTooLarge.Error():    "ไฟล์ของงานคำนวณภาษีมีขนาดใหญ่เกินไป",
+		ErrBatchFinished.Error():    "งานคำนวณภาษีเสร็จสิ้นแล้ว",
+		ErrCancellingBatch.Error():  "เกิดข้อผิดพลาดในการยกเลิกงานคำนวณภาษี",
+
+		ErrUnknownCSVColumn.Error():        "พบคอลัมน์ที่ไม่รู้จักในไฟล์ csv",
+		ErrDuplicateCSVColumn.Error():      "คอลัมน์ในไฟล์ csv ซ้ำกันหรือไม่มีชื่อ",
+		ErrMissingCSVColumn.Error():        "ไฟล์ csv ไม่มีคอลัมน์ที่จำเป็น",
+		ErrCSVColumnCount.Error():          "จำนวนค่าในแถวไม่ตรงกับหัวคอลัมน์ของไฟล์ csv",
+		WarnHighWHT.Error():                "ภาษีหัก ณ ที่จ่ายมากกว่าร้อยละ 20 ของรายได้",
+		WarnAllowanceAboveCap.Error():      "ค่าลดหย่อนเกินเพดาน จะหักได้เพียงเท่าเพดาน",
+		ErrDuplicateCSVID.Error():          "รหัสซ้ำในไฟล์ csv",
+		ErrCSVTooLarge.Error():             "ไฟล์ csv มีจำนวนแถวมากเกินไป กรุณาขอผลลัพธ์แบบ application/x-ndjson",
+		ErrUnsupportedCSVEncoding.Error():  "ไม่รองรับการเข้ารหัสของไฟล์ csv กรุณาใช้ utf-8 หรือ tis-620",
+		ErrUnsupportedCSVDelimiter.Error(): "ไม่รองรับตัวคั่นของไฟล์ csv กรุณาใช้ comma, semicolon หรือ tab",
+		ErrCreatingTemplate.Error():        "เกิดข้อผิดพลาดในการสร้างไฟล์ตัวอย่าง csv",
+
+		ErrReadingZip.Error():          "ไม่สามารถอ่านไฟล์ zip ได้",
+		ErrZipTooManyEntries.Error():   "ไฟล์ zip มีจำนวนไฟล์มากเกินไป",
+		ErrZipTooLarge.Error():         "ไฟล์ zip มีขนาดหลังแตกไฟล์ใหญ่เกินไป",
+		ErrInvalidZipEntry.Error():     "ไฟล์ zip มีไฟล์ที่อยู่นอกโฟลเดอร์ของไฟล์ zip",
+		ErrUnsupportedZipEntry.Error(): "อ่านได้เฉพาะไฟล์ csv และ xlsx ในไฟล์ zip",
+
+		ErrCalculationBatchTooLarge.Error(): "จำนวนรายการที่ส่งมาคำนวณพร้อมกันมากเกินไป",
+		ErrMissingCalculationID.Error():     "ต้องระบุรหัสของรายการ",
+		ErrDuplicateCalculationID.Error():   "รหัสของรายการซ้ำกัน",
+
+		templateNoteRequired:       "จำเป็น",
+		templateNoteOptional:       "ไม่บังคับ",
+		templateAmountTitle:        "จำนวนเงินไม่ถูกต้อง",
+		templateAmountError:        "จำนวนเงินต้องเป็นตัวเลขที่มากกว่าหรือเท่ากับ 0",
+		"total income of the year": "รายได้รวมทั้งปี",
+		"withholding tax already paid, at most the total income":                     "ภาษีหัก ณ ที่จ่ายที่ชำระแล้ว ไม่เกินรายได้รวม",
+		"donations, deducted up to the donation cap":                                 "เงินบริจาค หักได้ไม่เกินเพดานเงินบริจาค",
+		"k-receipt purchases, deducted up to the k-receipt cap":                      "ค่าซื้อสินค้าที่ได้ k-receipt หักได้ไม่เกินเพดาน k-receipt",
+		"optional row identifier such as an employee number, unique within the file": "รหัสของแถว เช่น รหัสพนักงาน ต้องไม่ซ้ำกันในไฟล์",
+	},
+}
+
+func localiseTaxLevels(levels []TaxLevel, lang i18n.Language) []TaxLevel {
+	if levels == nil {
+		return nil
+	}
+	result := make([]TaxLevel, len(levels))
+	for i, level := range levels {
+		result[i] = TaxLevel{
+			Level: messages.Translate(lang, level.Level),
+			Tax:   level.Tax,
+		}
+	}
+	return result
+}
+
+func localiseCsvRowErrors(errs []CsvRowError, lang i18n.Language) []CsvRowError {
+	if errs == nil {
+		return nil
+	}
+	result := make([]CsvRowError, len(errs))
+	for i, e := range errs {
+		e.Reason = messages.Translate(lang, e.Reason)
+		result[i] = e
+	}
+	return result
+}
+
+func localiseCsvValidationReport(report CsvValidationReport, lang i18n.Language) CsvValidationReport {
+	report.Errors = localiseCsvRowErrors(report.Errors, lang)
+	warnings := make([]CsvRowWarning, len(report.Warnings))
+	for i, w := range report.Warnings {
+		w.Reason = messages.Translate(lang, w.Reason)
+		warnings[i] = w
+	}
+	report.Warnings = warnings
+	return report
+}
+
+func localiseCsvTaxSummary(summary CsvTaxSummary, lang i18n.Language) CsvTaxSummary {
+	brackets := make([]CsvBracketSummary, len(summary.Brackets))
+	for i, bracket := range summary.Brackets {
+		bracket.Level = messages.Translate(lang, bracket.Level)
+		brackets[i] = bracket
+	}
+	summary.Brackets = brackets
+	return summary
+}
+
+func localiseFieldChanges(changes []FieldChange, lang i18n.Language) []FieldChange {
+	if changes == nil {
+		return nil
+	}
+	result := make([]FieldChange, len(changes))
+	for i, change := range changes {
+		change.Field = messages.Translate(lang, change.Field)
+		result[i] = change
+	}
+	return result
+}
+
+func localiseAmendmentResult(result AmendmentResult, lang i18n.Language) AmendmentResult {
+	result.Original = localiseTaxResult(result.Original, lang)
+	result.Amended = localiseTaxResult(result.Amended, lang)
+	result.Diff.TaxLevels = localiseFieldChanges(result.Diff.TaxLevels, lang)
+	result.Diff.SeveranceTaxLevels = localiseFieldChanges(result.Diff.SeveranceTaxLevels, lang)
+	return result
+}
+
+func localiseTaxResult(result TaxResult, lang i18n.Language) TaxResult {
+	result.TaxLevels = localiseTaxLevels(result.TaxLevels, lang)
+	if result.Severance != nil {
+		severance := *result.Severance
+		severance.TaxLevels = localiseTaxLevels(severance.TaxLevels, lang)
+		result.Severance = &severance
+	}
+	return result
+}
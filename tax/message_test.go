@@ -0,0 +1,97 @@
+//go:build unit
+
+package tax
+
+import (
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLocaliseTaxResult(t *testing.T) {
+	// Arrange
+	result := TaxResult{
+		Tax: 19_000.0,
+		TaxLevels: []TaxLevel{
+			{Level: "150,001-500,000", Tax: 19_000.0},
+			{Level: "2,000,001 ขึ้นไป", Tax: 0.0},
+		},
+	}
+	testCases := []struct {
+		name       string
+		lang       i18n.Language
+		wantLevels []string
+	}{
+		{name: "default keeps original labels", lang: i18n.LanguageDefault, wantLevels: []string{"150,001-500,000", "2,000,001 ขึ้นไป"}},
+		{name: "english", lang: i18n.LanguageEnglish, wantLevels: []string{"150,001-500,000", "2,000,001 and above"}},
+		{name: "thai", lang: i18n.LanguageThai, wantLevels: []string{"150,001-500,000", "2,000,001 ขึ้นไป"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := localiseTaxResult(result, tc.lang)
+
+			// Assert
+			assert.Equal(t, result.Tax, got.Tax)
+			for i, want := range tc.wantLevels {
+				assert.Equal(t, want, got.TaxLevels[i].Level)
+			}
+		})
+	}
+
+	t.Run("does not modify input", func(t *testing.T) {
+		// Act
+		localiseTaxResult(result, i18n.LanguageEnglish)
+
+		// Assert
+		assert.Equal(t, "2,000,001 ขึ้นไป", result.TaxLevels[1].Level)
+	})
+}
+
+func TestMessages_ThaiCatalogueCoversAllErrors(t *testing.T) {
+	errs := []error{
+		ErrReadingRequestBody, ErrGettingDeduction, ErrCalculatingTax,
+		ErrInvalidTaxInformation, ErrInvalidTotalIncome, ErrInvalidWHT, ErrInvalidAllowanceAmount,
+		ErrInvalidSeverance, ErrInvalidInterimTax, ErrNotApplicableToInterim,
+		ErrInvalidOriginal, ErrInvalidAmended, ErrAmendedAsOf,
+		ErrInvalidDeduction,
+		ErrGettingExchangeRate, ErrExchangeRateNotFound,
+		ErrUploadingFile, ErrReadingCSV, ErrParsingData, ErrInvalidCSVHeader,
+		ErrUnknownCSVColumn, ErrDuplicateCSVColumn, ErrMissingCSVColumn, ErrCSVColumnCount, ErrDuplicateCSVID, ErrCSVTooLarge,
+		ErrUnsupportedCSVEncoding, ErrUnsupportedCSVDelimiter, ErrCreatingTemplate,
+		ErrReadingZip, ErrZipTooManyEntries, ErrZipTooLarge, ErrInvalidZipEntry, ErrUnsupportedZipEntry,
+		ErrCalculationBatchTooLarge, ErrMissingCalculationID, ErrDuplicateCalculationID,
+		WarnHighWHT, WarnAllowanceAboveCap,
+		ErrReadingXLSX, ErrXLSXSheetNotFound,
+		ErrCreatingBatch, ErrGettingBatch, ErrBatchNotFound, ErrBatchNotFinished, ErrInvalidCSVRow, ErrBatchTooLarge,
+		ErrBatchFinished, ErrCancellingBatch,
+	}
+
+	for _, err := range errs {
+		t.Run(err.Error(), func(t *testing.T) {
+			// Act
+			got := messages.Translate(i18n.LanguageThai, err.Error())
+
+			// Assert
+			assert.NotEqual(t, err.Error(), got)
+		})
+	}
+}
+
+func TestMessages_ThaiCatalogueCoversTemplateNotes(t *testing.T) {
+	notes := []string{templateNoteRequired, templateNoteOptional, templateAmountTitle, templateAmountError}
+	for _, col := range templateColumns() {
+		notes = append(notes, col.note)
+	}
+
+	for _, note := range notes {
+		t.Run(note, func(t *testing.T) {
+			// Act
+			got := messages.Translate(i18n.LanguageThai, note)
+
+			// Assert
+			assert.NotEqual(t, note, got)
+		})
+	}
+}
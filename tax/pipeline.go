@@ -0,0 +1,154 @@
+package tax
+
+import (
+	"context"
+	"github.com/golfz/assessment-tax/deduction"
+	"io"
+	"runtime"
+	"sync"
+)
+
+func defaultCalculationWorkers() int {
+	return runtime.NumCPU()
+}
+
+// rowOutcome is a csv row after calculation. Exactly one of err, rowErr,
+// errs and result applies: err when the file could not be read any further,
+// rowErr when the row could not be parsed, errs when it failed validation.
+type rowOutcome struct {
+	row    csvRow
+	result TaxResult
+	rowErr *rowError
+	errs   []*rowError
+	err    error
+}
+
+func (o rowOutcome) rowErrors() []*rowError {
+	if o.rowErr != nil {
+		return []*rowError{o.rowErr}
+	}
+	return o.errs
+}
+
+type rowJob struct {
+	row csvRow
+	out chan<- rowOutcome
+}
+
+// rowPipeline reads a csv on one goroutine, calculates its rows on a bounded
+// pool of workers and delivers the outcomes in file order. At most a few rows
+// per worker are in flight, so memory does not grow with the file.
+type rowPipeline struct {
+	outcomes <-chan rowOutcome
+
+	parent context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// calculateRows starts a pipeline over the remaining rows of cr, skipping the
+// rows numbered up to from. stop must be called once the caller is done, and
+// before cr is closed.
+func calculateRows(ctx context.Context, cr *CSVReader, deductionData deduction.Deduction, workers, from int) *rowPipeline {
+	if workers <= 0 {
+		workers = defaultCalculationWorkers()
+	}
+
+	pctx, cancel := context.WithCancel(ctx)
+	jobs := make(chan rowJob, workers)
+	ordered := make(chan chan rowOutcome, workers*2)
+	outcomes := make(chan rowOutcome)
+	p := &rowPipeline{outcomes: outcomes, parent: ctx, cancel: cancel}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(ordered)
+		defer close(jobs)
+		p.read(pctx, cr, from, jobs, ordered)
+	}()
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range jobs {
+				result, errs := calculateCSVRow(job.row, deductionData)
+				job.out <- rowOutcome{row: job.row, result: result, errs: errs}
+			}
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(outcomes)
+		for out := range ordered {
+			if pctx.Err() != nil {
+				return
+			}
+			select {
+			case outcome := <-out:
+				select {
+				case outcomes <- outcome:
+				case <-pctx.Done():
+					return
+				}
+			case <-pctx.Done():
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *rowPipeline) read(ctx context.Context, cr *CSVReader, from int, jobs chan<- rowJob, ordered chan<- chan rowOutcome) {
+	for ctx.Err() == nil {
+		row, rowErr, err := cr.next()
+		if err == io.EOF {
+			return
+		}
+
+		out := make(chan rowOutcome, 1)
+		if err != nil {
+			out <- rowOutcome{row: csvRow{number: cr.rowNumber}, err: err}
+			select {
+			case ordered <- out:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if row.number <= from {
+			continue
+		}
+
+		select {
+		case ordered <- out:
+		case <-ctx.Done():
+			return
+		}
+
+		if rowErr != nil {
+			out <- rowOutcome{row: row, rowErr: rowErr}
+			continue
+		}
+		select {
+		case jobs <- rowJob{row: row, out: out}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop cancels the pipeline and waits for its goroutines to finish.
+func (p *rowPipeline) stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// err reports why outcomes was closed early: the error of the caller's
+// context, or nil when every row was delivered.
+func (p *rowPipeline) err() error {
+	return p.parent.Err()
+}
@@ -0,0 +1,151 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func pipelineCSV(rows int) string {
+	var sb strings.Builder
+	sb.WriteString("totalIncome,wht\n")
+	for i := 0; i < rows; i++ {
+		if i%7 == 3 {
+			sb.WriteString("ABC,0\n")
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%d,0\n", 500_000+i))
+	}
+	return sb.String()
+}
+
+func collectOutcomes(p *rowPipeline) []rowOutcome {
+	outcomes := make([]rowOutcome, 0)
+	for outcome := range p.outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+func TestCalculateRows(t *testing.T) {
+	t.Run("keeps file order", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString(pipelineCSV(500)))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		p := calculateRows(context.Background(), cr, batchDeduction, 8, 0)
+		got := collectOutcomes(p)
+		p.stop()
+
+		// Assert
+		assert.NoError(t, p.err())
+		assert.Len(t, got, 500)
+		for i, outcome := range got {
+			assert.Equal(t, i+2, outcome.row.number)
+			if i%7 == 3 {
+				assert.NotNil(t, outcome.rowErr)
+				continue
+			}
+			assert.Nil(t, outcome.rowErrors())
+			assert.Equal(t, float64(500_000+i), outcome.row.info.TotalIncome)
+		}
+	})
+
+	t.Run("skips rows up to from", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString(pipelineCSV(10)))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		p := calculateRows(context.Background(), cr, batchDeduction, 2, 8)
+		got := collectOutcomes(p)
+		p.stop()
+
+		// Assert
+		assert.Len(t, got, 3)
+		assert.Equal(t, 9, got[0].row.number)
+	})
+
+	t.Run("broken csv; expect error outcome last", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString("totalIncome\n500000\n\"600000"))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		p := calculateRows(context.Background(), cr, batchDeduction, 2, 0)
+		got := collectOutcomes(p)
+		p.stop()
+
+		// Assert
+		assert.Len(t, got, 2)
+		assert.NoError(t, got[0].err)
+		assert.ErrorIs(t, got[1].err, ErrReadingCSV)
+	})
+
+	t.Run("cancelled; expect outcomes closed early", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString(pipelineCSV(1_000)))
+		assert.NoError(t, cr.readHeader())
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Act
+		p := calculateRows(ctx, cr, batchDeduction, 4, 0)
+		<-p.outcomes
+		cancel()
+		got := collectOutcomes(p)
+		p.stop()
+
+		// Assert
+		assert.Less(t, len(got), 999)
+		assert.ErrorIs(t, p.err(), context.Canceled)
+	})
+
+	t.Run("stopped by the caller; expect no error", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString(pipelineCSV(1_000)))
+		assert.NoError(t, cr.readHeader())
+
+		// Act
+		p := calculateRows(context.Background(), cr, batchDeduction, 4, 0)
+		<-p.outcomes
+		p.stop()
+
+		// Assert
+		assert.NoError(t, p.err())
+	})
+}
+
+// BenchmarkCalculateRows compares pool sizes over the pipeline every csv
+// endpoint and batch uses. Rows are read on one goroutine, so the gain levels
+// off once the workers calculate faster than the file is parsed:
+//
+//	go test -tags=unit -run=^$ -bench=CalculateRows ./tax
+func BenchmarkCalculateRows(b *testing.B) {
+	const rows = 10_000
+	content := pipelineCSV(rows)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cr := NewCSVReader(strings.NewReader(content))
+				if err := cr.readHeader(); err != nil {
+					b.Fatal(err)
+				}
+				p := calculateRows(context.Background(), cr, batchDeduction, workers, 0)
+				for range p.outcomes {
+				}
+				p.stop()
+				if err := p.err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package tax
+
+import "math"
+
+const (
+	// Severance paid under the labour protection law is exempt up to the last
+	// 400 days of wages, capped at 600,000.
+	severanceExemptDays     = 400.0
+	severanceMaxExemption   = 600_000.0
+	severanceDaysPerMonth   = 30.0
+	severanceDeductionYear  = 7_000.0
+	severanceTaxablePortion = 0.5
+
+	// Severance can be taxed separately only after at least 5 years of
+	// service; otherwise it is added to the regular income.
+	severanceMinYearsForSeparate = 5
+)
+
+type severanceCalculation struct {
+	result        *SeveranceResult
+	regularIncome float64
+}
+
+func calculateSeveranceExemption(s Severance) float64 {
+	lastWages := s.LastSalary / severanceDaysPerMonth * severanceExemptDays
+	return math.Min(s.Amount, math.Min(lastWages, severanceMaxExemption))
+}
+
+func calculateSeverance(s *Severance) severanceCalculation {
+	if s == nil {
+		return severanceCalculation{}
+	}
+
+	exemption := calculateSeveranceExemption(*s)
+	remaining := s.Amount - exemption
+
+	if s.YearsOfService < severanceMinYearsForSeparate {
+		return severanceCalculation{
+			result: &SeveranceResult{
+				SeparateTaxation: false,
+				Exemption:        exemption,
+				TaxableIncome:    remaining,
+			},
+			regularIncome: remaining,
+		}
+	}
+
+	serviceDeduction := math.Min(severanceDeductionYear*float64(s.YearsOfService), remaining)
+	taxableIncome := (remaining - serviceDeduction) * severanceTaxablePortion
+
+	result := &SeveranceResult{
+		SeparateTaxation: true,
+		Exemption:        exemption,
+		ServiceDeduction: serviceDeduction,
+		TaxableIncome:    taxableIncome,
+	}
+	result.Tax, result.TaxLevels = calculateProgressiveTax(taxableIncome)
+
+	return severanceCalculation{result: result}
+}
@@ -0,0 +1,105 @@
+//go:build unit
+
+package tax
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCalculateSeverance_Nil(t *testing.T) {
+	// Act
+	got := calculateSeverance(nil)
+
+	// Assert
+	assert.Nil(t, got.result)
+	assert.Equal(t, 0.0, got.regularIncome)
+}
+
+func TestCalculateSeverance(t *testing.T) {
+	testCases := []struct {
+		name              string
+		severance         Severance
+		wantResult        SeveranceResult
+		wantRegularIncome float64
+		wantTaxLevels     []float64
+	}{
+		{
+			name:      "10 years; exemption by last 400 days wages; expect separate tax",
+			severance: Severance{Amount: 1_000_000.0, YearsOfService: 10, LastSalary: 30_000.0},
+			wantResult: SeveranceResult{
+				SeparateTaxation: true,
+				Exemption:        400_000.0,
+				ServiceDeduction: 70_000.0,
+				TaxableIncome:    265_000.0,
+				Tax:              11_500.0,
+			},
+			wantTaxLevels: []float64{0.0, 11_500.0, 0.0, 0.0, 0.0},
+		},
+		{
+			name:      "exemption capped at 600,000",
+			severance: Severance{Amount: 2_000_000.0, YearsOfService: 20, LastSalary: 100_000.0},
+			wantResult: SeveranceResult{
+				SeparateTaxation: true,
+				Exemption:        600_000.0,
+				ServiceDeduction: 140_000.0,
+				TaxableIncome:    630_000.0,
+				Tax:              54_500.0,
+			},
+			wantTaxLevels: []float64{0.0, 35_000.0, 19_500.0, 0.0, 0.0},
+		},
+		{
+			name:      "fully exempt; expect zero tax",
+			severance: Severance{Amount: 200_000.0, YearsOfService: 6, LastSalary: 20_000.0},
+			wantResult: SeveranceResult{
+				SeparateTaxation: true,
+				Exemption:        200_000.0,
+				ServiceDeduction: 0.0,
+				TaxableIncome:    0.0,
+				Tax:              0.0,
+			},
+			wantTaxLevels: []float64{0.0, 0.0, 0.0, 0.0, 0.0},
+		},
+		{
+			name:      "service deduction capped at remaining amount",
+			severance: Severance{Amount: 100_000.0, YearsOfService: 30, LastSalary: 0.0},
+			wantResult: SeveranceResult{
+				SeparateTaxation: true,
+				Exemption:        0.0,
+				ServiceDeduction: 100_000.0,
+				TaxableIncome:    0.0,
+				Tax:              0.0,
+			},
+			wantTaxLevels: []float64{0.0, 0.0, 0.0, 0.0, 0.0},
+		},
+		{
+			name:      "less than 5 years; expect added to regular income",
+			severance: Severance{Amount: 500_000.0, YearsOfService: 4, LastSalary: 30_000.0},
+			wantResult: SeveranceResult{
+				SeparateTaxation: false,
+				Exemption:        400_000.0,
+				TaxableIncome:    100_000.0,
+			},
+			wantRegularIncome: 100_000.0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := calculateSeverance(&tc.severance)
+
+			// Assert
+			assert.Equal(t, tc.wantRegularIncome, got.regularIncome)
+			assert.Equal(t, tc.wantResult.SeparateTaxation, got.result.SeparateTaxation)
+			assert.Equal(t, tc.wantResult.Exemption, got.result.Exemption)
+			assert.Equal(t, tc.wantResult.ServiceDeduction, got.result.ServiceDeduction)
+			assert.Equal(t, tc.wantResult.TaxableIncome, got.result.TaxableIncome)
+			assert.Equal(t, tc.wantResult.Tax, got.result.Tax)
+			assert.Len(t, got.result.TaxLevels, len(tc.wantTaxLevels))
+			for i, wantTax := range tc.wantTaxLevels {
+				assert.Equal(t, wantTax, got.result.TaxLevels[i].Tax)
+			}
+		})
+	}
+}
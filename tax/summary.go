@@ -0,0 +1,229 @@
+package tax
+
+import (
+	"math"
+	"sort"
+)
+
+// CsvTaxSummary totals a csv upload. Rows counts the calculated rows and
+// FailedRows the skipped ones. MinTax, MaxTax and MedianTax are taken over the
+// tax payable of the calculated rows. MedianTax is exact up to
+// summaryExactTaxes rows; past that it is estimated to within
+// summaryMedianAccuracy of the true median.
+type CsvTaxSummary struct {
+	Rows        int                 `json:"rows"`
+	FailedRows  int                 `json:"failedRows"`
+	TotalIncome float64             `json:"totalIncome"`
+	TotalTax    float64             `json:"totalTax"`
+	TotalRefund float64             `json:"totalRefund"`
+	Brackets    []CsvBracketSummary `json:"brackets"`
+	MinTax      float64             `json:"minTax"`
+	MaxTax      float64             `json:"maxTax"`
+	MedianTax   float64             `json:"medianTax"`
+}
+
+// CsvBracketSummary is one tax bracket of a summary. Count is the number of
+// rows whose highest taxed bracket it is, and Tax the tax all rows are charged
+// in it before withholding tax is credited.
+type CsvBracketSummary struct {
+	Level string  `json:"level"`
+	Count int     `json:"count"`
+	Tax   float64 `json:"tax"`
+}
+
+const (
+	// summaryExactTaxes is how many taxes a summary keeps to find the exact
+	// median before it falls back to counting them in buckets.
+	summaryExactTaxes = 10_000
+	// summaryMedianAccuracy is the relative error of an estimated median.
+	summaryMedianAccuracy = 0.005
+)
+
+// summaryBuilder adds rows up one at a time, in the same pass that calculates
+// them. It keeps the tax of each row, up to summaryExactTaxes, to find the
+// median, so that summarising a file of any size takes bounded memory.
+type summaryBuilder struct {
+	summary CsvTaxSummary
+	taxes   taxQuantiles
+}
+
+func newSummaryBuilder() *summaryBuilder {
+	brackets := make([]CsvBracketSummary, len(rates))
+	for i, r := range rates {
+		brackets[i] = CsvBracketSummary{Level: r.description}
+	}
+	return &summaryBuilder{summary: CsvTaxSummary{Brackets: brackets}}
+}
+
+// levelTaxes keeps the amounts of tax levels, which are always in bracket
+// order.
+func levelTaxes(levels []TaxLevel) []float64 {
+	result := make([]float64, len(levels))
+	for i, level := range levels {
+		result[i] = level.Tax
+	}
+	return result
+}
+
+// add counts a calculated row, given the tax of each of its levels.
+func (s *summaryBuilder) add(record CsvTaxRecord, levels []float64) {
+	s.summary.Rows++
+	s.summary.TotalIncome += record.TotalIncome
+	s.summary.TotalTax += record.Tax
+	s.summary.TotalRefund += record.TaxRefund
+	s.taxes.add(record.Tax)
+
+	highest := 0
+	for i, tax := range levels {
+		if i >= len(s.summary.Brackets) {
+			break
+		}
+		s.summary.Brackets[i].Tax += tax
+		if tax > 0 {
+			highest = i
+		}
+	}
+	s.summary.Brackets[highest].Count++
+}
+
+func (s *summaryBuilder) addFailed() {
+	s.summary.FailedRows++
+}
+
+// merge adds up the rows of other, so that files summarised on their own can
+// also be summarised together.
+func (s *summaryBuilder) merge(other *summaryBuilder) {
+	s.summary.Rows += other.summary.Rows
+	s.summary.FailedRows += other.summary.FailedRows
+	s.summary.TotalIncome += other.summary.TotalIncome
+	s.summary.TotalTax += other.summary.TotalTax
+	s.summary.TotalRefund += other.summary.TotalRefund
+	s.taxes.merge(&other.taxes)
+	for i := range s.summary.Brackets {
+		s.summary.Brackets[i].Count += other.summary.Brackets[i].Count
+		s.summary.Brackets[i].Tax += other.summary.Brackets[i].Tax
+	}
+}
+
+func (s *summaryBuilder) build() CsvTaxSummary {
+	summary := s.summary
+	summary.Brackets = append([]CsvBracketSummary(nil), s.summary.Brackets...)
+	if s.taxes.count == 0 {
+		return summary
+	}
+
+	summary.MinTax = s.taxes.min
+	summary.MaxTax = s.taxes.max
+	summary.MedianTax = s.taxes.median()
+	return summary
+}
+
+// taxQuantiles finds the median of the taxes added to it. It keeps them all
+// until there are more than summaryExactTaxes, then counts them in buckets
+// whose bounds grow by a fixed ratio, so that the median it estimates is off
+// by at most summaryMedianAccuracy, whatever the number of taxes.
+type taxQuantiles struct {
+	count    int
+	min, max float64
+	exact    []float64
+	zeros    int
+	buckets  map[int]int
+}
+
+// taxBucketRatio is the ratio between the bounds of a bucket. The midpoint of
+// a bucket is within summaryMedianAccuracy of every tax counted in it.
+var taxBucketRatio = (1 + summaryMedianAccuracy) / (1 - summaryMedianAccuracy)
+
+func (q *taxQuantiles) add(tax float64) {
+	if q.count == 0 || tax < q.min {
+		q.min = tax
+	}
+	if q.count == 0 || tax > q.max {
+		q.max = tax
+	}
+	q.count++
+	if q.buckets == nil {
+		q.exact = append(q.exact, tax)
+		if len(q.exact) > summaryExactTaxes {
+			q.toBuckets()
+		}
+		return
+	}
+	q.bucket(tax)
+}
+
+func (q *taxQuantiles) merge(other *taxQuantiles) {
+	if other.count == 0 {
+		return
+	}
+	if q.count == 0 || other.min < q.min {
+		q.min = other.min
+	}
+	if q.count == 0 || other.max > q.max {
+		q.max = other.max
+	}
+	q.count += other.count
+	if q.buckets == nil && other.buckets == nil && len(q.exact)+len(other.exact) <= summaryExactTaxes {
+		q.exact = append(q.exact, other.exact...)
+		return
+	}
+	if q.buckets == nil {
+		q.toBuckets()
+	}
+	for _, tax := range other.exact {
+		q.bucket(tax)
+	}
+	q.zeros += other.zeros
+	for i, n := range other.buckets {
+		q.buckets[i] += n
+	}
+}
+
+// toBuckets moves the taxes kept so far into buckets.
+func (q *taxQuantiles) toBuckets() {
+	q.buckets = make(map[int]int)
+	for _, tax := range q.exact {
+		q.bucket(tax)
+	}
+	q.exact = nil
+}
+
+// bucket counts tax in its bucket. Taxes under one satang all count as zero.
+func (q *taxQuantiles) bucket(tax float64) {
+	if tax < 0.01 {
+		q.zeros++
+		return
+	}
+	q.buckets[int(math.Ceil(math.Log(tax)/math.Log(taxBucketRatio)))]++
+}
+
+func (q *taxQuantiles) median() float64 {
+	if q.buckets == nil {
+		taxes := append([]float64(nil), q.exact...)
+		sort.Float64s(taxes)
+		middle := len(taxes) / 2
+		if len(taxes)%2 == 0 {
+			return (taxes[middle-1] + taxes[middle]) / 2
+		}
+		return taxes[middle]
+	}
+
+	rank := q.count / 2
+	if rank < q.zeros {
+		return 0
+	}
+	rank -= q.zeros
+	indexes := make([]int, 0, len(q.buckets))
+	for i := range q.buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if rank < q.buckets[i] {
+			median := 2 * math.Pow(taxBucketRatio, float64(i)) / (1 + taxBucketRatio)
+			return math.Min(math.Max(median, q.min), q.max)
+		}
+		rank -= q.buckets[i]
+	}
+	return q.max
+}
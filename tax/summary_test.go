@@ -0,0 +1,164 @@
+//go:build unit
+
+package tax
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestSummaryBuilder(t *testing.T) {
+	t.Run("totals, brackets and median of an even count", func(t *testing.T) {
+		// Arrange
+		s := newSummaryBuilder()
+
+		// Act
+		s.add(CsvTaxRecord{TotalIncome: 100_000.0}, []float64{0, 0, 0, 0, 0})
+		s.add(CsvTaxRecord{TotalIncome: 500_000.0, Tax: 29_000.0}, []float64{0, 29_000.0, 0, 0, 0})
+		s.add(CsvTaxRecord{TotalIncome: 600_000.0, TaxRefund: 2_000.0}, []float64{0, 35_000.0, 3_000.0, 0, 0})
+		s.add(CsvTaxRecord{TotalIncome: 3_000_000.0, Tax: 600_000.0}, []float64{0, 35_000.0, 75_000.0, 200_000.0, 290_000.0})
+		s.addFailed()
+		got := s.build()
+
+		// Assert
+		assert.Equal(t, CsvTaxSummary{
+			Rows:        4,
+			FailedRows:  1,
+			TotalIncome: 4_200_000.0,
+			TotalTax:    629_000.0,
+			TotalRefund: 2_000.0,
+			Brackets: []CsvBracketSummary{
+				{Level: "0-150,000", Count: 1},
+				{Level: "150,001-500,000", Count: 1, Tax: 99_000.0},
+				{Level: "500,001-1,000,000", Count: 1, Tax: 78_000.0},
+				{Level: "1,000,001-2,000,000", Tax: 200_000.0},
+				{Level: "2,000,001 ขึ้นไป", Count: 1, Tax: 290_000.0},
+			},
+			MinTax:    0,
+			MaxTax:    600_000.0,
+			MedianTax: 14_500.0,
+		}, got)
+	})
+
+	t.Run("median of an odd count", func(t *testing.T) {
+		// Arrange
+		s := newSummaryBuilder()
+
+		// Act
+		for _, tax := range []float64{30.0, 10.0, 20.0} {
+			s.add(CsvTaxRecord{Tax: tax}, nil)
+		}
+		got := s.build()
+
+		// Assert
+		assert.Equal(t, 10.0, got.MinTax)
+		assert.Equal(t, 30.0, got.MaxTax)
+		assert.Equal(t, 20.0, got.MedianTax)
+		assert.Equal(t, 3, got.Brackets[0].Count)
+	})
+
+	t.Run("no calculated rows", func(t *testing.T) {
+		// Arrange
+		s := newSummaryBuilder()
+
+		// Act
+		s.addFailed()
+		got := s.build()
+
+		// Assert
+		assert.Equal(t, 0, got.Rows)
+		assert.Equal(t, 1, got.FailedRows)
+		assert.Equal(t, 0.0, got.MedianTax)
+		assert.Len(t, got.Brackets, len(rates))
+	})
+}
+
+func TestSummaryBuilder_Merge(t *testing.T) {
+	// Arrange
+	a := newSummaryBuilder()
+	a.add(CsvTaxRecord{TotalIncome: 500_000.0, Tax: 29_000.0}, []float64{0, 29_000.0, 0, 0, 0})
+	a.addFailed()
+	b := newSummaryBuilder()
+	b.add(CsvTaxRecord{TotalIncome: 100_000.0}, []float64{0, 0, 0, 0, 0})
+	b.add(CsvTaxRecord{TotalIncome: 600_000.0, Tax: 38_000.0}, []float64{0, 35_000.0, 3_000.0, 0, 0})
+	total := newSummaryBuilder()
+
+	// Act
+	total.merge(a)
+	total.merge(b)
+	got := total.build()
+
+	// Assert
+	assert.Equal(t, 3, got.Rows)
+	assert.Equal(t, 1, got.FailedRows)
+	assert.Equal(t, 1_200_000.0, got.TotalIncome)
+	assert.Equal(t, 67_000.0, got.TotalTax)
+	assert.Equal(t, CsvBracketSummary{Level: "150,001-500,000", Count: 1, Tax: 64_000.0}, got.Brackets[1])
+	assert.Equal(t, CsvBracketSummary{Level: "500,001-1,000,000", Count: 1, Tax: 3_000.0}, got.Brackets[2])
+	assert.Equal(t, 29_000.0, got.MedianTax)
+}
+
+func TestSummaryBuilder_EstimatedMedian(t *testing.T) {
+	rows := 3*summaryExactTaxes + 1
+
+	t.Run("more rows than kept exactly; expect bounded memory and median within accuracy", func(t *testing.T) {
+		// Arrange
+		s := newSummaryBuilder()
+
+		// Act
+		for i := 0; i < rows; i++ {
+			s.add(CsvTaxRecord{Tax: float64(i)}, nil)
+		}
+		got := s.build()
+
+		// Assert
+		assert.Nil(t, s.taxes.exact)
+		assert.Less(t, len(s.taxes.buckets), summaryExactTaxes/10)
+		assert.Equal(t, 0.0, got.MinTax)
+		assert.Equal(t, float64(rows-1), got.MaxTax)
+		want := float64(rows / 2)
+		assert.LessOrEqual(t, math.Abs(got.MedianTax-want), want*summaryMedianAccuracy)
+	})
+
+	t.Run("mostly zero taxes; expect zero median", func(t *testing.T) {
+		// Arrange
+		s := newSummaryBuilder()
+
+		// Act
+		for i := 0; i < rows; i++ {
+			tax := 0.0
+			if i%3 == 0 {
+				tax = 50_000.0
+			}
+			s.add(CsvTaxRecord{Tax: tax}, nil)
+		}
+		got := s.build()
+
+		// Assert
+		assert.Equal(t, 0.0, got.MedianTax)
+		assert.Equal(t, 50_000.0, got.MaxTax)
+	})
+
+	t.Run("merged past the exact limit; expect median within accuracy", func(t *testing.T) {
+		// Arrange
+		a := newSummaryBuilder()
+		b := newSummaryBuilder()
+		for i := 0; i < summaryExactTaxes; i++ {
+			a.add(CsvTaxRecord{Tax: 1_000.0}, nil)
+			b.add(CsvTaxRecord{Tax: 3_000.0}, nil)
+		}
+		b.add(CsvTaxRecord{Tax: 3_000.0}, nil)
+		total := newSummaryBuilder()
+
+		// Act
+		total.merge(a)
+		total.merge(b)
+		got := total.build()
+
+		// Assert
+		assert.Equal(t, 2*summaryExactTaxes+1, got.Rows)
+		assert.Equal(t, 1_000.0, got.MinTax)
+		assert.InDelta(t, 3_000.0, got.MedianTax, 3_000.0*summaryMedianAccuracy)
+	})
+}
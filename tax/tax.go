@@ -7,21 +7,73 @@ const (
 	AllowanceTypeKReceipt AllowanceType = "k-receipt"
 )
 
+var allowanceTypes = []AllowanceType{
+	AllowanceTypeDonation,
+	AllowanceTypeKReceipt,
+}
+
 type Allowance struct {
 	Type   AllowanceType `json:"allowanceType"`
 	Amount float64       `json:"amount" validate:"min=0"`
 }
 
+type ForeignAmount struct {
+	Amount   float64 `json:"amount" validate:"min=0"`
+	Currency string  `json:"currency" validate:"required"`
+	Date     string  `json:"date" validate:"required,datetime=2006-01-02"`
+}
+
+type Severance struct {
+	Amount         float64 `json:"amount" validate:"min=0"`
+	YearsOfService int     `json:"yearsOfService" validate:"min=0"`
+	LastSalary     float64 `json:"lastSalary" validate:"min=0"`
+}
+
 type TaxInformation struct {
-	TotalIncome float64     `json:"totalIncome" validate:"required,min=0"`
-	WHT         float64     `json:"wht" validate:"min=0"`
-	Allowances  []Allowance `json:"allowances"`
+	TotalIncome    float64         `json:"totalIncome" validate:"required_without_all=ForeignIncomes Severance,min=0"`
+	WHT            float64         `json:"wht" validate:"min=0"`
+	InterimTax     float64         `json:"interimTax,omitempty" validate:"min=0"`
+	Allowances     []Allowance     `json:"allowances"`
+	ForeignIncomes []ForeignAmount `json:"foreignIncomes,omitempty" validate:"dive"`
+	ForeignWHTs    []ForeignAmount `json:"foreignWht,omitempty" validate:"dive"`
+	Severance      *Severance      `json:"severance,omitempty"`
+	// AsOf is the date, or RFC 3339 time, at which deductions are taken;
+	// now when empty.
+	AsOf string `json:"asOf,omitempty"`
 }
 
 type TaxResult struct {
-	Tax       float64    `json:"tax"`
-	TaxRefund float64    `json:"taxRefund,omitempty"`
-	TaxLevels []TaxLevel `json:"taxLevel"`
+	Tax         float64              `json:"tax"`
+	TaxRefund   float64              `json:"taxRefund,omitempty"`
+	TaxLevels   []TaxLevel           `json:"taxLevel"`
+	Severance   *SeveranceResult     `json:"severance,omitempty"`
+	Conversions []CurrencyConversion `json:"conversions,omitempty"`
+}
+
+type SeveranceResult struct {
+	SeparateTaxation bool       `json:"separateTaxation"`
+	Exemption        float64    `json:"exemption"`
+	ServiceDeduction float64    `json:"serviceDeduction"`
+	TaxableIncome    float64    `json:"taxableIncome"`
+	Tax              float64    `json:"tax"`
+	TaxLevels        []TaxLevel `json:"taxLevel,omitempty"`
+}
+
+type ConversionType string
+
+const (
+	ConversionTypeIncome ConversionType = "income"
+	ConversionTypeWHT    ConversionType = "wht"
+)
+
+type CurrencyConversion struct {
+	Type      ConversionType `json:"type"`
+	Currency  string         `json:"currency"`
+	Date      string         `json:"date"`
+	Amount    float64        `json:"amount"`
+	Rate      float64        `json:"rate"`
+	RateDate  string         `json:"rateDate"`
+	AmountTHB float64        `json:"amountTHB"`
 }
 
 type TaxLevel struct {
@@ -43,11 +95,29 @@ type CsvTaxRequest struct {
 }
 
 type CsvTaxResponse struct {
-	Taxes []CsvTaxRecord `json:"taxes"`
+	Taxes   []CsvTaxRecord `json:"taxes"`
+	Errors  []CsvRowError  `json:"errors,omitempty"`
+	Summary *CsvTaxSummary `json:"summary,omitempty"`
 }
 
+// CsvTaxRecord is the tax of one csv row. ID echoes the id column of the row
+// and Metadata its pass-through columns, so that results can be matched back
+// to people.
 type CsvTaxRecord struct {
-	TotalIncome float64 `json:"totalIncome"`
-	Tax         float64 `json:"tax"`
-	TaxRefund   float64 `json:"taxRefund,omitempty"`
+	Row         int               `json:"row,omitempty"`
+	ID          string            `json:"id,omitempty"`
+	TotalIncome float64           `json:"totalIncome"`
+	Tax         float64           `json:"tax"`
+	TaxRefund   float64           `json:"taxRefund,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// CsvRowError reports a csv row that was skipped. Row is the line number in
+// the file, the header being row 1.
+type CsvRowError struct {
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
 }
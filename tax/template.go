@@ -0,0 +1,151 @@
+package tax
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+	"net/http"
+)
+
+const (
+	templateFileName = "tax-template"
+
+	templateNoteRequired = "required"
+	templateNoteOptional = "optional"
+	templateAmountTitle  = "invalid amount"
+	templateAmountError  = "amount must be a number greater than or equal to 0"
+)
+
+// templateColumns are the columns of the downloadable template, taken from
+// the columns the reader accepts.
+func templateColumns() []csvColumn {
+	columns := make([]csvColumn, 0)
+	for _, col := range csvColumns() {
+		if !col.isMetadata() {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// templateCSV is a csv with the header the reader accepts and one example
+// row.
+func templateCSV() ([]byte, error) {
+	columns := templateColumns()
+	header := make([]string, len(columns))
+	example := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+		example[i] = col.example
+	}
+
+	buf := bytes.NewBuffer(append([]byte(nil), utf8BOM...))
+	w := csv.NewWriter(buf)
+	if err := w.WriteAll([][]string{header, example}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateXLSX is templateCSV as a spreadsheet, with a note on each header
+// cell and amount columns limited to numbers of at least 0.
+func templateXLSX(lang i18n.Language) (*excelize.File, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), xlsxSheetName); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	for i, col := range templateColumns() {
+		name, err := excelize.ColumnNumberToName(i + 1)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := fillTemplateColumn(f, name, col, lang); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func fillTemplateColumn(f *excelize.File, name string, col csvColumn, lang i18n.Language) error {
+	if err := f.SetCellStr(xlsxSheetName, name+"1", col.name); err != nil {
+		return err
+	}
+
+	presence := templateNoteOptional
+	if col.required {
+		presence = templateNoteRequired
+	}
+	note := messages.Translate(lang, presence)
+	if col.note != "" {
+		note = messages.Translate(lang, col.note) + " (" + note + ")"
+	}
+	if err := f.AddComment(xlsxSheetName, excelize.Comment{Cell: name + "1", Text: note}); err != nil {
+		return err
+	}
+
+	if col.apply == nil {
+		return f.SetCellStr(xlsxSheetName, name+"2", col.example)
+	}
+	if err := f.SetCellValue(xlsxSheetName, name+"2", parseTemplateExample(col.example)); err != nil {
+		return err
+	}
+	dv := excelize.NewDataValidation(true)
+	dv.SetSqref(name + "2:" + name + "1048576")
+	if err := dv.SetRange(0, 0, excelize.DataValidationTypeDecimal, excelize.DataValidationOperatorGreaterThanOrEqual); err != nil {
+		return err
+	}
+	dv.SetError(excelize.DataValidationErrorStyleStop, messages.Translate(lang, templateAmountTitle), messages.Translate(lang, templateAmountError))
+	return f.AddDataValidation(xlsxSheetName, dv)
+}
+
+// parseTemplateExample stores an example amount as a number, so the
+// spreadsheet shows it the way a user would type it.
+func parseTemplateExample(example string) any {
+	if amount, err := parseAmount(example); err == nil {
+		return amount
+	}
+	return example
+}
+
+// CSVTemplateHandler
+//
+//	@Summary		Download a template for csv uploads
+//	@Description	Download a file with the exact header upload-csv accepts, including one column per allowance type and the optional id column, followed by an example row.
+//	@Description	With Accept set to the xlsx media type the template is a spreadsheet with a note on every column and amount columns limited to numbers of at least 0.
+//	@Tags			tax
+//	@Param			Accept			header	string	false	"text/csv (default) or application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+//	@Param			Accept-Language	header	string	false	"Language of the notes (en, th)"
+//	@Produce		text/csv
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Success		200	{file}		file
+//	@Failure		500	{object}	Err
+//	@Router			/tax/calculations/csv-template [get]
+func (h *Handler) CSVTemplateHandler(c echo.Context) error {
+	if negotiateResultFormat(c.Request().Header.Get(echo.HeaderAccept)) == MIMEApplicationXLSX {
+		f, err := templateXLSX(i18n.FromRequest(c.Request()))
+		if err != nil {
+			return h.handleError(c, http.StatusInternalServerError, err, "creating template", ErrCreatingTemplate.Error())
+		}
+		defer f.Close()
+
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			return h.handleError(c, http.StatusInternalServerError, err, "creating template", ErrCreatingTemplate.Error())
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, attachment(templateFileName+".xlsx"))
+		return c.Blob(http.StatusOK, MIMEApplicationXLSX, buf.Bytes())
+	}
+
+	content, err := templateCSV()
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "creating template", ErrCreatingTemplate.Error())
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, attachment(templateFileName+".csv"))
+	return c.Blob(http.StatusOK, MIMETextCSV+"; charset=utf-8", content)
+}
@@ -0,0 +1,127 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTemplateCSV(t *testing.T) {
+	// Act
+	content, err := templateCSV()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, string(utf8BOM)+"totalIncome,wht,donation,k-receipt,id\n500000,0,0,0,E001\n", string(content))
+}
+
+func TestTemplate_ReadByCSVReader(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		// Arrange
+		content, err := templateCSV()
+		assert.NoError(t, err)
+		cr := NewCSVReader(bytes.NewReader(content))
+
+		// Act
+		report, err := readTemplate(cr)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.ValidRows)
+		assert.Empty(t, report.Errors)
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		// Arrange
+		f, err := templateXLSX(i18n.LanguageEnglish)
+		assert.NoError(t, err)
+		buf, err := f.WriteToBuffer()
+		assert.NoError(t, err)
+		f.Close()
+		cr := NewCSVReader(buf, WithXLSX(""))
+
+		// Act
+		report, err := readTemplate(cr)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.ValidRows)
+		assert.Empty(t, report.Errors)
+	})
+}
+
+func readTemplate(cr *CSVReader) (CsvValidationReport, error) {
+	if err := cr.readHeader(); err != nil {
+		return CsvValidationReport{}, err
+	}
+	defer cr.close()
+	return validateCSV(cr, batchDeduction)
+}
+
+func TestTemplateXLSX(t *testing.T) {
+	// Act
+	f, err := templateXLSX(i18n.LanguageThai)
+
+	// Assert
+	assert.NoError(t, err)
+	defer f.Close()
+	comments, err := f.GetComments(xlsxSheetName)
+	assert.NoError(t, err)
+	assert.Len(t, comments, len(templateColumns()))
+	assert.Equal(t, "A1", comments[0].Cell)
+	assert.Contains(t, comments[0].Text, "รายได้รวมทั้งปี")
+	validations, err := f.GetDataValidations(xlsxSheetName)
+	assert.NoError(t, err)
+	assert.Len(t, validations, 4)
+	assert.Equal(t, "A2:A1048576", validations[0].Sqref)
+	numberType, _ := f.GetCellType(xlsxSheetName, "A2")
+	textType, _ := f.GetCellType(xlsxSheetName, "E2")
+	assert.NotEqual(t, textType, numberType)
+}
+
+func TestCSVTemplateHandler(t *testing.T) {
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tax/calculations/csv-template", nil)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		assert.NoError(t, New(NewMockTaxStorer()).CSVTemplateHandler(c))
+		return rec
+	}
+
+	t.Run("csv by default", func(t *testing.T) {
+		// Act
+		rec := get("")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMETextCSV+"; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="tax-template.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Contains(t, rec.Body.String(), "totalIncome,wht,donation,k-receipt,id\n")
+	})
+
+	t.Run("xlsx when accepted", func(t *testing.T) {
+		// Act
+		rec := get(MIMEApplicationXLSX)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEApplicationXLSX, rec.Header().Get(echo.HeaderContentType))
+		f, err := excelize.OpenReader(io.NopCloser(rec.Body))
+		assert.NoError(t, err)
+		defer f.Close()
+		rows, err := f.GetRows(xlsxSheetName)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"totalIncome", "wht", "donation", "k-receipt", "id"}, rows[0])
+	})
+}
@@ -13,7 +13,15 @@ func validateTaxInformation(info TaxInformation) (err error) {
 		err = errors.Join(err, ErrInvalidWHT)
 	}
 
-	if info.TotalIncome > 0 && info.WHT > info.TotalIncome {
+	if info.InterimTax < 0 {
+		err = errors.Join(err, ErrInvalidInterimTax)
+	}
+
+	grossIncome := info.TotalIncome
+	if info.Severance != nil {
+		grossIncome += info.Severance.Amount
+	}
+	if grossIncome > 0 && info.WHT > grossIncome {
 		err = errors.Join(err, ErrInvalidWHT)
 	}
 
@@ -23,5 +31,18 @@ func validateTaxInformation(info TaxInformation) (err error) {
 		}
 	}
 
+	if info.Severance != nil {
+		if e := validateSeverance(*info.Severance); e != nil {
+			err = errors.Join(err, e)
+		}
+	}
+
+	return
+}
+
+func validateSeverance(s Severance) (err error) {
+	if s.Amount < 0 || s.YearsOfService < 0 || s.LastSalary < 0 {
+		err = errors.Join(err, ErrInvalidSeverance)
+	}
 	return
 }
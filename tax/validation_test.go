@@ -43,6 +43,10 @@ func TestValidateTaxInformation_Success(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "WHT > total income but within severance",
+			taxInfo: TaxInformation{TotalIncome: 100_000.0, WHT: 150_000.0, Severance: &Severance{Amount: 100_000.0, YearsOfService: 5}},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -95,6 +99,21 @@ func TestValidateTaxInformation_Error(t *testing.T) {
 			},
 			wantErrors: []error{ErrInvalidAllowanceAmount},
 		},
+		{
+			name:       "interim tax < 0",
+			taxInfo:    TaxInformation{TotalIncome: 100_000.0, InterimTax: -1.0},
+			wantErrors: []error{ErrInvalidInterimTax},
+		},
+		{
+			name:       "negative severance years of service",
+			taxInfo:    TaxInformation{Severance: &Severance{Amount: 100_000.0, YearsOfService: -1}},
+			wantErrors: []error{ErrInvalidSeverance},
+		},
+		{
+			name:       "WHT > income including severance",
+			taxInfo:    TaxInformation{TotalIncome: 100_000.0, WHT: 300_000.0, Severance: &Severance{Amount: 100_000.0}},
+			wantErrors: []error{ErrInvalidWHT},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,82 @@
+package tax
+
+import (
+	"errors"
+	"github.com/xuri/excelize/v2"
+	"io"
+)
+
+// defaultXLSXMaxUncompressedBytes bounds how much a workbook may unzip to
+// when the reader is given no limit.
+const defaultXLSXMaxUncompressedBytes = 100 * 1024 * 1024
+
+// xlsxMaxXMLBytes is the largest part of a workbook unzipped in memory;
+// larger worksheets are unzipped to a temporary file.
+const xlsxMaxXMLBytes = 16 * 1024 * 1024
+
+// xlsxRecords reads the rows of one worksheet. Cells are read as their raw
+// values: numbers without their display format, formulas as their cached
+// result and text as is.
+type xlsxRecords struct {
+	file  *excelize.File
+	rows  *excelize.Rows
+	width int
+}
+
+// openXLSXRecords opens a workbook that unzips to at most maxBytes, so that a
+// small upload cannot expand into an unbounded amount of memory or disk.
+func openXLSXRecords(r io.Reader, sheet string, maxBytes int64) (*xlsxRecords, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultXLSXMaxUncompressedBytes
+	}
+	f, err := excelize.OpenReader(r, excelize.Options{
+		UnzipSizeLimit:    maxBytes,
+		UnzipXMLSizeLimit: min(maxBytes, xlsxMaxXMLBytes),
+	})
+	if err != nil {
+		return nil, errors.Join(err, ErrReadingXLSX)
+	}
+
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	}
+	if index, err := f.GetSheetIndex(sheet); err != nil || index < 0 {
+		f.Close()
+		return nil, ErrXLSXSheetNotFound
+	}
+
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, errors.Join(err, ErrReadingXLSX)
+	}
+	return &xlsxRecords{file: f, rows: rows}, nil
+}
+
+// Read returns the next row. A worksheet does not store trailing empty cells,
+// so rows are padded to the width of the first row, the header.
+func (x *xlsxRecords) Read() ([]string, error) {
+	if !x.rows.Next() {
+		if err := x.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	record, err := x.rows.Columns(excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if x.width == 0 {
+		x.width = len(record)
+	}
+	for len(record) < x.width {
+		record = append(record, "")
+	}
+	return record, nil
+}
+
+func (x *xlsxRecords) Close() error {
+	return errors.Join(x.rows.Close(), x.file.Close())
+}
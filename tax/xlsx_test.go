@@ -0,0 +1,156 @@
+//go:build unit
+
+package tax
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"testing"
+)
+
+func newTestWorkbook(t *testing.T, sheet string, cells map[string]any) *bytes.Buffer {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		t.Fatal(err)
+	}
+	for cell, value := range cells {
+		if err := f.SetCellValue(sheet, cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := f.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestCSVReader_XLSX(t *testing.T) {
+	// Arrange
+	f := excelize.NewFile()
+	f.SetCellValue("Sheet1", "A1", "employee")
+	f.SetCellValue("Sheet1", "B1", "totalIncome")
+	f.SetCellValue("Sheet1", "C1", "wht")
+	f.SetCellValue("Sheet1", "A2", "00123")
+	f.SetCellValue("Sheet1", "B2", 500_000.0)
+	f.SetCellValue("Sheet1", "C2", 1_000.0)
+	f.SetCellStyle("Sheet1", "B2", "B2", mustNumberStyle(t, f))
+	f.SetCellValue("Sheet1", "A4", "00456")
+	f.SetCellValue("Sheet1", "B4", 1_250_000.0)
+	f.SetCellFormula("Sheet1", "B4", "B2*2.5")
+	f.SetCellValue("Sheet1", "A5", "00789")
+	f.SetCellStr("Sheet1", "B5", "1.000,00")
+	buf := new(bytes.Buffer)
+	f.Write(buf)
+	f.Close()
+
+	cr := NewCSVReader(buf, WithUnknownColumns(), WithXLSX(""))
+
+	// Act
+	headerErr := cr.readHeader()
+	first, firstErr, err1 := cr.next()
+	second, secondErr, err2 := cr.next()
+	_, thirdErr, err3 := cr.next()
+	_, _, errEOF := cr.next()
+	closeErr := cr.close()
+
+	// Assert
+	assert.NoError(t, headerErr)
+	assert.NoError(t, err1)
+	assert.Nil(t, firstErr)
+	assert.Equal(t, 2, first.number)
+	assert.Equal(t, TaxInformation{TotalIncome: 500_000.0, WHT: 1_000.0}, first.info)
+	assert.Equal(t, map[string]string{"employee": "00123"}, first.metadata)
+	assert.NoError(t, err2)
+	assert.Nil(t, secondErr)
+	assert.Equal(t, 4, second.number)
+	assert.Equal(t, 1_250_000.0, second.info.TotalIncome)
+	assert.Equal(t, "", second.raw["wht"])
+	assert.NoError(t, err3)
+	assert.Equal(t, CsvRowError{Row: 5, Column: "totalIncome", Value: "1.000,00", Reason: ErrParsingData.Error()}, thirdErr.toCsvRowError())
+	assert.Equal(t, io.EOF, errEOF)
+	assert.NoError(t, closeErr)
+}
+
+func mustNumberStyle(t *testing.T, f *excelize.File) int {
+	t.Helper()
+	format := "#,##0.00"
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return style
+}
+
+func TestCSVReader_XLSX_NamedSheet(t *testing.T) {
+	t.Run("named sheet", func(t *testing.T) {
+		// Arrange
+		buf := newTestWorkbook(t, "payroll", map[string]any{"A1": "totalIncome", "A2": 500_000})
+		cr := NewCSVReader(buf, WithXLSX("payroll"))
+
+		// Act
+		headerErr := cr.readHeader()
+		row, rowErr, err := cr.next()
+		cr.close()
+
+		// Assert
+		assert.NoError(t, headerErr)
+		assert.NoError(t, err)
+		assert.Nil(t, rowErr)
+		assert.Equal(t, 500_000.0, row.info.TotalIncome)
+	})
+
+	t.Run("missing sheet", func(t *testing.T) {
+		// Arrange
+		buf := newTestWorkbook(t, "payroll", map[string]any{"A1": "totalIncome"})
+		cr := NewCSVReader(buf, WithXLSX("other"))
+
+		// Act
+		err := cr.readHeader()
+
+		// Assert
+		assert.ErrorIs(t, err, ErrXLSXSheetNotFound)
+	})
+
+	t.Run("not a workbook", func(t *testing.T) {
+		// Arrange
+		cr := NewCSVReader(bytes.NewBufferString("totalIncome\n500000"), WithXLSX(""))
+
+		// Act
+		err := cr.readHeader()
+
+		// Assert
+		assert.ErrorIs(t, err, ErrReadingXLSX)
+	})
+}
+
+func TestCSVReader_XLSXUnzipLimit(t *testing.T) {
+	t.Run("within the limit", func(t *testing.T) {
+		// Arrange
+		buf := newTestWorkbook(t, "Sheet1", map[string]any{"A1": "totalIncome", "A2": 500_000})
+		cr := NewCSVReader(buf, WithXLSX(""), WithXLSXUnzipLimit(1024*1024))
+
+		// Act
+		err := cr.readHeader()
+		cr.close()
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("unzips past the limit", func(t *testing.T) {
+		// Arrange
+		buf := newTestWorkbook(t, "Sheet1", map[string]any{"A1": "totalIncome", "A2": 500_000})
+		cr := NewCSVReader(buf, WithXLSX(""), WithXLSXUnzipLimit(1024))
+
+		// Act
+		err := cr.readHeader()
+
+		// Assert
+		assert.ErrorIs(t, err, ErrReadingXLSX)
+	})
+}
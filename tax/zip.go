@@ -0,0 +1,248 @@
+package tax
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/golfz/assessment-tax/i18n"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	MIMEApplicationZIP  = "application/zip"
+	MIMEApplicationXZIP = "application/x-zip-compressed"
+)
+
+// ZipTaxResponse is the result of a zip upload: one result per file, in
+// archive order, and the summary of the files that could be calculated.
+type ZipTaxResponse struct {
+	Files   []ZipFileResult `json:"files"`
+	Summary CsvTaxSummary   `json:"summary"`
+}
+
+// ZipFileResult is the result of one file of a zip upload. Error is set when
+// the file could not be calculated, in which case Errors holds the row that
+// stopped it in strict mode, or the columns at fault in its header.
+type ZipFileResult struct {
+	Name    string         `json:"name"`
+	Taxes   []CsvTaxRecord `json:"taxes,omitempty"`
+	Errors  []CsvRowError  `json:"errors,omitempty"`
+	Summary *CsvTaxSummary `json:"summary,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func isZIPUpload(file *multipart.FileHeader) bool {
+	contentType := file.Header.Get(echo.HeaderContentType)
+	return strings.EqualFold(filepath.Ext(file.Filename), ".zip") ||
+		contentType == MIMEApplicationZIP || contentType == MIMEApplicationXZIP
+}
+
+// zipEntryName cleans the name of an archive entry. Names that point outside
+// the archive, absolute ones or ones with a .. element, are rejected: they
+// are never written to disk, but a name echoed back must not be misleading.
+func zipEntryName(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", ErrInvalidZipEntry
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return "", ErrInvalidZipEntry
+		}
+	}
+	return path.Clean(name), nil
+}
+
+// isZipMetadata reports whether an entry is left behind by the program that
+// made the archive, such as __MACOSX folders and hidden files.
+func isZipMetadata(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+type zipEntry struct {
+	name string
+	file *zip.File
+}
+
+// zipEntries lists the files of an archive. The sizes declared by the
+// archive are checked up front; zipBudget enforces them while reading.
+func zipEntries(zr *zip.Reader, maxEntries int, maxBytes int64) ([]zipEntry, error) {
+	entries := make([]zipEntry, 0)
+	var size uint64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, err := zipEntryName(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if isZipMetadata(name) {
+			continue
+		}
+		if len(entries) == maxEntries {
+			return nil, ErrZipTooManyEntries
+		}
+		size += f.UncompressedSize64
+		if size > uint64(maxBytes) {
+			return nil, ErrZipTooLarge
+		}
+		entries = append(entries, zipEntry{name: name, file: f})
+	}
+	return entries, nil
+}
+
+// zipBudget is the number of uncompressed bytes left to read from an
+// archive, shared by all its entries, so that an archive lying about its
+// sizes still stops at the limit.
+type zipBudget struct {
+	remaining int64
+}
+
+type zipBudgetReader struct {
+	r      io.Reader
+	budget *zipBudget
+}
+
+func (br *zipBudgetReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > br.budget.remaining+1 {
+		p = p[:br.budget.remaining+1]
+	}
+	n, err := br.r.Read(p)
+	br.budget.remaining -= int64(n)
+	if br.budget.remaining < 0 {
+		return n, ErrZipTooLarge
+	}
+	return n, err
+}
+
+// zipFileError is the reason reported for a file that could not be
+// calculated.
+func zipFileError(err error) error {
+	for _, reason := range []error{ErrUnsupportedZipEntry, ErrReadingZip, ErrXLSXSheetNotFound, ErrReadingXLSX, ErrInvalidCSVHeader, ErrInvalidCSVRow, ErrCalculatingTax} {
+		if errors.Is(err, reason) {
+			return reason
+		}
+	}
+	return ErrReadingCSV
+}
+
+// calculateZipEntry reads one file of an archive the way a single upload is
+// read, with the same options. It returns the summary of the file, to be
+// added to the summary of the archive.
+func (h *Handler) calculateZipEntry(ctx context.Context, entry zipEntry, opts BatchOptions, deductionData deduction.Deduction, keepRows bool, rowLimit int, budget *zipBudget) ([]CsvTaxRecord, []*rowError, *summaryBuilder, error) {
+	switch strings.ToLower(path.Ext(entry.name)) {
+	case ".csv":
+		opts.XLSX = false
+	case ".xlsx":
+		opts.XLSX = true
+	default:
+		return nil, nil, nil, ErrUnsupportedZipEntry
+	}
+
+	rc, err := entry.file.Open()
+	if err != nil {
+		return nil, nil, nil, errors.Join(err, ErrReadingZip)
+	}
+	defer rc.Close()
+
+	// a workbook is unzipped again once read, within what is left of the budget
+	cr := NewCSVReader(&zipBudgetReader{r: rc, budget: budget}, opts.readerOptions(min(h.xlsxMaxBytes, budget.remaining))...)
+	if err := cr.readHeader(); err != nil {
+		return nil, nil, nil, err
+	}
+	defer cr.close()
+
+	summary := newSummaryBuilder()
+	taxes, rowErrors, err := h.collectCSV(ctx, cr, deductionData, opts.Strict, keepRows, rowLimit, summary)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return taxes, rowErrors, summary, nil
+}
+
+// uploadZIP calculates every csv and xlsx file of an uploaded zip. A file that
+// cannot be read, or in strict mode has an invalid row, is reported on its
+// own and left out of the summary; only an archive over its limits, or more
+// rows than can be buffered, fails the upload.
+func (h *Handler) uploadZIP(c echo.Context, src io.ReaderAt, size int64, opts BatchOptions) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return h.handleError(c, http.StatusBadRequest, err, "reading zip files", ErrReadingZip.Error())
+	}
+	entries, err := zipEntries(zr, h.zipMaxEntries, h.zipMaxUncompressedBytes)
+	if err != nil {
+		if errors.Is(err, ErrInvalidZipEntry) {
+			return h.handleError(c, http.StatusBadRequest, err, "reading zip files", ErrInvalidZipEntry.Error())
+		}
+		return h.handleError(c, http.StatusRequestEntityTooLarge, err, "reading zip files", err.Error())
+	}
+
+	deductionData, err := h.store.GetDeduction(time.Now())
+	if err != nil {
+		return h.handleError(c, http.StatusInternalServerError, err, "getting deduction", ErrGettingDeduction.Error())
+	}
+	if err := deductionData.Validate(); err != nil {
+		return h.handleError(c, http.StatusInternalServerError, errors.Join(err, ErrInvalidDeduction), "calculating tax", ErrCalculatingTax.Error())
+	}
+
+	ctx := c.Request().Context()
+	lang := i18n.FromRequest(c.Request())
+	summaryOnly := formBool(c, "summaryOnly")
+	rowLimit := h.csvRowLimit()
+	budget := &zipBudget{remaining: h.zipMaxUncompressedBytes}
+	total := newSummaryBuilder()
+	response := ZipTaxResponse{Files: make([]ZipFileResult, 0, len(entries))}
+
+	for _, entry := range entries {
+		limit := rowLimit
+		if summaryOnly {
+			limit = -1
+		}
+		taxes, rowErrors, summary, err := h.calculateZipEntry(ctx, entry, opts, deductionData, !summaryOnly, limit, budget)
+		switch {
+		case errors.Is(err, ErrZipTooLarge):
+			return h.handleError(c, http.StatusRequestEntityTooLarge, err, "reading zip files", ErrZipTooLarge.Error())
+		case errors.Is(err, ErrCSVTooLarge):
+			return h.handleError(c, http.StatusRequestEntityTooLarge, err, "reading zip files", ErrCSVTooLarge.Error())
+		case ctx.Err() != nil:
+			return h.handleError(c, http.StatusInternalServerError, ctx.Err(), "calculating tax", ErrCalculatingTax.Error())
+		}
+
+		result := ZipFileResult{Name: entry.name}
+		if err != nil {
+			c.Logger().Printf("error reading %s from zip: %v", entry.name, err)
+			result.Error = messages.Translate(lang, zipFileError(err).Error())
+			if rowErrs := rowErrorsOf(err); len(rowErrs) > 0 {
+				result.Errors = localiseCsvRowErrors(csvRowErrors(rowErrs), lang)
+			}
+			response.Files = append(response.Files, result)
+			continue
+		}
+
+		built := localiseCsvTaxSummary(summary.build(), lang)
+		result.Summary = &built
+		if !summaryOnly {
+			result.Taxes = taxes
+			if len(rowErrors) > 0 {
+				result.Errors = localiseCsvRowErrors(csvRowErrors(rowErrors), lang)
+			}
+		}
+		if rowLimit >= 0 {
+			rowLimit -= built.Rows + built.FailedRows
+		}
+		total.merge(summary)
+		response.Files = append(response.Files, result)
+	}
+
+	response.Summary = localiseCsvTaxSummary(total.build(), lang)
+	return c.JSON(http.StatusOK, response)
+}
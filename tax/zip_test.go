@@ -0,0 +1,264 @@
+//go:build unit
+
+package tax
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/golfz/assessment-tax/deduction"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type zipFile struct {
+	name    string
+	content string
+}
+
+func makeZip(t *testing.T, files ...zipFile) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(f.content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestZipEntryName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{name: "taxes.csv", want: "taxes.csv"},
+		{name: "hr/./payroll.csv", want: "hr/payroll.csv"},
+		{name: `hr\payroll.csv`, want: "hr/payroll.csv"},
+		{name: "../payroll.csv", wantErr: ErrInvalidZipEntry},
+		{name: "hr/../../payroll.csv", wantErr: ErrInvalidZipEntry},
+		{name: "/etc/payroll.csv", wantErr: ErrInvalidZipEntry},
+		{name: `C:\payroll.csv`, wantErr: ErrInvalidZipEntry},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := zipEntryName(tc.name)
+
+			// Assert
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestZipEntries(t *testing.T) {
+	open := func(content []byte) *zip.Reader {
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		assert.NoError(t, err)
+		return zr
+	}
+
+	t.Run("skips folders and metadata", func(t *testing.T) {
+		// Arrange
+		zr := open(makeZip(t,
+			zipFile{name: "hr/"},
+			zipFile{name: "hr/payroll.csv", content: "totalIncome\n"},
+			zipFile{name: "__MACOSX/hr/._payroll.csv", content: "x"},
+			zipFile{name: "hr/.DS_Store", content: "x"},
+		))
+
+		// Act
+		got, err := zipEntries(zr, 10, 1024)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+		assert.Equal(t, "hr/payroll.csv", got[0].name)
+	})
+
+	t.Run("too many entries", func(t *testing.T) {
+		// Arrange
+		zr := open(makeZip(t, zipFile{name: "a.csv"}, zipFile{name: "b.csv"}))
+
+		// Act
+		_, err := zipEntries(zr, 1, 1024)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrZipTooManyEntries)
+	})
+
+	t.Run("declared size above the limit", func(t *testing.T) {
+		// Arrange
+		zr := open(makeZip(t, zipFile{name: "a.csv", content: strings.Repeat("0", 600)}, zipFile{name: "b.csv", content: strings.Repeat("0", 600)}))
+
+		// Act
+		_, err := zipEntries(zr, 10, 1024)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrZipTooLarge)
+	})
+
+	t.Run("entry outside the archive", func(t *testing.T) {
+		// Arrange
+		zr := open(makeZip(t, zipFile{name: "../a.csv"}))
+
+		// Act
+		_, err := zipEntries(zr, 10, 1024)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidZipEntry)
+	})
+}
+
+func TestZipBudgetReader(t *testing.T) {
+	// Arrange
+	budget := &zipBudget{remaining: 10}
+	first := &zipBudgetReader{r: strings.NewReader("123456"), budget: budget}
+	second := &zipBudgetReader{r: strings.NewReader("123456"), budget: budget}
+
+	// Act
+	_, firstErr := io.ReadAll(first)
+	_, secondErr := io.ReadAll(second)
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.ErrorIs(t, secondErr, ErrZipTooLarge)
+}
+
+func TestUploadCSVHandler_Zip(t *testing.T) {
+	upload := func(content []byte, fields map[string]string, opts ...Option) *httptest.ResponseRecorder {
+		rec, c := newUploadContext("/tax/calculations/upload-csv", "branch.zip", content, fields, nil)
+		mock := NewMockTaxStorer()
+		mock.deduction = deduction.Deduction{Personal: 60_000.0, KReceipt: 50_000.0, Donation: 100_000.0}
+		assert.NoError(t, New(mock, opts...).UploadCSVHandler(c))
+		return rec
+	}
+	archive := makeZip(t,
+		zipFile{name: "hr.csv", content: "totalIncome,wht\n500000,0\nABC,0\n"},
+		zipFile{name: "sales/team.csv", content: "totalIncome,wht\n600000,0\n"},
+		zipFile{name: "notes.txt", content: "hello"},
+		zipFile{name: "broken.csv", content: "salary\n1\n"},
+	)
+
+	t.Run("results grouped by file with a grand summary", func(t *testing.T) {
+		// Act
+		rec := upload(archive, nil)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got ZipTaxResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("expected response body to be valid json, got %s", rec.Body.String())
+		}
+		assert.Len(t, got.Files, 4)
+		assert.Equal(t, "hr.csv", got.Files[0].Name)
+		assert.Equal(t, []CsvTaxRecord{{Row: 2, TotalIncome: 500_000.0, Tax: 29_000.0}}, got.Files[0].Taxes)
+		assert.Equal(t, []CsvRowError{{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}}, got.Files[0].Errors)
+		assert.Equal(t, "sales/team.csv", got.Files[1].Name)
+		assert.Equal(t, 1, got.Files[1].Summary.Rows)
+		assert.Equal(t, ZipFileResult{Name: "notes.txt", Error: ErrUnsupportedZipEntry.Error()}, got.Files[2])
+		assert.Equal(t, ZipFileResult{Name: "broken.csv", Error: ErrInvalidCSVHeader.Error(), Errors: []CsvRowError{
+			{Row: 1, Column: "salary", Reason: ErrUnknownCSVColumn.Error()},
+			{Row: 1, Column: "totalIncome", Reason: ErrMissingCSVColumn.Error()},
+		}}, got.Files[3])
+		assert.Equal(t, 2, got.Summary.Rows)
+		assert.Equal(t, 1, got.Summary.FailedRows)
+		assert.Equal(t, 1_100_000.0, got.Summary.TotalIncome)
+		assert.Equal(t, 70_000.0, got.Summary.TotalTax)
+	})
+
+	t.Run("strict; expect the invalid row on its file only", func(t *testing.T) {
+		// Act
+		rec := upload(archive, map[string]string{"strict": "true"})
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got ZipTaxResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, ErrReadingCSV.Error(), got.Files[0].Error)
+		assert.Equal(t, []CsvRowError{{Row: 3, Column: "totalIncome", Value: "ABC", Reason: ErrParsingData.Error()}}, got.Files[0].Errors)
+		assert.Nil(t, got.Files[0].Summary)
+		assert.Equal(t, 1, got.Summary.Rows)
+		assert.Equal(t, 0, got.Summary.FailedRows)
+	})
+
+	t.Run("strict with an invalid value; expect the row error on its file", func(t *testing.T) {
+		// Arrange
+		invalid := makeZip(t, zipFile{name: "hr.csv", content: "totalIncome,wht\n500000,-1\n"})
+
+		// Act
+		rec := upload(invalid, map[string]string{"strict": "true"})
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got ZipTaxResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, ErrInvalidCSVRow.Error(), got.Files[0].Error)
+		assert.Equal(t, []CsvRowError{{Row: 2, Column: "wht", Value: "-1", Reason: ErrInvalidWHT.Error()}}, got.Files[0].Errors)
+	})
+
+	t.Run("summary only; expect no rows", func(t *testing.T) {
+		// Act
+		rec := upload(archive, map[string]string{"summaryOnly": "true"}, WithCSVMaxBufferedRows(1))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got ZipTaxResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Nil(t, got.Files[0].Taxes)
+		assert.Nil(t, got.Files[0].Errors)
+		assert.Equal(t, 2, got.Summary.Rows)
+	})
+
+	t.Run("more rows than buffered across files; expect request entity too large", func(t *testing.T) {
+		// Act
+		rec := upload(archive, nil, WithCSVMaxBufferedRows(2))
+
+		// Assert
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("too many entries; expect request entity too large", func(t *testing.T) {
+		// Act
+		rec := upload(archive, nil, WithZipMaxEntries(2))
+
+		// Assert
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), ErrZipTooManyEntries.Error())
+	})
+
+	t.Run("too large uncompressed; expect request entity too large", func(t *testing.T) {
+		// Act
+		rec := upload(archive, nil, WithZipMaxUncompressedBytes(16))
+
+		// Assert
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), ErrZipTooLarge.Error())
+	})
+
+	t.Run("entry outside the archive; expect bad request", func(t *testing.T) {
+		// Act
+		rec := upload(makeZip(t, zipFile{name: "../../etc/taxes.csv", content: "totalIncome\n1\n"}), nil)
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("not a zip; expect bad request", func(t *testing.T) {
+		// Act
+		rec := upload([]byte("totalIncome\n500000\n"), nil)
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), ErrReadingZip.Error())
+	})
+}